@@ -1,13 +1,15 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"os"
+	"strings"
 
 	proc "github.com/hubert-heijkers/GoTHINK2020/builder/processes"
 	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
@@ -30,79 +32,219 @@ const ordersCubeName = "Sales"
 // The http client, extended with some odata functions, we'll use throughout.
 var client *odata.Client
 
-// createDimension is the function that triggers the TM1 server to create the dimension
-func createDimension(dimension *tm1.Dimension) *tm1.Dimension {
+// createDimension is the function that triggers the TM1 server to create the, already validated, dimension
+func createDimension(dimension *tm1.Dimension) (*tm1.Dimension, error) {
 
-	// Create a JSON representation for the dimension
-	jDimension, _ := json.Marshal(dimension)
-
-	// POST the dimension to the TM1 server
+	// POST the dimension, its attributes and their values, in a single batch, to the TM1 server
 	fmt.Println(">> Create dimension", dimension.Name)
-	resp := client.ExecutePOSTRequest(tm1ServiceRootURL+"Dimensions", "application/json", string(jDimension))
+	err := tm1.PostDimension(context.Background(), client, tm1ServiceRootURL, dimension)
+	if err != nil {
+		return nil, err
+	}
 
-	// Validate that the dimension got created successfully
-	odata.ValidateStatusCode(resp, 201, func() string {
-		return "Failed to create dimension '" + dimension.Name + "'."
-	})
-	resp.Body.Close()
+	// Return the generated dimension
+	return dimension, nil
+}
 
-	// Secondly create an element attribute named 'Caption' of type 'string'
-	fmt.Println(">> Create 'Caption' attribute for dimension", dimension.Name)
-	resp = client.ExecutePOSTRequest(tm1ServiceRootURL+"Dimensions('"+dimension.Name+"')/Hierarchies('"+dimension.Name+"')/ElementAttributes", "application/json", `{"Name":"Caption","Type":"String"}`)
+// syncDimension is the function that brings the dimension on the TM1 server in line with the generated one. If
+// the dimension doesn't exist yet it gets created, otherwise only the differences between the dimension on the
+// server and the generated one get applied. In preview mode the changes are only printed and not applied.
+func syncDimension(dimension *tm1.Dimension, preview bool) (*tm1.Dimension, error) {
 
-	// Validate that the element attribute got created successfully as well
-	odata.ValidateStatusCode(resp, 201, func() string {
-		return "Creating element attribute 'Caption' for dimension '" + dimension.Name + "'."
-	})
-	resp.Body.Close()
+	// Validate the dimension first, reporting all the problems with it at once, instead of having the TM1 server
+	// reject it with the first problem it runs into, and warn about any likely mistakes
+	err := dimension.Validate()
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range dimension.Warnings() {
+		fmt.Println(">> Warning:", warning)
+	}
 
-	// Now that the caption attribute exists lets set the captions accordingly for this
-	// we'll simply update the }ElementAttributes_DIMENSION cube directly, updating the
-	// default value. Note: TM1 Server doesn't support passing the attribute values as
-	// part of the dimension definition just yet (should shortly), so for now this is the
-	// easiest way around that. Alternatively, one could have updated the attribute
-	// values for elements one by one by POSTing to or PATCHing the LocalizedAttributes
-	// of the individual elements.
-	fmt.Println(">> Set 'Caption' attribute values for elements in dimension", dimension.Name)
-	resp = client.ExecutePOSTRequest(tm1ServiceRootURL+"Cubes('}ElementAttributes_"+dimension.Name+"')/tm1.Update", "application/json", dimension.GetAttributesJSON())
-
-	// Validate that the update executed successfully (by default an empty response is expected, hence the 204).
-	odata.ValidateStatusCode(resp, 204, func() string {
-		return "Setting Caption values for elements in dimension '" + dimension.Name + "'."
-	})
-	resp.Body.Close()
+	// Retrieve the current definition of the dimension, if it exists that is
+	current, err := tm1.GetDimension(context.Background(), client, tm1ServiceRootURL, dimension.Name)
+	if odata.IsNotFound(err) {
+		if preview == true {
+			fmt.Println(">> Dimension", dimension.Name, "doesn't exist yet and would be created")
+			return dimension, nil
+		}
+		return createDimension(dimension)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	// Return the generated dimension
-	return dimension
+	// Determine, and print, the changes to be made to the dimension
+	diff := tm1.DiffDimension(current, dimension)
+	if diff.Empty() {
+		fmt.Println(">> Dimension", dimension.Name, "is up to date")
+		return dimension, nil
+	}
+	fmt.Println(">> Changes to dimension", dimension.Name)
+	for _, change := range diff.Changes {
+		fmt.Println("   ", change)
+	}
+	if preview == true {
+		return dimension, nil
+	}
+
+	// and apply them
+	fmt.Println(">> Update dimension", dimension.Name)
+	err = diff.Apply(context.Background(), client, tm1ServiceRootURL)
+	if err != nil {
+		return nil, err
+	}
+	return dimension, nil
+}
+
+// syncSubset is the function that brings the, public, subset on the TM1 server in line with the defined one. If the
+// subset doesn't exist yet it gets created, otherwise, if its definition changed, it gets updated. In preview mode
+// the changes are only printed and not applied.
+func syncSubset(subset *tm1.Subset, preview bool) error {
+
+	// Retrieve the current definition of the subset, if it exists that is
+	current, err := tm1.GetSubset(context.Background(), client, tm1ServiceRootURL, subset.Dimension, subset.Hierarchy, subset.Name, false)
+	if odata.IsNotFound(err) {
+		if preview == true {
+			fmt.Println(">> Subset", subset.Name, "doesn't exist yet and would be created")
+			return nil
+		}
+		fmt.Println(">> Create subset", subset.Name, "of dimension", subset.Dimension)
+		return tm1.PostSubset(context.Background(), client, tm1ServiceRootURL, subset, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only update the subset if its definition changed
+	if current.Equal(subset) {
+		fmt.Println(">> Subset", subset.Name, "is up to date")
+		return nil
+	}
+	if preview == true {
+		fmt.Println(">> Subset", subset.Name, "changed and would be updated")
+		return nil
+	}
+	fmt.Println(">> Update subset", subset.Name, "of dimension", subset.Dimension)
+	return tm1.UpdateSubset(context.Background(), client, tm1ServiceRootURL, subset, false)
+}
+
+// syncProcess is the function that creates the process on the TM1 server, or, if it exists already and its
+// definition changed, updates it. In preview mode the changes are only printed and not applied.
+func syncProcess(process *tm1.Process, preview bool) error {
+	current, err := tm1.GetProcess(context.Background(), client, tm1ServiceRootURL, process.Name)
+	if odata.IsNotFound(err) {
+		if preview == true {
+			fmt.Println(">> Process", process.Name, "doesn't exist yet and would be created")
+			return nil
+		}
+		fmt.Println(">> Create process", process.Name)
+		return tm1.PostProcess(context.Background(), client, tm1ServiceRootURL, process)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only update the process if its definition changed
+	if current.Equal(process) {
+		fmt.Println(">> Process", process.Name, "is up to date")
+		return nil
+	}
+	if preview == true {
+		fmt.Println(">> Process", process.Name, "changed and would be updated")
+		return nil
+	}
+	fmt.Println(">> Update process", process.Name)
+	return tm1.UpdateProcess(context.Background(), client, tm1ServiceRootURL, process)
+}
+
+// syncChore is the function that creates the chore on the TM1 server, or, if it exists already and its definition
+// changed, updates it. In preview mode the changes are only printed and not applied.
+func syncChore(chore *tm1.Chore, preview bool) error {
+	current, err := tm1.GetChore(context.Background(), client, tm1ServiceRootURL, chore.Name)
+	if odata.IsNotFound(err) {
+		if preview == true {
+			fmt.Println(">> Chore", chore.Name, "doesn't exist yet and would be created")
+			return nil
+		}
+		fmt.Println(">> Create chore", chore.Name)
+		return tm1.PostChore(context.Background(), client, tm1ServiceRootURL, chore)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only update the chore if its definition changed
+	if current.Equal(chore) {
+		fmt.Println(">> Chore", chore.Name, "is up to date")
+		return nil
+	}
+	if preview == true {
+		fmt.Println(">> Chore", chore.Name, "changed and would be updated")
+		return nil
+	}
+	fmt.Println(">> Update chore", chore.Name)
+	return tm1.UpdateChore(context.Background(), client, tm1ServiceRootURL, chore)
 }
 
 // createCube is the function that, given a set of dimension and rules, requests the TM1 server to create the cube
-func createCube(name string, dimensions []*tm1.Dimension, rules string) string {
+func createCube(name string, dimensions []*tm1.Dimension, rules string) (string, error) {
 
-	// Build array of dimension ids representing the dimensions making up the cube
-	dimensionIds := make([]string, len(dimensions))
+	// Define the cube, referring to the dimensions making up the cube by name
+	cube := &tm1.Cube{Name: name, Dimensions: make([]string, len(dimensions)), Rules: rules}
 	for i, dim := range dimensions {
-		dimensionIds[i] = "Dimensions('" + dim.Name + "')"
+		cube.Dimensions[i] = dim.Name
 	}
 
-	// Create a JSON representation for the cube
-	jCube, _ := json.Marshal(tm1.CubePost{Name: name, DimensionIds: dimensionIds, Rules: rules})
-
-	// POST the dimension to the TM1 server
+	// POST the cube to the TM1 server
 	fmt.Println(">> Create cube", name)
-	resp := client.ExecutePOSTRequest(tm1ServiceRootURL+"Cubes", "application/json", string(jCube))
-
-	// Validate that the cube got created successfully
-	odata.ValidateStatusCode(resp, 201, func() string {
-		return "Failed to create cube '" + name + "'."
-	})
-	resp.Body.Close()
+	err := tm1.PostCube(context.Background(), client, tm1ServiceRootURL, cube)
+	if err != nil {
+		return "", err
+	}
 
 	// Return the odata.id of the generated cube
-	return "Cubes('" + name + "')"
+	return tm1.CubePath(name), nil
+}
+
+// syncView is the function that creates the, public, view on the cube on the TM1 server if it doesn't exist yet. An
+// existing view is left alone, as our users might have tailored it to their needs. In preview mode the view is only
+// reported and not created.
+func syncView(cube string, view *tm1.View, preview bool) error {
+	_, err := tm1.GetView(context.Background(), client, tm1ServiceRootURL, cube, view.Name, false)
+	if err == nil {
+		fmt.Println(">> View", view.Name, "on cube", cube, "exists already")
+		return nil
+	}
+	if odata.IsNotFound(err) == false {
+		return err
+	}
+	if preview == true {
+		fmt.Println(">> View", view.Name, "on cube", cube, "doesn't exist yet and would be created")
+		return nil
+	}
+	fmt.Println(">> Create view", view.Name, "on cube", cube)
+	return tm1.PostView(context.Background(), client, tm1ServiceRootURL, cube, view, false)
+}
+
+// revenueByCategoryByYearView returns the definition of the default view on the Sales cube, showing the revenue of
+// every product category, on the rows, for every year, on the columns, for all customers and employees
+func revenueByCategoryByYearView() *tm1.View {
+	view := tm1.CreateNativeView("Revenue by Category by Year")
+	view.AddTitle(customerDimensionName, customerDimensionName, "All", "All")
+	view.AddTitle(employeeDimensionName, employeeDimensionName, "All", "All")
+	view.AddTitle(measuresDimensionName, measuresDimensionName, "Revenue", "Revenue")
+	view.AddColumn(timeDimensionName, timeDimensionName).Expression = "{" + tm1.MDXMember(timeDimensionName, timeDimensionName, "All") + ".Children}"
+	view.AddRow(productDimensionName, productDimensionName).Expression = "{" + tm1.MDXMember(productDimensionName, productDimensionName, "All") + ".Children}"
+	view.SuppressEmptyRows = true
+	return view
 }
 
 func main() {
+	// Preview mode only prints the changes the builder would make to existing dimensions
+	preview := flag.Bool("preview", false, "print the changes to the dimensions, and cube, without applying them")
+	flag.Parse()
+
 	// Load environment variables from .env file
 	err := godotenv.Load()
 	if err != nil {
@@ -111,35 +253,50 @@ func main() {
 	datasourceServiceRootURL = os.Getenv("DATASOURCE_SERVICE_ROOT_URL")
 	tm1ServiceRootURL = os.Getenv("TM1_SERVICE_ROOT_URL")
 
+	// The, optional, comma separated list of cultures, e.g. "de,fr,nl", to localize the dimensions for
+	if cultures := os.Getenv("TM1_CULTURES"); cultures != "" {
+		proc.Cultures = strings.Split(cultures, ",")
+	}
+
 	// Create the one and only http client we'll be using, with a cookie jar enabled to keep reusing our session
 	client = &odata.Client{}
 	cookieJar, _ := cookiejar.New(nil)
 	client.Jar = cookieJar
 
-	// Validate that the TM1 server is accessable by requesting the version of the server
-	req, _ := http.NewRequest("GET", tm1ServiceRootURL+"Configuration/ProductVersion/$value", nil)
-
-	// Since this is our initial request we'll have to provide a user name and
-	// password, also conveniently stored in the environment variables, to authenticate.
-	// Note: using authentication mode 1, TM1 authentication, which maps to basic
-	// authentication in HTTP[S]
-	req.SetBasicAuth(os.Getenv("TM1_USER"), os.Getenv("TM1_PASSWORD"))
-
-	// We'll expect text back in this case but we'll simply dump the content out and
-	// won't do any content type verification here
-	req.Header.Add("Accept", "*/*")
+	// Retry requests failing with a transient error, like a 503 while the TM1 server is busy saving or the
+	// NorthWind service throttling us, and go easy on the public NorthWind service while we're at it.
+	// Note: only idempotent requests get retried, our "+" spreading updates therefore won't be.
+	client.RetryPolicy = odata.DefaultRetryPolicy()
+	client.RateLimiter = odata.NewRateLimiter(10, 5)
+
+	// Validate that the TM1 server is accessable by requesting the version of the server, through the client so
+	// this initial request gets rate limited and retried like any other
+	authenticate := func(req *http.Request) {
+		// Since this is our initial request we'll have to provide a user name and
+		// password, also conveniently stored in the environment variables, to authenticate.
+		// Note: using authentication mode 1, TM1 authentication, which maps to basic
+		// authentication in HTTP[S]
+		req.SetBasicAuth(os.Getenv("TM1_USER"), os.Getenv("TM1_PASSWORD"))
+
+		// We'll expect text back in this case but we'll simply dump the content out and
+		// won't do any content type verification here
+		req.Header.Set("Accept", "*/*")
+	}
 
 	// Let's execute the request
-	resp, err := client.Do(req)
+	resp, err := client.ExecuteGETRequestEx(tm1ServiceRootURL+"Configuration/ProductVersion/$value", authenticate)
 	if err != nil {
 		// Execution of the request failed, log the error and terminate
 		log.Fatal(err)
 	}
 
 	// Validate that the request executed successfully
-	odata.ValidateStatusCode(resp, 200, func() string {
+	err = odata.ValidateStatusCode(resp, 200, func() string {
 		return "Server responded with an unexpected result while asking for its version number."
 	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// The body simply contains the version number of the server
 	version, _ := ioutil.ReadAll(resp.Body)
@@ -155,15 +312,74 @@ func main() {
 	// Now let's build some Dimensions. The definition of the dimension is based on data
 	// in the NorthWind database, a data source hosted on odata.org which can be queried
 	// using its OData complaint REST API.
-	var dimensions [5]*tm1.Dimension
-	dimensions[0] = createDimension(proc.GenerateProductDimension(client, datasourceServiceRootURL, productDimensionName))
-	dimensions[1] = createDimension(proc.GenerateCustomerDimension(client, datasourceServiceRootURL, customerDimensionName))
-	dimensions[2] = createDimension(proc.GenerateEmployeeDimension(client, datasourceServiceRootURL, employeeDimensionName))
-	dimensions[3] = createDimension(proc.GenerateTimeDimension(client, datasourceServiceRootURL, timeDimensionName))
-	dimensions[4] = createDimension(proc.GenerateMeasuresDimension(client, datasourceServiceRootURL, measuresDimensionName))
-
-	// Now that we have all our dimensions, let's create our Sales cube
-	createCube(ordersCubeName, dimensions[:], "UNDEFVALS;\nSKIPCHECK;\n\n['UnitPrice']=['Revenue']\\['Quantity'];\n\nFEEDERS;\n['Quantity']=>['UnitPrice'];")
+	generators := []struct {
+		name     string
+		generate func(*odata.Client, string, string) (*tm1.Dimension, error)
+	}{
+		{productDimensionName, proc.GenerateProductDimension},
+		{customerDimensionName, proc.GenerateCustomerDimension},
+		{employeeDimensionName, proc.GenerateEmployeeDimension},
+		{timeDimensionName, proc.GenerateTimeDimension},
+		{measuresDimensionName, proc.GenerateMeasuresDimension},
+	}
+	dimensions := make([]*tm1.Dimension, len(generators))
+	for i, generator := range generators {
+		dimension, err := generator.generate(client, datasourceServiceRootURL, generator.name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if dimensions[i], err = syncDimension(dimension, *preview); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Now that we have all our dimensions, let's create our Sales cube, unless it exists already
+	// Note: the changes to the dimensions are reflected in the cube automatically.
+	_, err = tm1.GetCube(context.Background(), client, tm1ServiceRootURL, ordersCubeName)
+	if err == nil {
+		fmt.Println(">> Cube", ordersCubeName, "exists already")
+	} else if odata.IsNotFound(err) == false {
+		log.Fatal(err)
+	} else if *preview == true {
+		fmt.Println(">> Cube", ordersCubeName, "doesn't exist yet and would be created")
+	} else {
+		_, err = createCube(ordersCubeName, dimensions, "UNDEFVALS;\nSKIPCHECK;\n\n['UnitPrice']=['Revenue']\\['Quantity'];\n\nFEEDERS;\n['Quantity']=>['UnitPrice'];")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// The default view on the Sales cube, which, like the subsets, gets created if it doesn't exist, whether the
+	// cube was just created or existed already
+	if err = syncView(ordersCubeName, revenueByCategoryByYearView(), *preview); err != nil {
+		log.Fatal(err)
+	}
+
+	// Last but not least, let's create some subsets for our users to use
+	subsets := []*tm1.Subset{
+		// The months, in the Time dimension, of the last 12 months for which we have data
+		tm1.CreateDynamicSubset("Last 12 months", timeDimensionName, timeDimensionName, "{TAIL(TM1FILTERBYLEVEL(TM1SUBSETALL("+tm1.MDXName(timeDimensionName)+"."+tm1.MDXName(timeDimensionName)+"), 1), 12)}"),
+		// The 10 customers with the most revenue, across all time, products and employees
+		tm1.CreateDynamicSubset("Top 10 customers by revenue", customerDimensionName, customerDimensionName, "{TOPCOUNT(TM1FILTERBYLEVEL(TM1SUBSETALL("+tm1.MDXName(customerDimensionName)+"."+tm1.MDXName(customerDimensionName)+"), 0), 10, "+tm1.MDXName(ordersCubeName)+".("+tm1.MDXMember(measuresDimensionName, measuresDimensionName, "Revenue")+"))}"),
+	}
+	for _, subset := range subsets {
+		if err = syncSubset(subset, *preview); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// If we know how to load the data, schedule the nightly reload of our Sales cube
+	if command := os.Getenv("TM1_RELOAD_COMMAND"); command != "" {
+		processes, chore := proc.GenerateNightlyReload(ordersCubeName, command)
+		for _, process := range processes {
+			if err = syncProcess(process, *preview); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err = syncChore(chore, *preview); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// And we are done!
 	fmt.Println(">> Done!")
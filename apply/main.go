@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+	"github.com/hubert-heijkers/GoTHINK2020/common/tm1"
+	"github.com/joho/godotenv"
+)
+
+// Environment variables
+var tm1ServiceRootURL string
+
+// The http client, extended with some odata functions, we'll use throughout.
+var client *odata.Client
+
+func main() {
+	folder := flag.String("model", "model", "the folder to read the model from")
+	autoApprove := flag.Bool("auto-approve", false, "apply the plan without asking for confirmation")
+	flag.Parse()
+
+	// Read the model first, there is no point in talking to the server if the folder can't be read
+	model, err := tm1.ReadModel(*folder)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load environment variables from .env file
+	err = godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+	tm1ServiceRootURL = os.Getenv("TM1_SERVICE_ROOT_URL")
+
+	// Create the one and only http client we'll be using, with a cookie jar enabled to keep reusing our session
+	client = &odata.Client{}
+	cookieJar, _ := cookiejar.New(nil)
+	client.Jar = cookieJar
+	client.RetryPolicy = odata.DefaultRetryPolicy()
+
+	// Validate that the TM1 server is accessable by requesting the version of the server, through the client so
+	// this initial request gets rate limited and retried like any other
+	authenticate := func(req *http.Request) {
+		// Since this is our initial request we'll have to provide a user name and
+		// password, also conveniently stored in the environment variables, to authenticate.
+		// Note: using authentication mode 1, TM1 authentication, which maps to basic
+		// authentication in HTTP[S]
+		req.SetBasicAuth(os.Getenv("TM1_USER"), os.Getenv("TM1_PASSWORD"))
+		req.Header.Set("Accept", "*/*")
+	}
+
+	// Let's execute the request
+	resp, err := client.ExecuteGETRequestEx(tm1ServiceRootURL+"Configuration/ProductVersion/$value", authenticate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Validate that the request executed successfully
+	err = odata.ValidateStatusCode(resp, 200, func() string {
+		return "Server responded with an unexpected result while asking for its version number."
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The body simply contains the version number of the server
+	version, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	fmt.Println("Using TM1 Server version", string(version))
+
+	// Interrupting the apply cancels the context which aborts any in-flight request
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Compare the model with the server and work out what needs to change
+	fmt.Println(">> Planning", len(model.Dimensions), "dimensions,", len(model.Cubes), "cubes and", len(model.Processes), "processes from", *folder)
+	plan, err := tm1.PlanModel(ctx, client, tm1ServiceRootURL, model)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if plan.Empty() == true {
+		fmt.Println(">> The server is up to date, nothing to do!")
+		return
+	}
+	creates, updates, deletes := 0, 0, 0
+	for _, step := range plan.Steps {
+		fmt.Println(step)
+		switch step.Action {
+		case tm1.PlanCreate:
+			creates++
+		case tm1.PlanUpdate:
+			updates++
+		case tm1.PlanDelete:
+			deletes++
+		}
+	}
+	fmt.Println(">> Plan:", creates, "to create,", updates, "to update,", deletes, "to delete")
+
+	// Nothing gets changed unless the plan gets approved
+	if *autoApprove == false {
+		fmt.Print(">> Apply this plan? Only 'yes' will be accepted: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			fmt.Println(">> Apply cancelled!")
+			return
+		}
+	}
+
+	fmt.Println(">> Applying plan...")
+	err = plan.Apply(ctx, client, tm1ServiceRootURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// And we are done!
+	fmt.Println(">> Done!")
+}
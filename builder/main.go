@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -27,75 +27,47 @@ const ordersCubeName = "Sales"
 var client *odata.Client
 
 // createDimension is the function that triggers the TM1 server to create the dimension
-func createDimension(dimension *tm1.Dimension) *tm1.Dimension {
+func createDimension(dimension *tm1.Dimension) (*tm1.Dimension, error) {
 
-	// Create a JSON representation for the dimension
-	jDimension, _ := json.Marshal(dimension)
+	// Validate the dimension first, reporting all the problems with it at once, instead of
+	// having the TM1 server reject it with the first problem it runs into, and warn about any likely mistakes
+	err := dimension.Validate()
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range dimension.Warnings() {
+		fmt.Println(">> Warning:", warning)
+	}
 
-	// POST the dimension to the TM1 server
+	// POST the dimension, its attributes and their values, in a single batch, to the TM1 server
 	fmt.Println(">> Create dimension", dimension.Name)
-	resp := client.ExecutePOSTRequest(tm1ServiceRootURL+"Dimensions", "application/json", string(jDimension))
-
-	// Validate that the dimension got created successfully
-	odata.ValidateStatusCode(resp, 201, func() string {
-		return "Failed to create dimension '" + dimension.Name + "'."
-	})
-	resp.Body.Close()
-
-	// Secondly create an element attribute named 'Caption' of type 'string'
-	fmt.Println(">> Create 'Caption' attribute for dimension", dimension.Name)
-	resp = client.ExecutePOSTRequest(tm1ServiceRootURL+"Dimensions('"+dimension.Name+"')/Hierarchies('"+dimension.Name+"')/ElementAttributes", "application/json", `{"Name":"Caption","Type":"String"}`)
-
-	// Validate that the element attribute got created successfully as well
-	odata.ValidateStatusCode(resp, 201, func() string {
-		return "Creating element attribute 'Caption' for dimension '" + dimension.Name + "'."
-	})
-	resp.Body.Close()
-
-	// Now that the caption attribute exists lets set the captions accordingly for this
-	// we'll simply update the }ElementAttributes_DIMENSION cube directly, updating the
-	// default value. Note: TM1 Server doesn't support passing the attribute values as
-	// part of the dimension definition just yet (should shortly), so for now this is the
-	// easiest way around that. Alternatively, one could have updated the attribute
-	// values for elements one by one by POSTing to or PATCHing the LocalizedAttributes
-	// of the individual elements.
-	fmt.Println(">> Set 'Caption' attribute values for elements in dimension", dimension.Name)
-	resp = client.ExecutePOSTRequest(tm1ServiceRootURL+"Cubes('}ElementAttributes_"+dimension.Name+"')/tm1.Update", "application/json", dimension.GetAttributesJSON())
-
-	// Validate that the update executed successfully (by default an empty response is expected, hence the 204).
-	odata.ValidateStatusCode(resp, 204, func() string {
-		return "Setting Caption values for elements in dimension '" + dimension.Name + "'."
-	})
-	resp.Body.Close()
+	err = tm1.PostDimension(context.Background(), client, tm1ServiceRootURL, dimension)
+	if err != nil {
+		return nil, err
+	}
 
 	// Return the generated dimension
-	return dimension
+	return dimension, nil
 }
 
 // createCube is the function that, given a set of dimension and rules, requests the TM1 server to create the cube
-func createCube(name string, dimensions []*tm1.Dimension, rules string) string {
+func createCube(name string, dimensions []*tm1.Dimension, rules string) (string, error) {
 
-	// Build array of dimension ids representing the dimensions making up the cube
-	dimensionIds := make([]string, len(dimensions))
+	// Define the cube, referring to the dimensions making up the cube by name
+	cube := &tm1.Cube{Name: name, Dimensions: make([]string, len(dimensions)), Rules: rules}
 	for i, dim := range dimensions {
-		dimensionIds[i] = "Dimensions('" + dim.Name + "')"
+		cube.Dimensions[i] = dim.Name
 	}
 
-	// Create a JSON representation for the cube
-	jCube, _ := json.Marshal(tm1.CubePost{Name: name, DimensionIds: dimensionIds, Rules: rules})
-
-	// POST the dimension to the TM1 server
+	// POST the cube to the TM1 server
 	fmt.Println(">> Create cube", name)
-	resp := client.ExecutePOSTRequest(tm1ServiceRootURL+"Cubes", "application/json", string(jCube))
-
-	// Validate that the cube got created successfully
-	odata.ValidateStatusCode(resp, 201, func() string {
-		return "Failed to create cube '" + name + "'."
-	})
-	resp.Body.Close()
+	err := tm1.PostCube(context.Background(), client, tm1ServiceRootURL, cube)
+	if err != nil {
+		return "", err
+	}
 
 	// Return the odata.id of the generated cube
-	return "Cubes('" + name + "')"
+	return tm1.CubePath(name), nil
 }
 
 func main() {
@@ -18,11 +18,3 @@ type Order struct {
 	Date       time.Time     `json:"OrderDate"`
 	Details    []OrderDetail `json:"Order_Details"`
 }
-
-// OrderCollectionResponse defines the structure of an odata compliant response wrapping a order collection
-type OrderCollectionResponse struct {
-	Context  string  `json:"@odata.context"`
-	Count    int     `json:"@odata.count"`
-	Orders   []Order `json:"value"`
-	NextLink string  `json:"@odata.nextLink"`
-}
@@ -2,8 +2,11 @@ package northwind
 
 // Product defines the structure of A single Product entity
 type Product struct {
-	ID   int    `json:"ProductID"`
-	Name string `json:"ProductName"`
+	ID              int    `json:"ProductID"`
+	Name            string `json:"ProductName"`
+	QuantityPerUnit string
+	UnitPrice       float64
+	Discontinued    bool
 }
 
 // Category defines the structure of A single Category entity
@@ -12,11 +15,3 @@ type Category struct {
 	Name     string    `json:"CategoryName"`
 	Products []Product `json:"Products"`
 }
-
-// CategoryCollectionResponse defines the structure of an odata compliant response wrapping a category collection
-type CategoryCollectionResponse struct {
-	Context    string     `json:"@odata.context"`
-	Count      int        `json:"@odata.count"`
-	Categories []Category `json:"value"`
-	NextLink   string     `json:"@odata.nextLink"`
-}
@@ -0,0 +1,75 @@
+package processes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cultures holds the cultures, as defined in the }Cultures dimension of the TM1 server, e.g. "de" or "fr-CA",
+// for which the generated dimensions get localized captions
+var Cultures []string
+
+// calendarNames defines the names of the months, January first, and the days of the week, Sunday first, in a language
+type calendarNames struct {
+	months   [12]string
+	weekdays [7]string
+}
+
+// languages maps the language codes to the names of the months and days of the week in that language
+var languages = map[string]calendarNames{
+	"en": {
+		months:   [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		weekdays: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	},
+	"de": {
+		months:   [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		weekdays: [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+	},
+	"es": {
+		months:   [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		weekdays: [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+	},
+	"fr": {
+		months:   [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		weekdays: [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+	},
+	"it": {
+		months:   [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		weekdays: [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+	},
+	"nl": {
+		months:   [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		weekdays: [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+	},
+}
+
+// calendarNamesFor returns the names of the months and days of the week for the culture, which can be either a
+// language, e.g. "fr", or a language and region, e.g. "fr-CA", for which we use the names of the language
+func calendarNamesFor(culture string) (calendarNames, error) {
+	language := strings.ToLower(strings.SplitN(culture, "-", 2)[0])
+	names, ok := languages[language]
+	if !ok {
+		return calendarNames{}, fmt.Errorf("no month and weekday names available for culture '%s'", culture)
+	}
+	return names, nil
+}
+
+// month returns the name of the month
+func (names calendarNames) month(month time.Month) string {
+	return names.months[month-1]
+}
+
+// weekday returns the name of the day of the week
+func (names calendarNames) weekday(weekday time.Weekday) string {
+	return names.weekdays[weekday]
+}
+
+// abbreviate returns the first three characters of the name, the same way we abbreviate the default captions
+func abbreviate(name string) string {
+	runes := []rune(name)
+	if len(runes) > 3 {
+		runes = runes[:3]
+	}
+	return string(runes)
+}
@@ -0,0 +1,57 @@
+package processes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarNamesFor(t *testing.T) {
+	tests := []struct {
+		culture string
+		month   string
+		weekday string
+		err     bool
+	}{
+		{"en", "March", "Sunday", false},
+		{"fr", "mars", "dimanche", false},
+		{"fr-CA", "mars", "dimanche", false},
+		{"DE-at", "März", "Sonntag", false},
+		{"pt-BR", "", "", true},
+	}
+	for _, test := range tests {
+		names, err := calendarNamesFor(test.culture)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: got no error, want one", test.culture)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.culture, err)
+			continue
+		}
+		if got := names.month(time.March); got != test.month {
+			t.Errorf("%s: got month %q, want %q", test.culture, got, test.month)
+		}
+		if got := names.weekday(time.Sunday); got != test.weekday {
+			t.Errorf("%s: got weekday %q, want %q", test.culture, got, test.weekday)
+		}
+	}
+}
+
+func TestAbbreviate(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"January", "Jan"},
+		{"März", "Mär"},
+		{"mai", "mai"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := abbreviate(test.name); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
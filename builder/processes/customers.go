@@ -1,8 +1,7 @@
 package processes
 
 import (
-	"encoding/json"
-	"log"
+	"context"
 
 	"github.com/hubert-heijkers/GoTHINK2020/builder/northwind"
 	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
@@ -19,14 +18,7 @@ type customerDimension struct {
 	cityElement    *tm1.Element
 }
 
-func (d *customerDimension) processResponse(responseBody []byte) (int, string) {
-	// Unmarshal the JSON response
-	res := northwind.CustomerCollectionResponse{}
-	err := json.Unmarshal(responseBody, &res)
-	if err != nil {
-		log.Fatal(err)
-	}
-
+func (d *customerDimension) processCustomers(customers []northwind.Customer) error {
 	// Process the collection of customers returned by the data source
 	// PRESUMPTIONS:
 	//  - NO DUPLICATE REGION NAMES (WHICH WORKS IN THIS EXAMPLE;-)
@@ -37,9 +29,10 @@ func (d *customerDimension) processResponse(responseBody []byte) (int, string) {
 			d.dimension = tm1.CreateDimension(d.name)
 		}
 		d.hierarchy = d.dimension.AddHierarchy(d.name)
+		d.hierarchy.AddAttribute("Phone", tm1.AttributeString)
 		d.allElement = d.hierarchy.AddElement("All", "All Customers")
 	}
-	for _, customer := range res.Customers {
+	for _, customer := range customers {
 		if d.countryElement == nil || d.countryElement.Name != customer.Country {
 			d.countryElement = d.hierarchy.AddElement(customer.Country, "")
 			d.hierarchy.AddEdge(d.allElement.Name, d.countryElement.Name)
@@ -77,16 +70,22 @@ func (d *customerDimension) processResponse(responseBody []byte) (int, string) {
 			}
 		}
 		customerElement := d.hierarchy.AddElement(customer.ID, customer.Name)
+		err := d.hierarchy.SetStringAttribute(customerElement.Name, "Phone", customer.Phone)
+		if err != nil {
+			return err
+		}
 		d.hierarchy.AddEdge(d.cityElement.Name, customerElement.Name)
 	}
 
-	// Return the nextLink, if there is one
-	return res.Count, res.NextLink
+	return nil
 }
 
 // GenerateCustomerDimension generates, based on the data from the northwind database, the dimension definition for the customers dimension
-func GenerateCustomerDimension(client *odata.Client, datasourceServiceRootURL string, name string) *tm1.Dimension {
+func GenerateCustomerDimension(client *odata.Client, datasourceServiceRootURL string, name string) (*tm1.Dimension, error) {
 	dimCustomers := &customerDimension{name: name}
-	client.IterateCollection(datasourceServiceRootURL, "Customers?$orderby=Country%20asc,Region%20asc,%20City%20asc&$select=CustomerID,CompanyName,City,Region,Country", dimCustomers.processResponse)
-	return dimCustomers.dimension
+	err := odata.IteratePages(context.Background(), client, datasourceServiceRootURL, odata.NewQuery("Customers").Select("CustomerID", "CompanyName", "City", "Region", "Country", "Phone").OrderBy("Country", "Region", "City").String(), nil, dimCustomers.processCustomers)
+	if err != nil {
+		return nil, err
+	}
+	return dimCustomers.dimension, nil
 }
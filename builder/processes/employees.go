@@ -1,8 +1,7 @@
 package processes
 
 import (
-	"encoding/json"
-	"log"
+	"context"
 	"strconv"
 
 	"github.com/hubert-heijkers/GoTHINK2020/builder/northwind"
@@ -22,20 +21,13 @@ type employeeDimension struct {
 	generationElements  [5]*tm1.Element
 }
 
-func (d *employeeDimension) processResponse(responseBody []byte) (int, string) {
-	// Unmarshal the JSON response
-	res := northwind.EmployeeCollectionResponse{}
-	err := json.Unmarshal(responseBody, &res)
-	if err != nil {
-		log.Fatal(err)
-	}
-
+func (d *employeeDimension) processEmployees(employees []northwind.Employee) error {
 	// Process the collection of employees returned by the data source
 	// PRESUMPTIONS:
 	//  - NO DUPLICATE REGION NAMES (WHICH WORKS IN THIS EXAMPLE;-)
 	//  - REGIONS CAN BE EMPTY IN WHICH CASE CITIES RESIDE UNDER COUNTRY
 	//  - THERE ARE, FORTUNATELY FOR THIS EXAMPLE, NO DUPLICATE CITY NAMES EITHER
-	for _, employee := range res.Employees {
+	for _, employee := range employees {
 		// Geography hierarchy
 		if d.countryElement == nil || d.countryElement.Name != employee.Country {
 			d.countryElement = d.geographyHierarchy.AddElement(employee.Country, "")
@@ -74,6 +66,14 @@ func (d *employeeDimension) processResponse(responseBody []byte) (int, string) {
 			}
 		}
 		employeeElement := d.geographyHierarchy.AddElement(strconv.Itoa(employee.ID), employee.LastName+", "+employee.FirstName)
+		err := d.geographyHierarchy.SetStringAttribute(employeeElement.Name, "Title", employee.Title)
+		if err != nil {
+			return err
+		}
+		err = d.geographyHierarchy.SetStringAttribute(employeeElement.Name, "HireDate", employee.HireDate.Format("2006-01-02"))
+		if err != nil {
+			return err
+		}
 		d.geographyHierarchy.AddEdge(d.cityElement.Name, employeeElement.Name)
 
 		// Generation hierarchy
@@ -89,12 +89,11 @@ func (d *employeeDimension) processResponse(responseBody []byte) (int, string) {
 		}
 	}
 
-	// Return the nextLink, if there is one
-	return res.Count, res.NextLink
+	return nil
 }
 
 // GenerateEmployeeDimension generates, based on the data from the northwind database, the dimension definition for the employees dimension
-func GenerateEmployeeDimension(client *odata.Client, datasourceServiceRootURL string, name string) *tm1.Dimension {
+func GenerateEmployeeDimension(client *odata.Client, datasourceServiceRootURL string, name string) (*tm1.Dimension, error) {
 	dimEmployees := &employeeDimension{
 		name:      name,
 		dimension: tm1.CreateDimension(name),
@@ -102,6 +101,8 @@ func GenerateEmployeeDimension(client *odata.Client, datasourceServiceRootURL st
 	// Note, a more logical name for the geography hierarchy might be something like, well, 'Geography' but we'll
 	// use 'Employee', same name as the dimension, for backwards compatibility, in this case with Architect.
 	dimEmployees.geographyHierarchy = dimEmployees.dimension.AddHierarchy(name)
+	dimEmployees.geographyHierarchy.AddAttribute("Title", tm1.AttributeString)
+	dimEmployees.geographyHierarchy.AddAttribute("HireDate", tm1.AttributeString)
 	dimEmployees.allGeographyElement = dimEmployees.geographyHierarchy.AddElement("All", "All Geographies")
 	dimEmployees.generationHierarchy = dimEmployees.dimension.AddHierarchy("Generation")
 	allGenerationsElement := dimEmployees.generationHierarchy.AddElement("All", "All Generations")
@@ -118,6 +119,9 @@ func GenerateEmployeeDimension(client *odata.Client, datasourceServiceRootURL st
 		dimEmployees.generationHierarchy.AddEdge(allGenerationsElement.Name, dimEmployees.generationElements[4].Name)
 	*/
 
-	client.IterateCollection(datasourceServiceRootURL, "Employees?$select=EmployeeID,LastName,FirstName,TitleOfCourtesy,City,Region,Country,BirthDate&$orderby=Country%20asc,Region%20asc,City%20asc", dimEmployees.processResponse)
-	return dimEmployees.dimension
+	err := odata.IteratePages(context.Background(), client, datasourceServiceRootURL, odata.NewQuery("Employees").Select("EmployeeID", "LastName", "FirstName", "TitleOfCourtesy", "City", "Region", "Country", "BirthDate", "Title", "HireDate").OrderBy("Country", "Region", "City").String(), nil, dimEmployees.processEmployees)
+	if err != nil {
+		return nil, err
+	}
+	return dimEmployees.dimension, nil
 }
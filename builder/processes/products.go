@@ -1,8 +1,7 @@
 package processes
 
 import (
-	"encoding/json"
-	"log"
+	"context"
 	"strconv"
 
 	"github.com/hubert-heijkers/GoTHINK2020/builder/northwind"
@@ -18,14 +17,7 @@ type productDimension struct {
 	categoryElement *tm1.Element
 }
 
-func (d *productDimension) ProcessResponse(responseBody []byte) (int, string) {
-	// Unmarshal the JSON response
-	res := northwind.CategoryCollectionResponse{}
-	err := json.Unmarshal(responseBody, &res)
-	if err != nil {
-		log.Fatal(err)
-	}
-
+func (d *productDimension) processCategories(categories []northwind.Category) error {
 	// Process the collection of products, by category, returned by the data source
 	// PRESUMPTIONS:
 	//  - NO DUPLICATE CATEGORY OR PRODUCT IDS
@@ -35,9 +27,12 @@ func (d *productDimension) ProcessResponse(responseBody []byte) (int, string) {
 			d.dimension = tm1.CreateDimension(d.name)
 		}
 		d.hierarchy = d.dimension.AddHierarchy(d.name)
+		d.hierarchy.AddAttribute("QuantityPerUnit", tm1.AttributeString)
+		d.hierarchy.AddAttribute("UnitPrice", tm1.AttributeNumeric)
+		d.hierarchy.AddAttribute("Discontinued", tm1.AttributeNumeric)
 		d.allElement = d.hierarchy.AddElement("All", "All Products")
 	}
-	for _, category := range res.Categories {
+	for _, category := range categories {
 		if d.categoryElement == nil || d.categoryElement.Name != "C-"+category.Name {
 			d.categoryElement = d.hierarchy.AddElement("C-"+strconv.Itoa(category.ID), category.Name)
 			d.hierarchy.AddEdge(d.allElement.Name, d.categoryElement.Name)
@@ -45,16 +40,35 @@ func (d *productDimension) ProcessResponse(responseBody []byte) (int, string) {
 		for _, product := range category.Products {
 			productElement := d.hierarchy.AddElement("P-"+strconv.Itoa(product.ID), product.Name)
 			d.hierarchy.AddEdge(d.categoryElement.Name, productElement.Name)
+			err := d.hierarchy.SetStringAttribute(productElement.Name, "QuantityPerUnit", product.QuantityPerUnit)
+			if err != nil {
+				return err
+			}
+			err = d.hierarchy.SetNumericAttribute(productElement.Name, "UnitPrice", product.UnitPrice)
+			if err != nil {
+				return err
+			}
+			// Numeric attributes double as flags, 1 meaning the product is discontinued
+			discontinued := 0.0
+			if product.Discontinued == true {
+				discontinued = 1
+			}
+			err = d.hierarchy.SetNumericAttribute(productElement.Name, "Discontinued", discontinued)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
-	// Return the nextLink, if there is one
-	return res.Count, res.NextLink
+	return nil
 }
 
 // GenerateProductDimension generates, based on the data from the northwind database, the dimension definition for the products dimension
-func GenerateProductDimension(client *odata.Client, datasourceServiceRootURL string, name string) *tm1.Dimension {
+func GenerateProductDimension(client *odata.Client, datasourceServiceRootURL string, name string) (*tm1.Dimension, error) {
 	dimProducts := &productDimension{name: name}
-	client.IterateCollection(datasourceServiceRootURL, "Categories?$select=CategoryID,CategoryName&$orderby=CategoryName&$expand=Products($select=ProductID,ProductName;$orderby=ProductName)", dimProducts.ProcessResponse)
-	return dimProducts.dimension
+	err := odata.IteratePages(context.Background(), client, datasourceServiceRootURL, odata.NewQuery("Categories").Select("CategoryID", "CategoryName").OrderBy("CategoryName").Expand("Products", odata.NewQuery("").Select("ProductID", "ProductName", "QuantityPerUnit", "UnitPrice", "Discontinued").OrderBy("ProductName")).String(), nil, dimProducts.processCategories)
+	if err != nil {
+		return nil, err
+	}
+	return dimProducts.dimension, nil
 }
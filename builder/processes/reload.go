@@ -0,0 +1,46 @@
+package processes
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/tm1"
+)
+
+// The names of the processes and chore making up the nightly reload
+const (
+	clearProcessName  = "Northwind.Clear"
+	reloadProcessName = "Northwind.Reload"
+	reloadChoreName   = "Northwind Nightly Reload"
+)
+
+// GenerateNightlyReload generates the definitions of the processes, and the chore executing them every night at
+// 2AM, reloading the cube with the data from the northwind database. The first process clears the cube, the second
+// one runs the command, typically the loader, that loads the data into the cube again.
+// Note: the chore runs in multiple commit mode, committing the cleared cube before the command starts loading the
+// data into it, as the command would otherwise be waiting for the locks held by the chore.
+func GenerateNightlyReload(cube string, command string) ([]*tm1.Process, *tm1.Chore) {
+	noDataSource := json.RawMessage(`{"Type":"None"}`)
+	clearProcess := &tm1.Process{
+		Name:            clearProcessName,
+		PrologProcedure: "CubeClearData('" + strings.ReplaceAll(cube, "'", "''") + "');",
+		DataSource:      noDataSource,
+	}
+	reloadProcess := &tm1.Process{
+		Name:            reloadProcessName,
+		PrologProcedure: "ExecuteCommand(pCommand, 1);",
+		DataSource:      noDataSource,
+		Parameters:      []tm1.ProcessParameter{{Name: "pCommand", Prompt: "The command loading the data", Value: command, Type: "String"}},
+	}
+
+	// Schedule the chore to run every night at 2AM local time. The start time is fixed, in the past, rather than
+	// derived from the current time, so the definition of the chore doesn't change every time it is generated.
+	chore := tm1.CreateChore(reloadChoreName, time.Date(2020, time.January, 1, 2, 0, 0, 0, time.Local), 24*time.Hour)
+	chore.ExecutionMode = tm1.ChoreMultipleCommit
+	chore.DSTSensitive = true
+	chore.Active = true
+	chore.AddTask(clearProcess.Name)
+	chore.AddTask(reloadProcess.Name, tm1.ProcessParameter{Name: "pCommand", Value: command})
+	return []*tm1.Process{clearProcess, reloadProcess}, chore
+}
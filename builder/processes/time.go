@@ -1,10 +1,9 @@
 package processes
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"strconv"
 	"time"
 
@@ -14,35 +13,27 @@ import (
 )
 
 // GenerateTimeDimension generates, based on the data from the northwind database, the dimension definition for the time dimension
-func GenerateTimeDimension(client *odata.Client, datasourceServiceRootURL string, name string) *tm1.Dimension {
+func GenerateTimeDimension(client *odata.Client, datasourceServiceRootURL string, name string) (*tm1.Dimension, error) {
 
 	// Grab the orderdate of the FIRST order, by order data, in the system
-	resp := client.ExecuteGETRequest(datasourceServiceRootURL + "Orders?$select=OrderDate&$orderby=OrderDate%20asc&$top=1")
-	odata.ValidateStatusCode(resp, 200, func() string {
-		return "Failed to retrieve first order date."
-	})
-	defer resp.Body.Close()
-	responseBody, _ := ioutil.ReadAll(resp.Body)
-	res := northwind.OrderCollectionResponse{}
-	err := json.Unmarshal(responseBody, &res)
+	res, err := odata.GetCollection[northwind.Order](context.Background(), client, datasourceServiceRootURL+odata.NewQuery("Orders").Select("OrderDate").OrderBy("OrderDate").Top(1).String())
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	tmBegin := res.Orders[0].Date
+	if len(res.Value) == 0 {
+		return nil, errors.New("no orders to derive the time dimension from")
+	}
+	tmBegin := res.Value[0].Date
 
 	// Grab the orderdate of the LAST order, by order data, in the system
-	resp = client.ExecuteGETRequest(datasourceServiceRootURL + "Orders?$select=OrderDate&$orderby=OrderDate%20desc&$top=1")
-	odata.ValidateStatusCode(resp, 200, func() string {
-		return "Failed to retrieve last order date."
-	})
-	defer resp.Body.Close()
-	responseBody, _ = ioutil.ReadAll(resp.Body)
-	res = northwind.OrderCollectionResponse{}
-	err = json.Unmarshal(responseBody, &res)
+	res, err = odata.GetCollection[northwind.Order](context.Background(), client, datasourceServiceRootURL+odata.NewQuery("Orders").Select("OrderDate").OrderByDesc("OrderDate").Top(1).String())
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	if len(res.Value) == 0 {
+		return nil, errors.New("no orders to derive the time dimension from")
 	}
-	tmEnd := res.Orders[0].Date
+	tmEnd := res.Value[0].Date
 
 	// Show the order date range we are going to use to create the time dimension
 	fmt.Println("Order date range:", tmBegin.Format(time.ANSIC), "-", tmEnd.Format(time.ANSIC))
@@ -87,6 +78,27 @@ func GenerateTimeDimension(client *odata.Client, datasourceServiceRootURL string
 		hierarchy.AddEdge(monthElement.Name, dayElement.Name)
 	}
 
+	// Localize the captions of the months and days, the only captions containing names, for the configured cultures
+	for _, culture := range Cultures {
+		names, err := calendarNamesFor(culture)
+		if err != nil {
+			return nil, err
+		}
+		for iTm := tmBegin; iTm.After(tmEnd) == false; iTm = iTm.AddDate(0, 0, 1) {
+			month := names.month(iTm.Month())
+			if iTm.Day() == 1 || iTm.Equal(tmBegin) {
+				err = hierarchy.SetLocalizedCaption(fmt.Sprintf("%02d-%04d", int(iTm.Month()), iTm.Year()), culture, fmt.Sprintf("%s %04d", abbreviate(month), iTm.Year()))
+				if err != nil {
+					return nil, err
+				}
+			}
+			err = hierarchy.SetLocalizedCaption(fmt.Sprintf("%02d-%02d-%04d", iTm.Day(), int(iTm.Month()), iTm.Year()), culture, fmt.Sprintf("%s %s %2d %04d", abbreviate(names.weekday(iTm.Weekday())), abbreviate(month), iTm.Day(), iTm.Year()))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// Now lets add year, quarter and month hierarchies
 	// Years
 	yearHierarchy := dimension.AddHierarchy("Years")
@@ -109,6 +121,19 @@ func GenerateTimeDimension(client *odata.Client, datasourceServiceRootURL string
 		monthElements[i] = monthHierarchy.AddElement(time.Month(i+1).String(), "")
 		monthHierarchy.AddEdge(allMonthsElement.Name, monthElements[i].Name)
 	}
+	// Localize the month names in the months hierarchy as well
+	for _, culture := range Cultures {
+		names, err := calendarNamesFor(culture)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < 12; i++ {
+			err = monthHierarchy.SetLocalizedCaption(monthElements[i].Name, culture, names.month(time.Month(i+1)))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	year = tmBegin.Year() - 1
 	// Create elements for every day in the range from the first till last day we have data for
@@ -126,5 +151,5 @@ func GenerateTimeDimension(client *odata.Client, datasourceServiceRootURL string
 		monthHierarchy.AddEdge(monthElements[month-1].Name, monthHierarchy.AddElement(dayName, "").Name)
 	}
 
-	return dimension
+	return dimension, nil
 }
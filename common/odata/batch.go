@@ -0,0 +1,184 @@
+package odata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// BatchRequest defines the structure of a single request in an OData JSON batch request
+type BatchRequest struct {
+	ID             string            `json:"id"`
+	Method         string            `json:"method"`
+	URL            string            `json:"url"`
+	AtomicityGroup string            `json:"atomicityGroup,omitempty"`
+	DependsOn      []string          `json:"dependsOn,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           json.RawMessage   `json:"body,omitempty"`
+}
+
+// BatchResponse defines the structure of a single response in an OData JSON batch response
+type BatchResponse struct {
+	ID             string            `json:"id"`
+	Status         int               `json:"status"`
+	AtomicityGroup string            `json:"atomicityGroup,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           json.RawMessage   `json:"body,omitempty"`
+}
+
+// Batch collects a set of requests to be executed, by ExecuteBatch, in a single round trip. Requests added to the
+// same change set form an atomicity group, which the service executes as a whole or not at all.
+type Batch struct {
+	Requests []*BatchRequest `json:"requests"`
+	groups   int
+}
+
+// ChangeSet represents an atomicity group in a batch
+type ChangeSet struct {
+	batch      *Batch
+	id         string
+	sequential bool
+	last       string
+}
+
+// BatchResult holds the responses to a batch request, which can be looked up by the id of the request
+type BatchResult struct {
+	URL       string
+	Responses []*BatchResponse `json:"responses"`
+	requests  map[string]*BatchRequest
+}
+
+// Add adds a request, with an optional JSON body, to the batch and returns it
+func (batch *Batch) Add(method, urlStr, body string) *BatchRequest {
+	req := &BatchRequest{
+		ID:     strconv.Itoa(len(batch.Requests) + 1),
+		Method: method,
+		URL:    urlStr,
+	}
+	if body != "" {
+		req.Body = json.RawMessage(body)
+		req.Headers = map[string]string{"Content-Type": "application/json"}
+	}
+	batch.Requests = append(batch.Requests, req)
+	return req
+}
+
+// ChangeSet creates a new, empty, atomicity group in the batch. The requests in the group don't depend on each
+// other, use After to make a request wait for another one, or use a SequentialChangeSet instead.
+func (batch *Batch) ChangeSet() *ChangeSet {
+	batch.groups++
+	return &ChangeSet{batch: batch, id: "g" + strconv.Itoa(batch.groups)}
+}
+
+// SequentialChangeSet creates a new, empty, atomicity group in the batch, in which the requests are executed in the
+// order they were added as every request depends on the one added before it
+func (batch *Batch) SequentialChangeSet() *ChangeSet {
+	changeSet := batch.ChangeSet()
+	changeSet.sequential = true
+	return changeSet
+}
+
+// Add adds a request, with an optional JSON body, to the change set and returns it
+func (changeSet *ChangeSet) Add(method, urlStr, body string) *BatchRequest {
+	req := changeSet.batch.Add(method, urlStr, body)
+	req.AtomicityGroup = changeSet.id
+	if changeSet.sequential == true && changeSet.last != "" {
+		req.DependsOn = []string{changeSet.last}
+	}
+	changeSet.last = req.ID
+	return req
+}
+
+// After makes the request wait for the passed requests, which have to be part of the same batch, to succeed before
+// it gets executed
+func (req *BatchRequest) After(reqs ...*BatchRequest) *BatchRequest {
+	for _, other := range reqs {
+		req.DependsOn = append(req.DependsOn, other.ID)
+	}
+	return req
+}
+
+// ExecuteBatch sends the batch to the service's $batch endpoint and returns the responses to the individual requests
+func (client *Client) ExecuteBatch(ctx context.Context, serviceRootURL string, batch *Batch) (*BatchResult, error) {
+	jBatch, err := json.Marshal(batch)
+	if err != nil {
+		return nil, err
+	}
+	// JSON batch requests were introduced with OData 4.01, let the service know we speak that version
+	resp, err := client.ExecutePOSTRequestExContext(ctx, serviceRootURL+"$batch", "application/json", string(jBatch), func(req *http.Request) {
+		req.Header.Set("OData-MaxVersion", "4.01")
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = ValidateStatusCode(resp, 200, func() string {
+		return "Failed to execute batch"
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &ResponseError{URL: serviceRootURL + "$batch", Err: err}
+	}
+	result := &BatchResult{URL: serviceRootURL, requests: make(map[string]*BatchRequest)}
+	if err = json.Unmarshal(body, result); err != nil {
+		return nil, &ResponseError{URL: serviceRootURL + "$batch", Err: err}
+	}
+	for _, req := range batch.Requests {
+		result.requests[req.ID] = req
+	}
+	return result, nil
+}
+
+// Response returns the response to the request with the passed id, or nil if the service didn't return one
+func (result *BatchResult) Response(id string) *BatchResponse {
+	for _, resp := range result.Responses {
+		if resp.ID == id {
+			return resp
+		}
+	}
+	return nil
+}
+
+// Validate validates that the response to the passed request has the expected status code and returns a
+// *StatusError, like ValidateStatusCode, if it doesn't
+func (result *BatchResult) Validate(req *BatchRequest, statusCode int, logFmt func() string) error {
+	resp := result.Response(req.ID)
+	statusErr := &StatusError{Method: req.Method, URL: result.URL + req.URL}
+	if logFmt != nil {
+		statusErr.Message = logFmt()
+	}
+	if resp == nil {
+		statusErr.Body = []byte("no response returned for request " + req.ID)
+		return statusErr
+	}
+	if resp.Status == statusCode {
+		return nil
+	}
+	statusErr.StatusCode = resp.Status
+	statusErr.Body = resp.Body
+	statusErr.ODataError = parseErrorBody(resp.Body)
+	return statusErr
+}
+
+// Err returns an error, joining the errors for every failed request, if any of the requests in the batch failed
+func (result *BatchResult) Err() error {
+	var errs []error
+	for _, resp := range result.Responses {
+		if resp.Status >= 200 && resp.Status < 300 {
+			continue
+		}
+		statusErr := &StatusError{StatusCode: resp.Status, Body: resp.Body, ODataError: parseErrorBody(resp.Body)}
+		if req := result.requests[resp.ID]; req != nil {
+			statusErr.Method = req.Method
+			statusErr.URL = result.URL + req.URL
+		}
+		errs = append(errs, statusErr)
+	}
+	return errors.Join(errs...)
+}
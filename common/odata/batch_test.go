@@ -0,0 +1,129 @@
+package odata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestBatchSerialization(t *testing.T) {
+	batch := &Batch{}
+	batch.Add("GET", "Cubes('Sales')", "")
+	first := batch.SequentialChangeSet()
+	first.Add("POST", "Dimensions", `{"Name":"Products"}`)
+	first.Add("PATCH", "Dimensions('Products')", `{"Name":"Products"}`)
+	second := batch.ChangeSet()
+	create := second.Add("POST", "Cubes", `{"Name":"Sales"}`)
+	second.Add("POST", "Cubes('Sales')/tm1.Update", `[]`).After(create)
+
+	jBatch, err := json.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"requests":[` +
+		`{"id":"1","method":"GET","url":"Cubes('Sales')"},` +
+		`{"id":"2","method":"POST","url":"Dimensions","atomicityGroup":"g1","headers":{"Content-Type":"application/json"},"body":{"Name":"Products"}},` +
+		`{"id":"3","method":"PATCH","url":"Dimensions('Products')","atomicityGroup":"g1","dependsOn":["2"],"headers":{"Content-Type":"application/json"},"body":{"Name":"Products"}},` +
+		`{"id":"4","method":"POST","url":"Cubes","atomicityGroup":"g2","headers":{"Content-Type":"application/json"},"body":{"Name":"Sales"}},` +
+		`{"id":"5","method":"POST","url":"Cubes('Sales')/tm1.Update","atomicityGroup":"g2","dependsOn":["4"],"headers":{"Content-Type":"application/json"},"body":[]}` +
+		`]}`
+	if string(jBatch) != want {
+		t.Errorf("got\n%s\nwant\n%s", jBatch, want)
+	}
+}
+
+func TestBatchChangeSetIDsAreUnique(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(batch *Batch) []*ChangeSet
+	}{
+		{"change sets only", func(batch *Batch) []*ChangeSet {
+			return []*ChangeSet{batch.ChangeSet(), batch.ChangeSet(), batch.SequentialChangeSet()}
+		}},
+		{"requests added in between", func(batch *Batch) []*ChangeSet {
+			first := batch.ChangeSet()
+			first.Add("POST", "Dimensions", "")
+			batch.Add("GET", "Dimensions", "")
+			batch.Add("GET", "Cubes", "")
+			second := batch.ChangeSet()
+			second.Add("POST", "Cubes", "")
+			return []*ChangeSet{first, second, batch.ChangeSet()}
+		}},
+	}
+	for _, test := range tests {
+		seen := make(map[string]bool)
+		for _, changeSet := range test.build(&Batch{}) {
+			if seen[changeSet.id] {
+				t.Errorf("%s: change set id '%s' is used more than once", test.name, changeSet.id)
+			}
+			seen[changeSet.id] = true
+		}
+	}
+}
+
+func TestChangeSetDependencies(t *testing.T) {
+	batch := &Batch{}
+	independent := batch.ChangeSet()
+	a := independent.Add("DELETE", "Cubes('A')", "")
+	b := independent.Add("DELETE", "Cubes('B')", "")
+	sequential := batch.SequentialChangeSet()
+	c := sequential.Add("DELETE", "Cubes('C')", "")
+	d := sequential.Add("DELETE", "Cubes('D')", "")
+
+	tests := []struct {
+		name string
+		req  *BatchRequest
+		want []string
+	}{
+		{"first of independent change set", a, nil},
+		{"second of independent change set", b, nil},
+		{"first of sequential change set", c, nil},
+		{"second of sequential change set", d, []string{c.ID}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.req.DependsOn, test.want) {
+			t.Errorf("%s: depends on %v, want %v", test.name, test.req.DependsOn, test.want)
+		}
+	}
+}
+
+func TestExecuteBatch(t *testing.T) {
+	quiet(t)
+	var maxVersion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maxVersion = r.Header.Get("OData-MaxVersion")
+		if r.URL.Path != "/$batch" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"responses":[{"id":"1","status":201},{"id":"2","status":400,"body":{"error":{"code":"278","message":"Invalid element"}}}]}`)
+	}))
+	defer server.Close()
+
+	batch := &Batch{}
+	changeSet := batch.ChangeSet()
+	create := changeSet.Add("POST", "Dimensions", `{"Name":"Products"}`)
+	update := changeSet.Add("POST", "Cubes('Sales')/tm1.Update", `[]`)
+	result, err := (&Client{}).ExecuteBatch(context.Background(), server.URL+"/", batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxVersion != "4.01" {
+		t.Errorf("got OData-MaxVersion %q, want 4.01", maxVersion)
+	}
+	if err := result.Validate(create, 201, nil); err != nil {
+		t.Errorf("create: %v", err)
+	}
+	var statusErr *StatusError
+	if err := result.Validate(update, 204, nil); !errors.As(err, &statusErr) || statusErr.StatusCode != 400 || statusErr.URL != server.URL+"/Cubes('Sales')/tm1.Update" {
+		t.Errorf("update: got %v, want a status error for the update", err)
+	}
+	if err := result.Err(); !errors.As(err, &statusErr) || statusErr.StatusCode != 400 {
+		t.Errorf("got %v, want the failed update", err)
+	}
+}
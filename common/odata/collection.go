@@ -0,0 +1,112 @@
+package odata
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+// CollectionResponse defines the structure of an odata compliant response wrapping a collection of entities of type T
+type CollectionResponse[T any] struct {
+	Context   string `json:"@odata.context"`
+	Count     int    `json:"@odata.count"`
+	Value     []T    `json:"value"`
+	NextLink  string `json:"@odata.nextLink"`
+	DeltaLink string `json:"@odata.deltaLink"`
+}
+
+// GetCollection retrieves the collection, or at least the first page of it if the service decided to page the
+// result, in a single request and returns the typed response
+func GetCollection[T any](ctx context.Context, client *Client, urlStr string) (*CollectionResponse[T], error) {
+	resp, err := client.ExecuteGETRequestContext(ctx, urlStr)
+	if err != nil {
+		return nil, err
+	}
+	err = ValidateStatusCode(resp, 200, func() string {
+		return "Failed to retrieve collection"
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &ResponseError{URL: urlStr, Err: err}
+	}
+	res := &CollectionResponse[T]{}
+	if err = json.Unmarshal(body, res); err != nil {
+		return nil, &ResponseError{URL: urlStr, Err: err}
+	}
+	return res, nil
+}
+
+// GetEntity retrieves the single entity, or the single valued result of a function, addressed by the URL and
+// returns it typed
+func GetEntity[T any](ctx context.Context, client *Client, urlStr string) (*T, error) {
+	resp, err := client.ExecuteGETRequestContext(ctx, urlStr)
+	if err != nil {
+		return nil, err
+	}
+	err = ValidateStatusCode(resp, 200, func() string {
+		return "Failed to retrieve entity"
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &ResponseError{URL: urlStr, Err: err}
+	}
+	res := new(T)
+	if err = json.Unmarshal(body, res); err != nil {
+		return nil, &ResponseError{URL: urlStr, Err: err}
+	}
+	return res, nil
+}
+
+// IteratePages iterates the collection, using the passed paging strategy or, if nil, the same client driven
+// paging IterateCollection uses, and calls processEntities for every page with the typed entities in that page
+func IteratePages[T any](ctx context.Context, client *Client, serviceRootURL string, urlStr string, paging PagingStrategy, processEntities func([]T) error) error {
+	if paging == nil {
+		paging = SkipTopPaging{PageSize: 10}
+	}
+	return client.IterateCollectionPagedContext(ctx, serviceRootURL, urlStr, paging, func(body []byte) (int, string, error) {
+		res := CollectionResponse[T]{}
+		if err := json.Unmarshal(body, &res); err != nil {
+			return 0, "", err
+		}
+		if err := processEntities(res.Value); err != nil {
+			return 0, "", err
+		}
+		return res.Count, res.NextLink, nil
+	})
+}
+
+// IterateEntities iterates the collection, like IteratePages, but calls processEntity for every single entity
+func IterateEntities[T any](ctx context.Context, client *Client, serviceRootURL string, urlStr string, paging PagingStrategy, processEntity func(T) error) error {
+	return IteratePages(ctx, client, serviceRootURL, urlStr, paging, func(entities []T) error {
+		for _, entity := range entities {
+			if err := processEntity(entity); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TrackPages tracks the collection, like TrackCollectionContext, and calls processEntities for every page, or
+// delta, with the typed entities in that page
+func TrackPages[T any](ctx context.Context, client *Client, serviceRootURL string, urlStr string, interval time.Duration, processEntities func([]T) error) error {
+	return client.TrackCollectionContext(ctx, serviceRootURL, urlStr, interval, func(body []byte) (string, string, error) {
+		res := CollectionResponse[T]{}
+		if err := json.Unmarshal(body, &res); err != nil {
+			return "", "", err
+		}
+		if err := processEntities(res.Value); err != nil {
+			return "", "", err
+		}
+		return res.NextLink, res.DeltaLink, nil
+	})
+}
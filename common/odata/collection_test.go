@@ -0,0 +1,154 @@
+package odata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+type testProduct struct {
+	ProductID   int
+	ProductName string
+}
+
+// newProductsServer returns a server serving a collection of count products, paged using $skip and $top
+func newProductsServer(t *testing.T, count int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		skip, _ := strconv.Atoi(r.URL.Query().Get("$skip"))
+		top, err := strconv.Atoi(r.URL.Query().Get("$top"))
+		if err != nil {
+			top = count
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"@odata.context":"$metadata#Products","@odata.count":%d,"value":[`, count)
+		for i := skip; i < skip+top && i < count; i++ {
+			if i > skip {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"ProductID":%d,"ProductName":"Product %d"}`, i+1, i+1)
+		}
+		fmt.Fprint(w, "]}")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// quiet turns off verbose logging for the duration of the test
+func quiet(t *testing.T) {
+	verbose := Verbose
+	Verbose = false
+	t.Cleanup(func() { Verbose = verbose })
+}
+
+func TestGetCollection(t *testing.T) {
+	quiet(t)
+	server := newProductsServer(t, 3)
+	res, err := GetCollection[testProduct](context.Background(), &Client{}, server.URL+"/Products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Count != 3 || len(res.Value) != 3 {
+		t.Fatalf("got count %d and %d products, want 3 and 3", res.Count, len(res.Value))
+	}
+	if got := res.Value[2]; got.ProductID != 3 || got.ProductName != "Product 3" {
+		t.Errorf("got %+v, want product 3", got)
+	}
+}
+
+func TestGetEntity(t *testing.T) {
+	quiet(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ProductID":7,"ProductName":"Uncle Bob's Organic Dried Pears"}`)
+	}))
+	defer server.Close()
+	got, err := GetEntity[testProduct](context.Background(), &Client{}, server.URL+"/Products(7)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ProductID != 7 || got.ProductName != "Uncle Bob's Organic Dried Pears" {
+		t.Errorf("got %+v, want product 7", got)
+	}
+}
+
+func TestGetEntityStatusError(t *testing.T) {
+	quiet(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	if _, err := GetEntity[testProduct](context.Background(), &Client{}, server.URL+"/Products(0)"); !IsNotFound(err) {
+		t.Errorf("got %v, want a not found error", err)
+	}
+}
+
+func TestIteratePages(t *testing.T) {
+	quiet(t)
+	tests := []struct {
+		name   string
+		count  int
+		paging PagingStrategy
+		pages  int
+	}{
+		{"default paging", 25, nil, 3},
+		{"custom page size", 25, SkipTopPaging{PageSize: 5}, 5},
+		{"single page", 4, SkipTopPaging{PageSize: 5}, 1},
+		{"empty collection", 0, nil, 1},
+	}
+	for _, test := range tests {
+		server := newProductsServer(t, test.count)
+		pages, products := 0, 0
+		err := IteratePages(context.Background(), &Client{}, server.URL+"/", "Products", test.paging, func(entities []testProduct) error {
+			pages++
+			products += len(entities)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if pages != test.pages || products != test.count {
+			t.Errorf("%s: got %d products in %d pages, want %d in %d", test.name, products, pages, test.count, test.pages)
+		}
+	}
+}
+
+func TestIterateEntities(t *testing.T) {
+	quiet(t)
+	server := newProductsServer(t, 12)
+	var ids []int
+	err := IterateEntities(context.Background(), &Client{}, server.URL+"/", "Products", nil, func(product testProduct) error {
+		ids = append(ids, product.ProductID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range ids {
+		if id != i+1 {
+			t.Fatalf("got product ids %v, want 1 through 12 in order", ids)
+		}
+	}
+	if len(ids) != 12 {
+		t.Errorf("got %d products, want 12", len(ids))
+	}
+}
+
+func TestIterateEntitiesStopsOnError(t *testing.T) {
+	quiet(t)
+	server := newProductsServer(t, 12)
+	stop := fmt.Errorf("stop")
+	seen := 0
+	err := IterateEntities(context.Background(), &Client{}, server.URL+"/", "Products", nil, func(product testProduct) error {
+		seen++
+		if product.ProductID == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err == nil || seen != 3 {
+		t.Errorf("got error %v after %d products, want the callback's error after 3", err, seen)
+	}
+}
@@ -0,0 +1,119 @@
+package odata
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrorDetail defines the structure of a single entry in the details of an OData error response
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Target  string `json:"target,omitempty"`
+}
+
+// ErrorBody defines the structure of the error object in an OData compliant JSON error response
+type ErrorBody struct {
+	Code       string          `json:"code"`
+	Message    string          `json:"message"`
+	Target     string          `json:"target,omitempty"`
+	Details    []ErrorDetail   `json:"details,omitempty"`
+	InnerError json.RawMessage `json:"innererror,omitempty"`
+}
+
+// errorResponse defines the structure of an OData compliant JSON error response
+type errorResponse struct {
+	Error *ErrorBody `json:"error"`
+}
+
+// RequestError is returned when a request could not be created or executed, the underlying error, typically
+// the one returned by the http package, is available through Unwrap
+type RequestError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return e.Method + " " + e.URL + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned when the service responded with a status code other than the one expected. If the
+// service returned an OData compliant error response it is available, parsed, in ODataError
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Message    string
+	Body       []byte
+	ODataError *ErrorBody
+}
+
+func (e *StatusError) Error() string {
+	var msg strings.Builder
+	if e.Message != "" {
+		msg.WriteString(e.Message)
+		msg.WriteString(": ")
+	}
+	msg.WriteString(e.Method)
+	msg.WriteString(" ")
+	msg.WriteString(e.URL)
+	msg.WriteString(" responded with ")
+	if e.Status != "" {
+		msg.WriteString(e.Status)
+	} else {
+		msg.WriteString(strconv.Itoa(e.StatusCode))
+	}
+	if e.ODataError != nil {
+		if e.ODataError.Code != "" {
+			msg.WriteString(" [")
+			msg.WriteString(e.ODataError.Code)
+			msg.WriteString("]")
+		}
+		msg.WriteString(" ")
+		msg.WriteString(e.ODataError.Message)
+	} else if len(e.Body) > 0 {
+		msg.WriteString(" ")
+		msg.WriteString(string(e.Body))
+	}
+	return msg.String()
+}
+
+// IsNotFound reports whether the error is, or wraps, a *StatusError for a 404 Not Found response
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// ResponseError is returned when the response of the service could not be read or processed
+type ResponseError struct {
+	URL string
+	Err error
+}
+
+func (e *ResponseError) Error() string {
+	return "processing response of " + e.URL + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// parseErrorBody attempts to parse the passed response body as an OData error response
+func parseErrorBody(body []byte) *ErrorBody {
+	res := errorResponse{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil
+	}
+	return res.Error
+}
@@ -0,0 +1,969 @@
+package odata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Metadata is the in-memory model of a service's metadata document, the CSDL, as served at $metadata
+type Metadata struct {
+	Version string
+	Schemas []*Schema
+}
+
+// Schema defines the structure of a single schema in the metadata document
+type Schema struct {
+	Namespace       string
+	Alias           string
+	EntityTypes     []*EntityType
+	ComplexTypes    []*ComplexType
+	EnumTypes       []*EnumType
+	Actions         []*Operation
+	Functions       []*Operation
+	EntityContainer *EntityContainer
+}
+
+// StructuredType holds what entity and complex types have in common
+type StructuredType struct {
+	Name                 string
+	BaseType             string
+	Abstract             bool
+	OpenType             bool
+	Properties           []*Property
+	NavigationProperties []*NavigationProperty
+}
+
+// EntityType defines the structure of an entity type
+type EntityType struct {
+	StructuredType
+	Key       []string
+	HasStream bool
+}
+
+// ComplexType defines the structure of a complex type
+type ComplexType struct {
+	StructuredType
+}
+
+// Property defines the structure of a structural property
+type Property struct {
+	Name         string
+	Type         string
+	Nullable     bool
+	MaxLength    string
+	Precision    string
+	Scale        string
+	DefaultValue string
+}
+
+// NavigationProperty defines the structure of a navigation property
+type NavigationProperty struct {
+	Name           string
+	Type           string
+	Nullable       bool
+	Partner        string
+	ContainsTarget bool
+}
+
+// EnumType defines the structure of an enumeration type
+type EnumType struct {
+	Name           string
+	UnderlyingType string
+	IsFlags        bool
+	Members        []*EnumMember
+}
+
+// EnumMember defines the structure of a single member of an enumeration type
+type EnumMember struct {
+	Name  string
+	Value string
+}
+
+// Operation defines the structure of an action or function
+type Operation struct {
+	Name          string
+	IsBound       bool
+	IsComposable  bool
+	EntitySetPath string
+	Parameters    []*Parameter
+	ReturnType    *ReturnType
+}
+
+// Parameter defines the structure of a single parameter of an action or function
+type Parameter struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+// ReturnType defines the structure of the return type of an action or function
+type ReturnType struct {
+	Type     string
+	Nullable bool
+}
+
+// EntityContainer defines the structure of the entity container, the entry points, of a service
+type EntityContainer struct {
+	Name            string
+	EntitySets      []*EntitySet
+	Singletons      []*Singleton
+	ActionImports   []*OperationImport
+	FunctionImports []*OperationImport
+}
+
+// EntitySet defines the structure of an entity set
+type EntitySet struct {
+	Name                       string
+	EntityType                 string
+	NavigationPropertyBindings []*NavigationPropertyBinding
+}
+
+// Singleton defines the structure of a singleton
+type Singleton struct {
+	Name                       string
+	Type                       string
+	NavigationPropertyBindings []*NavigationPropertyBinding
+}
+
+// NavigationPropertyBinding defines the structure of a navigation property binding
+type NavigationPropertyBinding struct {
+	Path   string
+	Target string
+}
+
+// OperationImport defines the structure of an action or function import, in which case Operation refers to the
+// action or function respectively
+type OperationImport struct {
+	Name      string
+	Operation string
+	EntitySet string
+}
+
+// GetMetadata retrieves and parses the metadata document of the service
+func (client *Client) GetMetadata(ctx context.Context, serviceRootURL string) (*Metadata, error) {
+	resp, err := client.ExecuteGETRequestExContext(ctx, serviceRootURL+"$metadata", func(req *http.Request) {
+		req.Header.Set("Accept", "application/xml, application/json")
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = ValidateStatusCode(resp, 200, func() string {
+		return "Failed to retrieve metadata"
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &ResponseError{URL: serviceRootURL + "$metadata", Err: err}
+	}
+	metadata, err := ParseMetadata(body)
+	if err != nil {
+		return nil, &ResponseError{URL: serviceRootURL + "$metadata", Err: err}
+	}
+	return metadata, nil
+}
+
+// ParseMetadata parses a metadata document in either the CSDL XML or CSDL JSON format
+func ParseMetadata(data []byte) (*Metadata, error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("<")):
+		return parseMetadataXML(data)
+	case bytes.HasPrefix(data, []byte("{")):
+		return parseMetadataJSON(data)
+	}
+	return nil, errors.New("odata: metadata document is neither CSDL XML nor CSDL JSON")
+}
+
+// Schema returns the schema with the passed namespace or alias, or nil if there is none
+func (metadata *Metadata) Schema(namespace string) *Schema {
+	for _, schema := range metadata.Schemas {
+		if schema.Namespace == namespace || (schema.Alias != "" && schema.Alias == namespace) {
+			return schema
+		}
+	}
+	return nil
+}
+
+// EntityContainer returns the entity container of the service, or nil if there is none
+func (metadata *Metadata) EntityContainer() *EntityContainer {
+	for _, schema := range metadata.Schemas {
+		if schema.EntityContainer != nil {
+			return schema.EntityContainer
+		}
+	}
+	return nil
+}
+
+// splitQualifiedName splits a, namespace or alias, qualified name in its schema and name
+func (metadata *Metadata) splitQualifiedName(qualifiedName string) (*Schema, string) {
+	i := strings.LastIndex(qualifiedName, ".")
+	if i < 0 {
+		return nil, qualifiedName
+	}
+	return metadata.Schema(qualifiedName[:i]), qualifiedName[i+1:]
+}
+
+// EntityType returns the entity type with the passed qualified name, or nil if there is none
+func (metadata *Metadata) EntityType(qualifiedName string) *EntityType {
+	schema, name := metadata.splitQualifiedName(qualifiedName)
+	if schema != nil {
+		for _, entityType := range schema.EntityTypes {
+			if entityType.Name == name {
+				return entityType
+			}
+		}
+	}
+	return nil
+}
+
+// ComplexType returns the complex type with the passed qualified name, or nil if there is none
+func (metadata *Metadata) ComplexType(qualifiedName string) *ComplexType {
+	schema, name := metadata.splitQualifiedName(qualifiedName)
+	if schema != nil {
+		for _, complexType := range schema.ComplexTypes {
+			if complexType.Name == name {
+				return complexType
+			}
+		}
+	}
+	return nil
+}
+
+// structuredType returns the entity or complex type with the passed qualified name, or nil if there is none
+func (metadata *Metadata) structuredType(qualifiedName string) *StructuredType {
+	if entityType := metadata.EntityType(qualifiedName); entityType != nil {
+		return &entityType.StructuredType
+	}
+	if complexType := metadata.ComplexType(qualifiedName); complexType != nil {
+		return &complexType.StructuredType
+	}
+	return nil
+}
+
+// Actions returns all overloads of the action with the passed qualified name
+func (metadata *Metadata) Actions(qualifiedName string) []*Operation {
+	schema, name := metadata.splitQualifiedName(qualifiedName)
+	if schema == nil {
+		return nil
+	}
+	return findOperations(schema.Actions, name)
+}
+
+// Functions returns all overloads of the function with the passed qualified name
+func (metadata *Metadata) Functions(qualifiedName string) []*Operation {
+	schema, name := metadata.splitQualifiedName(qualifiedName)
+	if schema == nil {
+		return nil
+	}
+	return findOperations(schema.Functions, name)
+}
+
+func findOperations(operations []*Operation, name string) []*Operation {
+	var found []*Operation
+	for _, operation := range operations {
+		if operation.Name == name {
+			found = append(found, operation)
+		}
+	}
+	return found
+}
+
+// EntitySet returns the entity set with the passed name, or nil if there is none
+func (metadata *Metadata) EntitySet(name string) *EntitySet {
+	if container := metadata.EntityContainer(); container != nil {
+		for _, entitySet := range container.EntitySets {
+			if entitySet.Name == name {
+				return entitySet
+			}
+		}
+	}
+	return nil
+}
+
+// Singleton returns the singleton with the passed name, or nil if there is none
+func (metadata *Metadata) Singleton(name string) *Singleton {
+	if container := metadata.EntityContainer(); container != nil {
+		for _, singleton := range container.Singletons {
+			if singleton.Name == name {
+				return singleton
+			}
+		}
+	}
+	return nil
+}
+
+// Property returns the structural property with the passed name, including inherited ones, or nil if there is none
+func (metadata *Metadata) Property(structuredType *StructuredType, name string) *Property {
+	for t := structuredType; t != nil; t = metadata.structuredType(t.BaseType) {
+		for _, property := range t.Properties {
+			if property.Name == name {
+				return property
+			}
+		}
+	}
+	return nil
+}
+
+// NavigationProperty returns the navigation property with the passed name, including inherited ones, or nil if
+// there is none
+func (metadata *Metadata) NavigationProperty(structuredType *StructuredType, name string) *NavigationProperty {
+	for t := structuredType; t != nil; t = metadata.structuredType(t.BaseType) {
+		for _, navigationProperty := range t.NavigationProperties {
+			if navigationProperty.Name == name {
+				return navigationProperty
+			}
+		}
+	}
+	return nil
+}
+
+// isDerivedFrom returns if the type with the passed qualified name is, or derives from, the base type
+func (metadata *Metadata) isDerivedFrom(qualifiedName, baseType string) bool {
+	target := metadata.structuredType(baseType)
+	for t := metadata.structuredType(qualifiedName); t != nil; t = metadata.structuredType(t.BaseType) {
+		if t == target {
+			return true
+		}
+	}
+	return qualifiedName == baseType
+}
+
+// collectionType returns the item type and if the passed type is a collection type
+func collectionType(typeName string) (string, bool) {
+	if strings.HasPrefix(typeName, "Collection(") && strings.HasSuffix(typeName, ")") {
+		return typeName[len("Collection(") : len(typeName)-1], true
+	}
+	return typeName, false
+}
+
+// ValidatePath validates that the resource path, relative to the service root, addresses something the service
+// exposes, for example that Cubes('Sales')/tm1.Update refers to an existing entity set and a bound action
+// applicable to a single entity of that set. Any query options are ignored.
+func (metadata *Metadata) ValidatePath(path string) error {
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	segments := splitPath(path)
+	if len(segments) == 0 || segments[0] == "" {
+		return errors.New("odata: empty resource path")
+	}
+
+	// Resolve the first segment against the entity container
+	container := metadata.EntityContainer()
+	if container == nil {
+		return errors.New("odata: metadata has no entity container")
+	}
+	name, args := splitSegment(segments[0])
+	var typeName string
+	var isCollection bool
+	if entitySet := metadata.EntitySet(name); entitySet != nil {
+		typeName, isCollection = entitySet.EntityType, args == ""
+	} else if singleton := metadata.Singleton(name); singleton != nil {
+		typeName = singleton.Type
+	} else if operationImport := findOperationImport(container.FunctionImports, name); operationImport != nil {
+		functions := metadata.Functions(operationImport.Operation)
+		if len(functions) == 0 || functions[0].ReturnType == nil {
+			return fmt.Errorf("odata: function import '%s' refers to unknown function '%s'", name, operationImport.Operation)
+		}
+		typeName, isCollection = collectionType(functions[0].ReturnType.Type)
+	} else if operationImport := findOperationImport(container.ActionImports, name); operationImport != nil {
+		if len(segments) > 1 {
+			return fmt.Errorf("odata: action import '%s' must be the last segment", name)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("odata: '%s' is not an entity set, singleton or operation import", name)
+	}
+
+	// Walk the remaining segments
+	for i := 1; i < len(segments); i++ {
+		segment := segments[i]
+		name, args = splitSegment(segment)
+		switch {
+		case name == "$value" || name == "$count" || name == "$ref":
+			if i != len(segments)-1 {
+				return fmt.Errorf("odata: '%s' must be the last segment", name)
+			}
+			return nil
+		case strings.Contains(name, "."):
+			// A qualified name is either a type cast or a bound action or function
+			if metadata.structuredType(name) != nil {
+				if !metadata.isDerivedFrom(name, typeName) {
+					return fmt.Errorf("odata: type '%s' does not derive from '%s'", name, typeName)
+				}
+				typeName = name
+				continue
+			}
+			if operation := metadata.boundOperation(metadata.Actions(name), typeName, isCollection); operation != nil {
+				if i != len(segments)-1 {
+					return fmt.Errorf("odata: action '%s' must be the last segment", name)
+				}
+				return nil
+			}
+			operation := metadata.boundOperation(metadata.Functions(name), typeName, isCollection)
+			if operation == nil {
+				return fmt.Errorf("odata: '%s' is not an action or function bound to '%s'", name, boundTypeName(typeName, isCollection))
+			}
+			if operation.ReturnType == nil {
+				return fmt.Errorf("odata: function '%s' has no return type", name)
+			}
+			typeName, isCollection = collectionType(operation.ReturnType.Type)
+		default:
+			if isCollection {
+				return fmt.Errorf("odata: '%s' cannot be applied to collection of '%s', address a single entity first", name, typeName)
+			}
+			structuredType := metadata.structuredType(typeName)
+			if structuredType == nil {
+				return fmt.Errorf("odata: '%s' cannot be applied to '%s'", name, typeName)
+			}
+			if navigationProperty := metadata.NavigationProperty(structuredType, name); navigationProperty != nil {
+				typeName, isCollection = collectionType(navigationProperty.Type)
+			} else if property := metadata.Property(structuredType, name); property != nil {
+				typeName, isCollection = collectionType(property.Type)
+			} else {
+				return fmt.Errorf("odata: '%s' is not a property of '%s'", name, typeName)
+			}
+			if args != "" {
+				if !isCollection {
+					return fmt.Errorf("odata: key specified for single valued property '%s'", name)
+				}
+				isCollection = false
+			}
+		}
+	}
+	return nil
+}
+
+// boundOperation returns the overload of the operation that is bound to the passed type, or nil if there is none
+func (metadata *Metadata) boundOperation(operations []*Operation, typeName string, isCollection bool) *Operation {
+	for _, operation := range operations {
+		if !operation.IsBound || len(operation.Parameters) == 0 {
+			continue
+		}
+		bindingType, bindingIsCollection := collectionType(operation.Parameters[0].Type)
+		if bindingIsCollection == isCollection && metadata.isDerivedFrom(typeName, bindingType) {
+			return operation
+		}
+	}
+	return nil
+}
+
+func boundTypeName(typeName string, isCollection bool) string {
+	if isCollection {
+		return "Collection(" + typeName + ")"
+	}
+	return typeName
+}
+
+func findOperationImport(operationImports []*OperationImport, name string) *OperationImport {
+	for _, operationImport := range operationImports {
+		if operationImport.Name == name {
+			return operationImport
+		}
+	}
+	return nil
+}
+
+// splitPath splits a resource path in its segments, ignoring any slashes within parentheses or string literals
+func splitPath(path string) []string {
+	var segments []string
+	depth := 0
+	inString := false
+	start := 0
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; {
+		case c == '\'':
+			inString = !inString
+		case inString:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '/' && depth == 0:
+			segments = append(segments, path[start:i])
+			start = i + 1
+		}
+	}
+	return append(segments, path[start:])
+}
+
+// splitSegment splits a path segment in its name and, if any, the key or parameters between parentheses
+func splitSegment(segment string) (string, string) {
+	if i := strings.Index(segment, "("); i >= 0 && strings.HasSuffix(segment, ")") {
+		return segment[:i], segment[i+1 : len(segment)-1]
+	}
+	return segment, ""
+}
+
+// The following types reflect the structure of the CSDL XML format and are only used for parsing it
+
+type edmxDocument struct {
+	Version string       `xml:"Version,attr"`
+	Schemas []*xmlSchema `xml:"DataServices>Schema"`
+}
+
+type xmlSchema struct {
+	Namespace       string               `xml:"Namespace,attr"`
+	Alias           string               `xml:"Alias,attr"`
+	EntityTypes     []*xmlStructuredType `xml:"EntityType"`
+	ComplexTypes    []*xmlStructuredType `xml:"ComplexType"`
+	EnumTypes       []*xmlEnumType       `xml:"EnumType"`
+	Actions         []*xmlOperation      `xml:"Action"`
+	Functions       []*xmlOperation      `xml:"Function"`
+	EntityContainer *xmlEntityContainer  `xml:"EntityContainer"`
+}
+
+type xmlStructuredType struct {
+	Name                 string           `xml:"Name,attr"`
+	BaseType             string           `xml:"BaseType,attr"`
+	Abstract             string           `xml:"Abstract,attr"`
+	OpenType             string           `xml:"OpenType,attr"`
+	HasStream            string           `xml:"HasStream,attr"`
+	Key                  []xmlPropertyRef `xml:"Key>PropertyRef"`
+	Properties           []*xmlProperty   `xml:"Property"`
+	NavigationProperties []*xmlProperty   `xml:"NavigationProperty"`
+}
+
+type xmlPropertyRef struct {
+	Name  string `xml:"Name,attr"`
+	Alias string `xml:"Alias,attr"`
+}
+
+type xmlProperty struct {
+	Name           string `xml:"Name,attr"`
+	Type           string `xml:"Type,attr"`
+	Nullable       string `xml:"Nullable,attr"`
+	MaxLength      string `xml:"MaxLength,attr"`
+	Precision      string `xml:"Precision,attr"`
+	Scale          string `xml:"Scale,attr"`
+	DefaultValue   string `xml:"DefaultValue,attr"`
+	Partner        string `xml:"Partner,attr"`
+	ContainsTarget string `xml:"ContainsTarget,attr"`
+}
+
+type xmlEnumType struct {
+	Name           string `xml:"Name,attr"`
+	UnderlyingType string `xml:"UnderlyingType,attr"`
+	IsFlags        string `xml:"IsFlags,attr"`
+	Members        []struct {
+		Name  string `xml:"Name,attr"`
+		Value string `xml:"Value,attr"`
+	} `xml:"Member"`
+}
+
+type xmlOperation struct {
+	Name          string         `xml:"Name,attr"`
+	IsBound       string         `xml:"IsBound,attr"`
+	IsComposable  string         `xml:"IsComposable,attr"`
+	EntitySetPath string         `xml:"EntitySetPath,attr"`
+	Parameters    []*xmlProperty `xml:"Parameter"`
+	ReturnType    *xmlProperty   `xml:"ReturnType"`
+}
+
+type xmlEntityContainer struct {
+	Name       string `xml:"Name,attr"`
+	EntitySets []struct {
+		Name       string                 `xml:"Name,attr"`
+		EntityType string                 `xml:"EntityType,attr"`
+		Bindings   []xmlNavigationBinding `xml:"NavigationPropertyBinding"`
+	} `xml:"EntitySet"`
+	Singletons []struct {
+		Name     string                 `xml:"Name,attr"`
+		Type     string                 `xml:"Type,attr"`
+		Bindings []xmlNavigationBinding `xml:"NavigationPropertyBinding"`
+	} `xml:"Singleton"`
+	ActionImports []struct {
+		Name      string `xml:"Name,attr"`
+		Action    string `xml:"Action,attr"`
+		EntitySet string `xml:"EntitySet,attr"`
+	} `xml:"ActionImport"`
+	FunctionImports []struct {
+		Name      string `xml:"Name,attr"`
+		Function  string `xml:"Function,attr"`
+		EntitySet string `xml:"EntitySet,attr"`
+	} `xml:"FunctionImport"`
+}
+
+type xmlNavigationBinding struct {
+	Path   string `xml:"Path,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+// parseMetadataXML parses a metadata document in the CSDL XML format
+func parseMetadataXML(data []byte) (*Metadata, error) {
+	doc := edmxDocument{}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	metadata := &Metadata{Version: doc.Version}
+	for _, xSchema := range doc.Schemas {
+		schema := &Schema{Namespace: xSchema.Namespace, Alias: xSchema.Alias}
+		for _, xType := range xSchema.EntityTypes {
+			entityType := &EntityType{StructuredType: xType.structuredType(), HasStream: xType.HasStream == "true"}
+			for _, key := range xType.Key {
+				entityType.Key = append(entityType.Key, key.Name)
+			}
+			schema.EntityTypes = append(schema.EntityTypes, entityType)
+		}
+		for _, xType := range xSchema.ComplexTypes {
+			schema.ComplexTypes = append(schema.ComplexTypes, &ComplexType{StructuredType: xType.structuredType()})
+		}
+		for _, xType := range xSchema.EnumTypes {
+			enumType := &EnumType{Name: xType.Name, UnderlyingType: xType.UnderlyingType, IsFlags: xType.IsFlags == "true"}
+			if enumType.UnderlyingType == "" {
+				enumType.UnderlyingType = "Edm.Int32"
+			}
+			for _, member := range xType.Members {
+				enumType.Members = append(enumType.Members, &EnumMember{Name: member.Name, Value: member.Value})
+			}
+			schema.EnumTypes = append(schema.EnumTypes, enumType)
+		}
+		for _, xOperation := range xSchema.Actions {
+			schema.Actions = append(schema.Actions, xOperation.operation())
+		}
+		for _, xOperation := range xSchema.Functions {
+			schema.Functions = append(schema.Functions, xOperation.operation())
+		}
+		if xContainer := xSchema.EntityContainer; xContainer != nil {
+			container := &EntityContainer{Name: xContainer.Name}
+			for _, xEntitySet := range xContainer.EntitySets {
+				entitySet := &EntitySet{Name: xEntitySet.Name, EntityType: xEntitySet.EntityType}
+				for _, binding := range xEntitySet.Bindings {
+					entitySet.NavigationPropertyBindings = append(entitySet.NavigationPropertyBindings, &NavigationPropertyBinding{Path: binding.Path, Target: binding.Target})
+				}
+				container.EntitySets = append(container.EntitySets, entitySet)
+			}
+			for _, xSingleton := range xContainer.Singletons {
+				singleton := &Singleton{Name: xSingleton.Name, Type: xSingleton.Type}
+				for _, binding := range xSingleton.Bindings {
+					singleton.NavigationPropertyBindings = append(singleton.NavigationPropertyBindings, &NavigationPropertyBinding{Path: binding.Path, Target: binding.Target})
+				}
+				container.Singletons = append(container.Singletons, singleton)
+			}
+			for _, xImport := range xContainer.ActionImports {
+				container.ActionImports = append(container.ActionImports, &OperationImport{Name: xImport.Name, Operation: xImport.Action, EntitySet: xImport.EntitySet})
+			}
+			for _, xImport := range xContainer.FunctionImports {
+				container.FunctionImports = append(container.FunctionImports, &OperationImport{Name: xImport.Name, Operation: xImport.Function, EntitySet: xImport.EntitySet})
+			}
+			schema.EntityContainer = container
+		}
+		metadata.Schemas = append(metadata.Schemas, schema)
+	}
+	return metadata, nil
+}
+
+func (xType *xmlStructuredType) structuredType() StructuredType {
+	structuredType := StructuredType{
+		Name:     xType.Name,
+		BaseType: xType.BaseType,
+		Abstract: xType.Abstract == "true",
+		OpenType: xType.OpenType == "true",
+	}
+	for _, xProperty := range xType.Properties {
+		structuredType.Properties = append(structuredType.Properties, &Property{
+			Name:         xProperty.Name,
+			Type:         xProperty.Type,
+			Nullable:     xProperty.Nullable != "false",
+			MaxLength:    xProperty.MaxLength,
+			Precision:    xProperty.Precision,
+			Scale:        xProperty.Scale,
+			DefaultValue: xProperty.DefaultValue,
+		})
+	}
+	for _, xProperty := range xType.NavigationProperties {
+		structuredType.NavigationProperties = append(structuredType.NavigationProperties, &NavigationProperty{
+			Name:           xProperty.Name,
+			Type:           xProperty.Type,
+			Nullable:       xProperty.Nullable != "false",
+			Partner:        xProperty.Partner,
+			ContainsTarget: xProperty.ContainsTarget == "true",
+		})
+	}
+	return structuredType
+}
+
+func (xOperation *xmlOperation) operation() *Operation {
+	operation := &Operation{
+		Name:          xOperation.Name,
+		IsBound:       xOperation.IsBound == "true",
+		IsComposable:  xOperation.IsComposable == "true",
+		EntitySetPath: xOperation.EntitySetPath,
+	}
+	for _, xParameter := range xOperation.Parameters {
+		operation.Parameters = append(operation.Parameters, &Parameter{Name: xParameter.Name, Type: xParameter.Type, Nullable: xParameter.Nullable != "false"})
+	}
+	if xOperation.ReturnType != nil {
+		operation.ReturnType = &ReturnType{Type: xOperation.ReturnType.Type, Nullable: xOperation.ReturnType.Nullable != "false"}
+	}
+	return operation
+}
+
+// parseMetadataJSON parses a metadata document in the CSDL JSON format
+func parseMetadataJSON(data []byte) (*Metadata, error) {
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	metadata := &Metadata{}
+	if version, ok := doc["$Version"]; ok {
+		json.Unmarshal(version, &metadata.Version)
+	}
+	containerName := ""
+	if container, ok := doc["$EntityContainer"]; ok {
+		json.Unmarshal(container, &containerName)
+	}
+	// Every member not starting with a '$' is a schema
+	namespaces, err := objectKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, namespace := range namespaces {
+		if strings.HasPrefix(namespace, "$") {
+			continue
+		}
+		members := map[string]json.RawMessage{}
+		if err := json.Unmarshal(doc[namespace], &members); err != nil {
+			return nil, fmt.Errorf("odata: schema '%s': %w", namespace, err)
+		}
+		schema := &Schema{Namespace: namespace}
+		if alias, ok := members["$Alias"]; ok {
+			json.Unmarshal(alias, &schema.Alias)
+		}
+		names, err := objectKeys(doc[namespace])
+		if err != nil {
+			return nil, fmt.Errorf("odata: schema '%s': %w", namespace, err)
+		}
+		for _, name := range names {
+			if strings.HasPrefix(name, "$") {
+				continue
+			}
+			if err := schema.addJSONElement(name, members[name], namespace+"."+name == containerName); err != nil {
+				return nil, fmt.Errorf("odata: schema element '%s.%s': %w", namespace, name, err)
+			}
+		}
+		metadata.Schemas = append(metadata.Schemas, schema)
+	}
+	return metadata, nil
+}
+
+// jsonElement reflects the members of the various CSDL JSON schema elements we are interested in
+type jsonElement struct {
+	Kind           string            `json:"$Kind"`
+	Type           string            `json:"$Type"`
+	Collection     bool              `json:"$Collection"`
+	Nullable       bool              `json:"$Nullable"`
+	BaseType       string            `json:"$BaseType"`
+	Abstract       bool              `json:"$Abstract"`
+	OpenType       bool              `json:"$OpenType"`
+	HasStream      bool              `json:"$HasStream"`
+	Key            []json.RawMessage `json:"$Key"`
+	MaxLength      json.RawMessage   `json:"$MaxLength"`
+	Precision      json.RawMessage   `json:"$Precision"`
+	Scale          json.RawMessage   `json:"$Scale"`
+	DefaultValue   json.RawMessage   `json:"$DefaultValue"`
+	Partner        string            `json:"$Partner"`
+	ContainsTarget bool              `json:"$ContainsTarget"`
+	UnderlyingType string            `json:"$UnderlyingType"`
+	IsFlags        bool              `json:"$IsFlags"`
+	IsBound        bool              `json:"$IsBound"`
+	IsComposable   bool              `json:"$IsComposable"`
+	EntitySetPath  string            `json:"$EntitySetPath"`
+	Parameters     []struct {
+		Name       string `json:"$Name"`
+		Type       string `json:"$Type"`
+		Collection bool   `json:"$Collection"`
+		Nullable   bool   `json:"$Nullable"`
+	} `json:"$Parameter"`
+	ReturnType *struct {
+		Type       string `json:"$Type"`
+		Collection bool   `json:"$Collection"`
+		Nullable   bool   `json:"$Nullable"`
+	} `json:"$ReturnType"`
+	NavigationPropertyBinding map[string]string `json:"$NavigationPropertyBinding"`
+	Action                    string            `json:"$Action"`
+	Function                  string            `json:"$Function"`
+	EntitySet                 string            `json:"$EntitySet"`
+}
+
+// jsonType returns the type name, in the notation used by CSDL XML, for a CSDL JSON type which defaults to Edm.String
+func jsonType(typeName string, isCollection bool) string {
+	if typeName == "" {
+		typeName = "Edm.String"
+	}
+	return boundTypeName(typeName, isCollection)
+}
+
+// rawString returns the textual representation of a, string or numeric, JSON value
+func rawString(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// addJSONElement adds the CSDL JSON schema element, with the passed name, to the schema
+func (schema *Schema) addJSONElement(name string, raw json.RawMessage, isContainer bool) error {
+	// Actions and functions are represented as arrays of overloads
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		overloads := []jsonElement{}
+		if err := json.Unmarshal(raw, &overloads); err != nil {
+			return err
+		}
+		for _, overload := range overloads {
+			operation := &Operation{Name: name, IsBound: overload.IsBound, IsComposable: overload.IsComposable, EntitySetPath: overload.EntitySetPath}
+			for _, parameter := range overload.Parameters {
+				operation.Parameters = append(operation.Parameters, &Parameter{Name: parameter.Name, Type: jsonType(parameter.Type, parameter.Collection), Nullable: parameter.Nullable})
+			}
+			if overload.ReturnType != nil {
+				operation.ReturnType = &ReturnType{Type: jsonType(overload.ReturnType.Type, overload.ReturnType.Collection), Nullable: overload.ReturnType.Nullable}
+			}
+			if overload.Kind == "Action" {
+				schema.Actions = append(schema.Actions, operation)
+			} else {
+				schema.Functions = append(schema.Functions, operation)
+			}
+		}
+		return nil
+	}
+
+	element := jsonElement{}
+	if err := json.Unmarshal(raw, &element); err != nil {
+		return err
+	}
+	members := map[string]json.RawMessage{}
+	json.Unmarshal(raw, &members)
+	memberNames, err := objectKeys(raw)
+	if err != nil {
+		return err
+	}
+	switch {
+	case element.Kind == "EntityType" || element.Kind == "ComplexType":
+		structuredType := StructuredType{Name: name, BaseType: element.BaseType, Abstract: element.Abstract, OpenType: element.OpenType}
+		for _, memberName := range memberNames {
+			if strings.HasPrefix(memberName, "$") || strings.Contains(memberName, "@") {
+				continue
+			}
+			member := jsonElement{}
+			if err := json.Unmarshal(members[memberName], &member); err != nil {
+				return err
+			}
+			if member.Kind == "NavigationProperty" {
+				structuredType.NavigationProperties = append(structuredType.NavigationProperties, &NavigationProperty{
+					Name:           memberName,
+					Type:           jsonType(member.Type, member.Collection),
+					Nullable:       member.Nullable,
+					Partner:        member.Partner,
+					ContainsTarget: member.ContainsTarget,
+				})
+			} else {
+				structuredType.Properties = append(structuredType.Properties, &Property{
+					Name:         memberName,
+					Type:         jsonType(member.Type, member.Collection),
+					Nullable:     member.Nullable,
+					MaxLength:    rawString(member.MaxLength),
+					Precision:    rawString(member.Precision),
+					Scale:        rawString(member.Scale),
+					DefaultValue: rawString(member.DefaultValue),
+				})
+			}
+		}
+		if element.Kind == "EntityType" {
+			entityType := &EntityType{StructuredType: structuredType, HasStream: element.HasStream}
+			for _, key := range element.Key {
+				// A key property is either its name or an object mapping an alias to its path
+				var keyName string
+				if json.Unmarshal(key, &keyName) != nil {
+					aliased := map[string]string{}
+					json.Unmarshal(key, &aliased)
+					for _, path := range aliased {
+						keyName = path
+					}
+				}
+				entityType.Key = append(entityType.Key, keyName)
+			}
+			schema.EntityTypes = append(schema.EntityTypes, entityType)
+		} else {
+			schema.ComplexTypes = append(schema.ComplexTypes, &ComplexType{StructuredType: structuredType})
+		}
+	case element.Kind == "EnumType":
+		enumType := &EnumType{Name: name, UnderlyingType: element.UnderlyingType, IsFlags: element.IsFlags}
+		if enumType.UnderlyingType == "" {
+			enumType.UnderlyingType = "Edm.Int32"
+		}
+		for _, memberName := range memberNames {
+			if strings.HasPrefix(memberName, "$") || strings.Contains(memberName, "@") {
+				continue
+			}
+			enumType.Members = append(enumType.Members, &EnumMember{Name: memberName, Value: rawString(members[memberName])})
+		}
+		schema.EnumTypes = append(schema.EnumTypes, enumType)
+	case element.Kind == "EntityContainer" || isContainer:
+		container := &EntityContainer{Name: name}
+		for _, memberName := range memberNames {
+			if strings.HasPrefix(memberName, "$") || strings.Contains(memberName, "@") {
+				continue
+			}
+			member := jsonElement{}
+			if err := json.Unmarshal(members[memberName], &member); err != nil {
+				return err
+			}
+			var bindings []*NavigationPropertyBinding
+			if raw := map[string]json.RawMessage{}; json.Unmarshal(members[memberName], &raw) == nil && raw["$NavigationPropertyBinding"] != nil {
+				paths, _ := objectKeys(raw["$NavigationPropertyBinding"])
+				for _, path := range paths {
+					bindings = append(bindings, &NavigationPropertyBinding{Path: path, Target: member.NavigationPropertyBinding[path]})
+				}
+			}
+			switch {
+			case member.Action != "":
+				container.ActionImports = append(container.ActionImports, &OperationImport{Name: memberName, Operation: member.Action, EntitySet: member.EntitySet})
+			case member.Function != "":
+				container.FunctionImports = append(container.FunctionImports, &OperationImport{Name: memberName, Operation: member.Function, EntitySet: member.EntitySet})
+			case member.Collection:
+				container.EntitySets = append(container.EntitySets, &EntitySet{Name: memberName, EntityType: member.Type, NavigationPropertyBindings: bindings})
+			default:
+				container.Singletons = append(container.Singletons, &Singleton{Name: memberName, Type: member.Type, NavigationPropertyBindings: bindings})
+			}
+		}
+		schema.EntityContainer = container
+	}
+	return nil
+}
+
+// objectKeys returns the member names of the JSON object in the order they appear in the document, which, unlike
+// a map, preserves the order of for example the properties of a type
+func objectKeys(raw json.RawMessage) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, errors.New("odata: expected a JSON object")
+	}
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, token.(string))
+		// Skip the value of the member
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
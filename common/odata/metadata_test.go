@@ -0,0 +1,182 @@
+package odata
+
+import (
+	"testing"
+)
+
+// testMetadataXML is a trimmed down version of the TM1 Server metadata document, in the CSDL XML format
+const testMetadataXML = `<?xml version="1.0" encoding="utf-8"?>
+<edmx:Edmx Version="4.0" xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx">
+  <edmx:DataServices>
+    <Schema Namespace="ibm.tm1.api.v1" Alias="tm1" xmlns="http://docs.oasis-open.org/odata/ns/edm">
+      <EntityType Name="Cube">
+        <Key><PropertyRef Name="Name"/></Key>
+        <Property Name="Name" Type="Edm.String" Nullable="false"/>
+        <Property Name="Rules" Type="Edm.String"/>
+        <NavigationProperty Name="Dimensions" Type="Collection(tm1.Dimension)"/>
+        <NavigationProperty Name="Views" Type="Collection(tm1.View)"/>
+      </EntityType>
+      <EntityType Name="Dimension">
+        <Key><PropertyRef Name="Name"/></Key>
+        <Property Name="Name" Type="Edm.String" Nullable="false"/>
+      </EntityType>
+      <EntityType Name="View" Abstract="true">
+        <Key><PropertyRef Name="Name"/></Key>
+        <Property Name="Name" Type="Edm.String" Nullable="false"/>
+      </EntityType>
+      <EntityType Name="MDXView" BaseType="tm1.View">
+        <Property Name="MDX" Type="Edm.String"/>
+      </EntityType>
+      <EntityType Name="Cellset">
+        <Key><PropertyRef Name="ID"/></Key>
+        <Property Name="ID" Type="Edm.String" Nullable="false"/>
+      </EntityType>
+      <EnumType Name="ElementType">
+        <Member Name="Numeric" Value="1"/>
+        <Member Name="String" Value="2"/>
+      </EnumType>
+      <Action Name="Update" IsBound="true">
+        <Parameter Name="Cube" Type="tm1.Cube"/>
+        <Parameter Name="Cells" Type="Collection(Edm.String)"/>
+      </Action>
+      <Action Name="Execute" IsBound="true">
+        <Parameter Name="View" Type="tm1.View"/>
+        <ReturnType Type="tm1.Cellset"/>
+      </Action>
+      <Action Name="ExecuteMDX">
+        <Parameter Name="MDX" Type="Edm.String"/>
+        <ReturnType Type="tm1.Cellset"/>
+      </Action>
+      <EntityContainer Name="API">
+        <EntitySet Name="Cubes" EntityType="tm1.Cube"/>
+        <EntitySet Name="Dimensions" EntityType="tm1.Dimension"/>
+        <ActionImport Name="ExecuteMDX" Action="tm1.ExecuteMDX"/>
+      </EntityContainer>
+    </Schema>
+  </edmx:DataServices>
+</edmx:Edmx>`
+
+// testMetadataJSON is the same metadata document in the CSDL JSON format
+const testMetadataJSON = `{
+  "$Version": "4.01",
+  "$EntityContainer": "ibm.tm1.api.v1.API",
+  "ibm.tm1.api.v1": {
+    "$Alias": "tm1",
+    "Cube": {
+      "$Kind": "EntityType",
+      "$Key": ["Name"],
+      "Name": {},
+      "Rules": {"$Nullable": true},
+      "Dimensions": {"$Kind": "NavigationProperty", "$Type": "tm1.Dimension", "$Collection": true},
+      "Views": {"$Kind": "NavigationProperty", "$Type": "tm1.View", "$Collection": true}
+    },
+    "Dimension": {"$Kind": "EntityType", "$Key": ["Name"], "Name": {}},
+    "View": {"$Kind": "EntityType", "$Abstract": true, "$Key": ["Name"], "Name": {}},
+    "MDXView": {"$Kind": "EntityType", "$BaseType": "tm1.View", "MDX": {"$Nullable": true}},
+    "Cellset": {"$Kind": "EntityType", "$Key": ["ID"], "ID": {}},
+    "ElementType": {"$Kind": "EnumType", "Numeric": 1, "String": 2},
+    "Update": [{"$Kind": "Action", "$IsBound": true, "$Parameter": [{"$Name": "Cube", "$Type": "tm1.Cube"}, {"$Name": "Cells", "$Collection": true}]}],
+    "Execute": [{"$Kind": "Action", "$IsBound": true, "$Parameter": [{"$Name": "View", "$Type": "tm1.View"}], "$ReturnType": {"$Type": "tm1.Cellset"}}],
+    "ExecuteMDX": [{"$Kind": "Action", "$Parameter": [{"$Name": "MDX"}], "$ReturnType": {"$Type": "tm1.Cellset"}}],
+    "API": {
+      "$Kind": "EntityContainer",
+      "Cubes": {"$Collection": true, "$Type": "tm1.Cube"},
+      "Dimensions": {"$Collection": true, "$Type": "tm1.Dimension"},
+      "ExecuteMDX": {"$Action": "tm1.ExecuteMDX"}
+    }
+  }
+}`
+
+func TestParseMetadata(t *testing.T) {
+	for _, format := range []struct {
+		name     string
+		document string
+	}{
+		{"xml", testMetadataXML},
+		{"json", testMetadataJSON},
+	} {
+		metadata, err := ParseMetadata([]byte(format.document))
+		if err != nil {
+			t.Fatalf("%s: %v", format.name, err)
+		}
+		cube := metadata.EntityType("tm1.Cube")
+		if cube == nil {
+			t.Fatalf("%s: entity type tm1.Cube not found", format.name)
+		}
+		if metadata.EntityType("ibm.tm1.api.v1.Cube") != cube {
+			t.Errorf("%s: entity type not found by its namespace qualified name", format.name)
+		}
+		if len(cube.Key) != 1 || cube.Key[0] != "Name" {
+			t.Errorf("%s: got key %v, want [Name]", format.name, cube.Key)
+		}
+		if len(cube.Properties) != 2 || cube.Properties[0].Name != "Name" || cube.Properties[1].Name != "Rules" {
+			t.Errorf("%s: got properties %v, want Name and Rules, in order", format.name, cube.Properties)
+		}
+		if navigationProperty := metadata.NavigationProperty(&cube.StructuredType, "Dimensions"); navigationProperty == nil || navigationProperty.Type != "Collection(tm1.Dimension)" {
+			t.Errorf("%s: got navigation property %v, want Dimensions of Collection(tm1.Dimension)", format.name, navigationProperty)
+		}
+		if view := metadata.EntityType("tm1.MDXView"); view == nil || view.BaseType != "tm1.View" {
+			t.Errorf("%s: got %v, want MDXView deriving from tm1.View", format.name, view)
+		}
+		if schema := metadata.Schema("tm1"); schema == nil || len(schema.EnumTypes) != 1 || len(schema.EnumTypes[0].Members) != 2 {
+			t.Errorf("%s: enum type ElementType with 2 members not found", format.name)
+		}
+		if metadata.EntitySet("Cubes") == nil || metadata.EntityContainer() == nil {
+			t.Errorf("%s: entity set Cubes not found", format.name)
+		}
+	}
+}
+
+func TestParseMetadataInvalid(t *testing.T) {
+	for _, document := range []string{"", "metadata", "<Edmx", `{"$Version":`} {
+		if _, err := ParseMetadata([]byte(document)); err == nil {
+			t.Errorf("ParseMetadata(%q) succeeded, want an error", document)
+		}
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	metadata, err := ParseMetadata([]byte(testMetadataXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path  string
+		valid bool
+	}{
+		{"Cubes", true},
+		{"Cubes('Sales')", true},
+		{"Cubes('Sales')/tm1.Update", true},
+		{"Cubes('Sales')/ibm.tm1.api.v1.Update", true},
+		{"Cubes('Sales')/Rules", true},
+		{"Cubes('Sales')/Rules/$value", true},
+		{"Cubes('Sales')/Dimensions", true},
+		{"Cubes('Sales')/Dimensions/$count", true},
+		{"Cubes('Sales')/Dimensions('Products')", true},
+		{"Cubes('Sales')/Dimensions('Products')/Name", true},
+		{"Cubes('Sales')/Views('Revenue')/tm1.Execute", true},
+		{"Cubes('Sales')/Views('Revenue')/tm1.MDXView/MDX", true},
+		{"Cubes('a/b')/tm1.Update?$select=Name", true},
+		{"ExecuteMDX", true},
+		{"", false},
+		{"Sales", false},
+		{"Cubes/tm1.Update", false},
+		{"Cubes/Rules", false},
+		{"Cubes('Sales')/Measures", false},
+		{"Cubes('Sales')/Rules('x')", false},
+		{"Cubes('Sales')/tm1.Execute", false},
+		{"Cubes('Sales')/tm1.Update/Name", false},
+		{"Cubes('Sales')/$count/Name", false},
+		{"Cubes('Sales')/Views('Revenue')/tm1.Cube", false},
+		{"Dimensions('Products')/tm1.Update", false},
+		{"ExecuteMDX/ID", false},
+	}
+	for _, test := range tests {
+		err := metadata.ValidatePath(test.path)
+		if test.valid && err != nil {
+			t.Errorf("ValidatePath(%q) = %v, want no error", test.path, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("ValidatePath(%q) succeeded, want an error", test.path)
+		}
+	}
+}
@@ -1,11 +1,12 @@
 package odata
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -14,162 +15,200 @@ var Verbose = true
 
 type Client struct {
 	http.Client
+	// RetryPolicy, if set, defines how requests failing with a transient error get retried
+	RetryPolicy *RetryPolicy
+	// RateLimiter, if set, limits the rate at which requests get sent
+	RateLimiter *RateLimiter
 }
 
-func (client *Client) ExecuteGETRequest(urlStr string) *http.Response {
-	// Create new, GET, request
-	req, _ := http.NewRequest("GET", urlStr, nil)
-	// Add the OData-Version header
-	req.Header.Add("OData-MaxVersion", "4.0")
-	// We'll be expecting a JSON formatted response, set Accept header accordingly
-	req.Header.Add("Accept", "application/json")
-	if Verbose == true {
-		fmt.Println(req.Method, req.URL)
-	}
-	// Execute the request
-	resp, err := client.Do(req)
-	// If no errors then return the response
-	if err != nil {
-		log.Fatal(err)
-	}
-	return resp
+func (client *Client) ExecuteGETRequest(urlStr string) (*http.Response, error) {
+	return client.ExecuteGETRequestExContext(context.Background(), urlStr, nil)
 }
 
-func (client *Client) ExecuteGETRequestEx(urlStr string, preReq func(*http.Request)) *http.Response {
-	// Create new, GET, request
-	req, _ := http.NewRequest("GET", urlStr, nil)
-	// Add the OData-Version header
-	req.Header.Add("OData-MaxVersion", "4.0")
-	// We'll be expecting a JSON formatted response, set Accept header accordingly
-	req.Header.Add("Accept", "application/json")
-	// Allow additional processing of the request before actually executing
-	preReq(req)
-	if Verbose == true {
-		fmt.Println(req.Method, req.URL)
-	}
-	// Execute the request
-	resp, err := client.Do(req)
-	// If no errors then return the response
-	if err != nil {
-		log.Fatal(err)
-	}
-	return resp
+func (client *Client) ExecuteGETRequestContext(ctx context.Context, urlStr string) (*http.Response, error) {
+	return client.ExecuteGETRequestExContext(ctx, urlStr, nil)
 }
 
-func (client *Client) ExecutePOSTRequest(urlStr, contentType, body string) *http.Response {
-	// Create new, POST, request
-	req, _ := http.NewRequest("POST", urlStr, strings.NewReader(body))
-	req.Header.Add("Content-Type", contentType)
-	// Add the OData-Version header
-	req.Header.Add("OData-MaxVersion", "4.0")
-	// We'll be expecting a JSON formatted response, set Accept header accordingly
-	req.Header.Add("Accept", "application/json")
-	if Verbose == true {
-		fmt.Println(req.Method, req.URL)
-		fmt.Println(body)
+func (client *Client) ExecuteGETRequestEx(urlStr string, preReq func(*http.Request)) (*http.Response, error) {
+	return client.ExecuteGETRequestExContext(context.Background(), urlStr, preReq)
+}
+
+func (client *Client) ExecuteGETRequestExContext(ctx context.Context, urlStr string, preReq func(*http.Request)) (*http.Response, error) {
+	return client.executeRequest(ctx, "GET", urlStr, "", "", preReq)
+}
+
+func (client *Client) ExecutePOSTRequest(urlStr, contentType, body string) (*http.Response, error) {
+	return client.ExecutePOSTRequestExContext(context.Background(), urlStr, contentType, body, nil)
+}
+
+func (client *Client) ExecutePOSTRequestContext(ctx context.Context, urlStr, contentType, body string) (*http.Response, error) {
+	return client.ExecutePOSTRequestExContext(ctx, urlStr, contentType, body, nil)
+}
+
+func (client *Client) ExecutePOSTRequestEx(urlStr, contentType, body string, preReq func(*http.Request)) (*http.Response, error) {
+	return client.ExecutePOSTRequestExContext(context.Background(), urlStr, contentType, body, preReq)
+}
+
+func (client *Client) ExecutePOSTRequestExContext(ctx context.Context, urlStr, contentType, body string, preReq func(*http.Request)) (*http.Response, error) {
+	return client.executeRequest(ctx, "POST", urlStr, contentType, body, preReq)
+}
+
+// ExecutePATCHRequest executes a PATCH request, updating the entity at the URL with the passed body
+func (client *Client) ExecutePATCHRequest(urlStr, contentType, body string) (*http.Response, error) {
+	return client.ExecutePATCHRequestContext(context.Background(), urlStr, contentType, body)
+}
+
+// ExecutePATCHRequestContext executes a PATCH request, updating the entity at the URL with the passed body, bound to the passed context
+func (client *Client) ExecutePATCHRequestContext(ctx context.Context, urlStr, contentType, body string) (*http.Response, error) {
+	return client.executeRequest(ctx, "PATCH", urlStr, contentType, body, nil)
+}
+
+// ExecuteDELETERequest executes a DELETE request, deleting the entity at the URL
+func (client *Client) ExecuteDELETERequest(urlStr string) (*http.Response, error) {
+	return client.ExecuteDELETERequestContext(context.Background(), urlStr)
+}
+
+// ExecuteDELETERequestContext executes a DELETE request, deleting the entity at the URL, bound to the passed context
+func (client *Client) ExecuteDELETERequestContext(ctx context.Context, urlStr string) (*http.Response, error) {
+	return client.executeRequest(ctx, "DELETE", urlStr, "", "", nil)
+}
+
+// executeRequest executes a request, with a body if a content type is passed, using the passed method
+func (client *Client) executeRequest(ctx context.Context, method, urlStr, contentType, body string, preReq func(*http.Request)) (*http.Response, error) {
+	// Create new request, bound to the passed context so cancelling it aborts the request
+	var reqBody io.Reader
+	if contentType != "" {
+		reqBody = strings.NewReader(body)
 	}
-	// Execute the request
-	resp, err := client.Do(req)
-	// If no errors then return the response
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, reqBody)
 	if err != nil {
-		log.Fatal(err)
+		return nil, &RequestError{Method: method, URL: urlStr, Err: err}
+	}
+	if contentType != "" {
+		req.Header.Add("Content-Type", contentType)
 	}
-	return resp
-}
-
-func (client *Client) ExecutePOSTRequestEx(urlStr, contentType, body string, preReq func(*http.Request)) *http.Response {
-	// Create new, POST, request
-	req, _ := http.NewRequest("POST", urlStr, strings.NewReader(body))
-	req.Header.Add("Content-Type", contentType)
 	// Add the OData-Version header
 	req.Header.Add("OData-MaxVersion", "4.0")
 	// We'll be expecting a JSON formatted response, set Accept header accordingly
 	req.Header.Add("Accept", "application/json")
 	// Allow additional processing of the request before actually executing
-	preReq(req)
+	if preReq != nil {
+		preReq(req)
+	}
 	if Verbose == true {
 		fmt.Println(req.Method, req.URL)
-		fmt.Println(body)
+		if contentType != "" {
+			fmt.Println(body)
+		}
 	}
 	// Execute the request
-	resp, err := client.Do(req)
+	resp, err := client.do(req)
 	// If no errors then return the response
 	if err != nil {
-		log.Fatal(err)
+		return nil, &RequestError{Method: req.Method, URL: urlStr, Err: err}
 	}
-	return resp
+	return resp, nil
 }
 
-func (client *Client) IterateCollection(datasourceServiceRootURL string, urlStr string, processResponse func([]byte) (int, string)) {
-	// Set up the request to retrieve the collection given the passed url
+func (client *Client) IterateCollection(datasourceServiceRootURL string, urlStr string, processResponse func([]byte) (int, string, error)) error {
+	return client.IterateCollectionContext(context.Background(), datasourceServiceRootURL, urlStr, processResponse)
+}
+
+// IterateCollectionContext iterates the collection, page by page, aborting any in-flight request and returning
+// the context's error once the passed context gets cancelled.
+func (client *Client) IterateCollectionContext(ctx context.Context, datasourceServiceRootURL string, urlStr string, processResponse func([]byte) (int, string, error)) error {
 	// While we are requesting the collection completely in one request, the service might opt to
 	// apply server driven paging and give us a partial response with a nextLink which subsequently
-	// can be used to retrieve the next chunk or remainder of the collection. The following code
-	// does exactly that however commented out because the implementation of the NorthWind service
-	// has a big in the server driven paging algorithm resulting in entities getting lost.
-	/*
-		for nextLink := urlStr; nextLink != ""; {
-			resp := client.ExecuteGETRequest(datasourceServiceRootURL + nextLink)
-			defer resp.Body.Close()
-			body, _ := ioutil.ReadAll(resp.Body)
-			if Verbose == true {
-				fmt.Println(string(body))
-			}
-
-			// Process the response
-			_, nextLink = processResponse(body)
-		}
-	*/
+	// can be used to retrieve the next chunk or remainder of the collection. NextLinkPaging does
+	// exactly that however we don't use it by default because the implementation of the NorthWind
+	// service has a bug in the server driven paging algorithm resulting in entities getting lost.
 	// So instead we'll use paging driven by the client using $top and $skip using a small enough
 	// page size so we don't inevitably run into a situation where the service would nevertheless
 	// decide to page the result. For this purpose, and simplicity of the code, we've chosen a
-	// page size of 10 and ignore any nextLink (which there won't be any guaranteed;-)
-	// On the first request we'll add a $count query option asking the service the return the number
-	// of entities we can expect in the total collection (hence the first request will take longer).
-	url := urlStr + "&$count=true&$top=10"
-	nCount := 0
-	nSkip := 0
-	for {
-		resp := client.ExecuteGETRequest(datasourceServiceRootURL + url)
-		defer resp.Body.Close()
-		body, _ := ioutil.ReadAll(resp.Body)
-		if Verbose == true {
-			fmt.Println(string(body))
+	// page size of 10.
+	return client.IterateCollectionPagedContext(ctx, datasourceServiceRootURL, urlStr, SkipTopPaging{PageSize: 10}, processResponse)
+}
+
+func (client *Client) IterateCollectionPaged(datasourceServiceRootURL string, urlStr string, paging PagingStrategy, processResponse func([]byte) (int, string, error)) error {
+	return client.IterateCollectionPagedContext(context.Background(), datasourceServiceRootURL, urlStr, paging, processResponse)
+}
+
+// IterateCollectionPagedContext iterates the collection, page by page, using the passed paging strategy. The
+// processResponse callback returns the @odata.count and @odata.nextLink of the page it processed.
+func (client *Client) IterateCollectionPagedContext(ctx context.Context, datasourceServiceRootURL string, urlStr string, paging PagingStrategy, processResponse func([]byte) (int, string, error)) error {
+	page := Page{URL: paging.FirstPage(urlStr)}
+	retrieved := make(map[string]bool)
+	for page.URL != "" {
+		// Guard against services handing us a link to a page we've already processed
+		pageURL := resolveLink(datasourceServiceRootURL, page.URL)
+		if retrieved[pageURL] {
+			return fmt.Errorf("%w: %s", ErrPagingCycle, pageURL)
+		}
+		retrieved[pageURL] = true
+
+		body, err := client.getCollectionPage(ctx, pageURL, paging.PrepareRequest)
+		if err != nil {
+			return err
 		}
 
 		// Process the response
-		count, _ := processResponse(body)
-		if nCount == 0 {
-			if count == 0 {
-				break
-			}
-			nCount = count
+		page.Count, page.NextLink, err = processResponse(body)
+		if err != nil {
+			return &ResponseError{URL: pageURL, Err: err}
 		}
-		nSkip += 10
-		if nCount <= nSkip {
-			break
+		if page.Entities, err = countEntities(body); err != nil {
+			return &ResponseError{URL: pageURL, Err: err}
 		}
-		url = urlStr + "&$skip=" + strconv.Itoa(nSkip) + "&$top=10"
+		if page.Index == 0 {
+			page.TotalCount = page.Count
+		}
+		page.Seen += page.Entities
+
+		// Let the paging strategy decide what, if any, page is next
+		nextURL, err := paging.NextPage(urlStr, &page)
+		if err != nil {
+			return &ResponseError{URL: pageURL, Err: err}
+		}
+		page.URL = nextURL
+		page.Index++
+	}
+	return nil
+}
+
+// countEntities returns the number of entities in the collection response
+func countEntities(body []byte) (int, error) {
+	res := struct {
+		Value []json.RawMessage `json:"value"`
+	}{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return 0, err
 	}
+	return len(res.Value), nil
 }
 
-func (client *Client) TrackCollection(serviceRootURL string, urlStr string, interval time.Duration, processResponse func([]byte) (string, string)) {
+func (client *Client) TrackCollection(serviceRootURL string, urlStr string, interval time.Duration, processResponse func([]byte) (string, string, error)) error {
+	return client.TrackCollectionContext(context.Background(), serviceRootURL, urlStr, interval, processResponse)
+}
+
+// TrackCollectionContext tracks the collection until either the service stops returning deltaLinks, an error
+// occurs or the passed context gets cancelled, in which case the context's error is returned.
+func (client *Client) TrackCollectionContext(ctx context.Context, serviceRootURL string, urlStr string, interval time.Duration, processResponse func([]byte) (string, string, error)) error {
 	// Set up the request to retrieve the collection given the passed url
 	// While we are requesting the collection completely in one request, the service might opt to
 	// apply server driven paging and give us a partial response with a nextLink which subsequently
-	// can be used to retrieve the next chunk or remainder of the collection. The following code
-	// does exactly that however commented out because the implementation of the NorthWind service
-	// has a big in the server driven paging algorithm resulting in entities getting lost.
+	// is used to retrieve the next chunk or remainder of the collection, before moving on to the
+	// deltaLink that only comes with the last chunk.
 	for urlStr := urlStr; urlStr != ""; {
-		resp := client.ExecuteGETRequestEx(serviceRootURL+urlStr, func(req *http.Request) { req.Header.Add("Prefer", "odata.track-changes") })
-		defer resp.Body.Close()
-		body, _ := ioutil.ReadAll(resp.Body)
-		if Verbose == true {
-			fmt.Println(string(body))
+		body, err := client.getCollectionPage(ctx, serviceRootURL+urlStr, func(req *http.Request) { req.Header.Add("Prefer", "odata.track-changes") })
+		if err != nil {
+			return err
 		}
 
 		// Process the response
-		nextLink, deltaLink := processResponse(body)
+		nextLink, deltaLink, err := processResponse(body)
+		if err != nil {
+			return &ResponseError{URL: serviceRootURL + urlStr, Err: err}
+		}
 
 		// TM1 doesn't but other services could return a nextLink when applying server side windowing
 		// while returning the collection. Note that, following OData conventions, only the last
@@ -178,8 +217,10 @@ func (client *Client) TrackCollection(serviceRootURL string, urlStr string, inte
 			// Continue processing the collection being returned
 			urlStr = nextLink
 		} else if deltaLink != "" {
-			// Wait a second before querying for the next deltaLink
-			time.Sleep(interval)
+			// Wait the requested interval before querying for the next deltaLink, unless we get cancelled
+			if err := sleep(ctx, interval); err != nil {
+				return err
+			}
 
 			// Continue with the deltaLink
 			urlStr = deltaLink
@@ -188,12 +229,51 @@ func (client *Client) TrackCollection(serviceRootURL string, urlStr string, inte
 			break
 		}
 	}
+	return nil
 }
 
-func ValidateStatusCode(resp *http.Response, statusCode int, logFmt func() string) {
+// getCollectionPage executes a GET request for a, page of a, collection and returns the response body
+func (client *Client) getCollectionPage(ctx context.Context, urlStr string, preReq func(*http.Request)) ([]byte, error) {
+	resp, err := client.ExecuteGETRequestExContext(ctx, urlStr, preReq)
+	if err != nil {
+		return nil, err
+	}
+	if err = ValidateStatusCode(resp, 200, func() string {
+		return "Failed to retrieve collection"
+	}); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &ResponseError{URL: urlStr, Err: err}
+	}
+	if Verbose == true {
+		fmt.Println(string(body))
+	}
+	return body, nil
+}
+
+// ValidateStatusCode validates that the service responded with the expected status code. If not, the body of the
+// response gets read and closed and a *StatusError, with the message returned by logFmt, is returned.
+func ValidateStatusCode(resp *http.Response, statusCode int, logFmt func() string) error {
 	if resp.StatusCode != statusCode {
 		defer resp.Body.Close()
 		body, _ := ioutil.ReadAll(resp.Body)
-		log.Fatal(logFmt() + "\r\nServer responded with: " + resp.Status + "\r\n" + string(body))
+		statusErr := &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       body,
+			ODataError: parseErrorBody(body),
+		}
+		if logFmt != nil {
+			statusErr.Message = logFmt()
+		}
+		if resp.Request != nil {
+			statusErr.Method = resp.Request.Method
+			statusErr.URL = resp.Request.URL.String()
+		}
+		return statusErr
 	}
+	return nil
 }
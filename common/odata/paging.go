@@ -0,0 +1,159 @@
+package odata
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrCollectionChanged is returned, wrapped, when paging detected that the collection changed while being
+// iterated, in which case entities might have been lost or processed twice
+var ErrCollectionChanged = errors.New("odata: collection changed while being paged")
+
+// ErrPagingCycle is returned, wrapped, when the service returned a link to a page that was already retrieved
+var ErrPagingCycle = errors.New("odata: service returned a link to a page that was already retrieved")
+
+// Page describes a single page of a collection as retrieved while iterating the collection
+type Page struct {
+	// URL is the URL, relative to the service root unless the service returned an absolute nextLink, used to request the page
+	URL string
+	// Index is the zero based index of the page in the iteration
+	Index int
+	// Count is the @odata.count as returned for the page, or 0 if the service didn't return one
+	Count int
+	// TotalCount is the @odata.count as returned for the first page, or 0 if the service didn't return one
+	TotalCount int
+	// NextLink is the @odata.nextLink as returned for the page, if any
+	NextLink string
+	// Entities is the number of entities in the page
+	Entities int
+	// Seen is the number of entities retrieved so far, including the ones in this page
+	Seen int
+}
+
+// PagingStrategy defines how a collection gets paged while being iterated by IterateCollectionPaged
+type PagingStrategy interface {
+	// FirstPage returns the URL, relative to the service root, for the first page of the collection
+	FirstPage(urlStr string) string
+	// PrepareRequest allows the strategy to amend the request for a page before it gets executed
+	PrepareRequest(req *http.Request)
+	// NextPage returns the URL for the page following the passed page, or "" if the iteration is complete
+	NextPage(urlStr string, page *Page) (string, error)
+}
+
+// SkipTopPaging pages through a collection driven by the client using the $skip and $top query options.
+// The collection should be ordered, using $orderby, for the pages to be stable. If VerifyCount is set the
+// $count is requested for every page, not only the first, and the iteration fails with ErrCollectionChanged
+// as soon as the count changes.
+type SkipTopPaging struct {
+	PageSize    int
+	VerifyCount bool
+}
+
+// FirstPage returns the URL for the first page, asking for the count of the collection as well
+func (p SkipTopPaging) FirstPage(urlStr string) string {
+	return appendQueryOptions(urlStr, "$count=true&$top="+strconv.Itoa(p.pageSize()))
+}
+
+// PrepareRequest doesn't need to amend the request
+func (p SkipTopPaging) PrepareRequest(req *http.Request) {}
+
+// NextPage returns the URL for the page following the passed page. Note that we skip the number of entities
+// actually seen, not the page size, so nothing gets lost if the service decided to return a smaller page.
+func (p SkipTopPaging) NextPage(urlStr string, page *Page) (string, error) {
+	if page.Index > 0 && p.VerifyCount && page.Count != page.TotalCount {
+		return "", fmt.Errorf("%w: count changed from %d to %d", ErrCollectionChanged, page.TotalCount, page.Count)
+	}
+	if page.TotalCount > 0 && page.Seen > page.TotalCount {
+		return "", fmt.Errorf("%w: expected %d entities, retrieved %d", ErrCollectionChanged, page.TotalCount, page.Seen)
+	}
+	// We are done once we've seen all entities or, if the service didn't return a count, the page wasn't full
+	if page.Entities == 0 || (page.TotalCount > 0 && page.Seen == page.TotalCount) || (page.TotalCount == 0 && page.Entities < p.pageSize() && page.NextLink == "") {
+		return "", nil
+	}
+	options := "$skip=" + strconv.Itoa(page.Seen) + "&$top=" + strconv.Itoa(p.pageSize())
+	if p.VerifyCount {
+		options = "$count=true&" + options
+	}
+	return appendQueryOptions(urlStr, options), nil
+}
+
+func (p SkipTopPaging) pageSize() int {
+	if p.PageSize <= 0 {
+		return 10
+	}
+	return p.PageSize
+}
+
+// NextLinkPaging pages through a collection driven by the service, following the @odata.nextLink returned with
+// every partial response. If VerifyCount is set the $count is requested with the first page and the iteration
+// fails with ErrCollectionChanged if the number of entities retrieved doesn't match it.
+type NextLinkPaging struct {
+	VerifyCount bool
+}
+
+// FirstPage returns the URL for the first page
+func (p NextLinkPaging) FirstPage(urlStr string) string {
+	if p.VerifyCount {
+		return appendQueryOptions(urlStr, "$count=true")
+	}
+	return urlStr
+}
+
+// PrepareRequest doesn't need to amend the request
+func (p NextLinkPaging) PrepareRequest(req *http.Request) {}
+
+// NextPage returns the nextLink of the passed page
+func (p NextLinkPaging) NextPage(urlStr string, page *Page) (string, error) {
+	return verifiedNextLink(p.VerifyCount, page)
+}
+
+// MaxPageSizePaging pages through a collection driven by the service, like NextLinkPaging, but asks the service
+// for pages of, at most, PageSize entities using the odata.maxpagesize preference.
+type MaxPageSizePaging struct {
+	PageSize    int
+	VerifyCount bool
+}
+
+// FirstPage returns the URL for the first page
+func (p MaxPageSizePaging) FirstPage(urlStr string) string {
+	return NextLinkPaging{VerifyCount: p.VerifyCount}.FirstPage(urlStr)
+}
+
+// PrepareRequest adds the odata.maxpagesize preference to the request
+func (p MaxPageSizePaging) PrepareRequest(req *http.Request) {
+	if p.PageSize > 0 {
+		req.Header.Add("Prefer", "odata.maxpagesize="+strconv.Itoa(p.PageSize))
+	}
+}
+
+// NextPage returns the nextLink of the passed page
+func (p MaxPageSizePaging) NextPage(urlStr string, page *Page) (string, error) {
+	return verifiedNextLink(p.VerifyCount, page)
+}
+
+// verifiedNextLink returns the nextLink of the page, verifying, once there is none, that all entities were retrieved
+func verifiedNextLink(verifyCount bool, page *Page) (string, error) {
+	if page.NextLink == "" && verifyCount && page.Seen != page.TotalCount {
+		return "", fmt.Errorf("%w: expected %d entities, retrieved %d", ErrCollectionChanged, page.TotalCount, page.Seen)
+	}
+	return page.NextLink, nil
+}
+
+// appendQueryOptions appends the passed query options to the URL, taking into account if the URL has a query already
+func appendQueryOptions(urlStr, options string) string {
+	if strings.Contains(urlStr, "?") {
+		return urlStr + "&" + options
+	}
+	return urlStr + "?" + options
+}
+
+// resolveLink resolves a, potentially relative, link returned by a service against the service root URL
+func resolveLink(serviceRootURL, link string) string {
+	if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+		return link
+	}
+	return serviceRootURL + link
+}
@@ -0,0 +1,127 @@
+package odata
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestPagingFirstPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy PagingStrategy
+		url      string
+		want     string
+	}{
+		{"skip top", SkipTopPaging{PageSize: 50}, "Orders", "Orders?$count=true&$top=50"},
+		{"skip top default page size", SkipTopPaging{}, "Orders?$orderby=OrderID", "Orders?$orderby=OrderID&$count=true&$top=10"},
+		{"next link", NextLinkPaging{}, "Orders", "Orders"},
+		{"next link verifying count", NextLinkPaging{VerifyCount: true}, "Orders?$select=OrderID", "Orders?$select=OrderID&$count=true"},
+		{"max page size", MaxPageSizePaging{PageSize: 100}, "Orders", "Orders"},
+	}
+	for _, test := range tests {
+		if got := test.strategy.FirstPage(test.url); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSkipTopPagingNextPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy SkipTopPaging
+		page     Page
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "more entities to come",
+			strategy: SkipTopPaging{PageSize: 10},
+			page:     Page{Index: 0, TotalCount: 25, Entities: 10, Seen: 10},
+			want:     "Orders?$skip=10&$top=10",
+		},
+		{
+			name:     "skips what was seen, not the page size",
+			strategy: SkipTopPaging{PageSize: 10},
+			page:     Page{Index: 1, TotalCount: 25, Entities: 8, Seen: 18},
+			want:     "Orders?$skip=18&$top=10",
+		},
+		{
+			name:     "all entities seen",
+			strategy: SkipTopPaging{PageSize: 10},
+			page:     Page{Index: 2, TotalCount: 25, Entities: 5, Seen: 25},
+		},
+		{
+			name:     "empty page",
+			strategy: SkipTopPaging{PageSize: 10},
+			page:     Page{Index: 1, TotalCount: 25, Entities: 0, Seen: 20},
+		},
+		{
+			name:     "no count and a partial page",
+			strategy: SkipTopPaging{PageSize: 10},
+			page:     Page{Index: 1, Entities: 3, Seen: 13},
+		},
+		{
+			name:     "verifying count requests it again",
+			strategy: SkipTopPaging{PageSize: 10, VerifyCount: true},
+			page:     Page{Index: 1, Count: 25, TotalCount: 25, Entities: 10, Seen: 20},
+			want:     "Orders?$count=true&$skip=20&$top=10",
+		},
+		{
+			name:     "count changed",
+			strategy: SkipTopPaging{PageSize: 10, VerifyCount: true},
+			page:     Page{Index: 1, Count: 26, TotalCount: 25, Entities: 10, Seen: 20},
+			wantErr:  ErrCollectionChanged,
+		},
+		{
+			name:     "more entities than counted",
+			strategy: SkipTopPaging{PageSize: 10},
+			page:     Page{Index: 2, TotalCount: 25, Entities: 10, Seen: 30},
+			wantErr:  ErrCollectionChanged,
+		},
+	}
+	for _, test := range tests {
+		got, err := test.strategy.NextPage("Orders", &test.page)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNextLinkPagingNextPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy PagingStrategy
+		page     Page
+		want     string
+		wantErr  error
+	}{
+		{"next link", NextLinkPaging{}, Page{NextLink: "Orders?$skiptoken=10", Seen: 10}, "Orders?$skiptoken=10", nil},
+		{"last page", NextLinkPaging{}, Page{Seen: 20}, "", nil},
+		{"last page with all counted", NextLinkPaging{VerifyCount: true}, Page{TotalCount: 20, Seen: 20}, "", nil},
+		{"last page with entities lost", NextLinkPaging{VerifyCount: true}, Page{TotalCount: 20, Seen: 19}, "", ErrCollectionChanged},
+		{"max page size next link", MaxPageSizePaging{PageSize: 5}, Page{NextLink: "Orders?$skiptoken=5", Seen: 5}, "Orders?$skiptoken=5", nil},
+	}
+	for _, test := range tests {
+		got, err := test.strategy.NextPage("Orders", &test.page)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMaxPageSizePagingPrepareRequest(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://localhost/Orders", nil)
+	MaxPageSizePaging{PageSize: 100}.PrepareRequest(req)
+	if got := req.Header.Get("Prefer"); got != "odata.maxpagesize=100" {
+		t.Errorf("got Prefer header %q, want %q", got, "odata.maxpagesize=100")
+	}
+}
@@ -0,0 +1,446 @@
+package odata
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query builds the URL, relative to the service root, for a request to a resource with, optionally, any of the
+// system query options. A Query without resource is used to specify the nested options of an expanded
+// navigation property.
+type Query struct {
+	resource string
+	apply    string
+	filter   Expr
+	search   string
+	selects  []string
+	expands  []expandItem
+	orderBy  []string
+	skip     int
+	top      int
+	hasSkip  bool
+	hasTop   bool
+	count    bool
+}
+
+type expandItem struct {
+	path    string
+	options *Query
+}
+
+// NewQuery creates a new query for the resource with the passed path, e.g. "Customers" or "Cubes('Sales')/Views"
+func NewQuery(resource string) *Query {
+	return &Query{resource: resource}
+}
+
+// Select adds the passed properties to the $select query option
+func (q *Query) Select(properties ...string) *Query {
+	q.selects = append(q.selects, properties...)
+	return q
+}
+
+// Filter sets the $filter query option, combining it, using and, with any previously set filter
+func (q *Query) Filter(expr Expr) *Query {
+	if q.filter != nil {
+		expr = And(q.filter, expr)
+	}
+	q.filter = expr
+	return q
+}
+
+// OrderBy adds the passed properties, in ascending order, to the $orderby query option
+func (q *Query) OrderBy(properties ...string) *Query {
+	q.orderBy = append(q.orderBy, properties...)
+	return q
+}
+
+// OrderByDesc adds the passed properties, in descending order, to the $orderby query option
+func (q *Query) OrderByDesc(properties ...string) *Query {
+	for _, property := range properties {
+		q.orderBy = append(q.orderBy, property+" desc")
+	}
+	return q
+}
+
+// Expand adds the navigation property to the $expand query option, with the, optional, nested query options
+func (q *Query) Expand(navigationProperty string, options *Query) *Query {
+	q.expands = append(q.expands, expandItem{path: navigationProperty, options: options})
+	return q
+}
+
+// Top sets the $top query option, replacing any previously set value
+func (q *Query) Top(n int) *Query {
+	q.top, q.hasTop = n, true
+	return q
+}
+
+// Skip sets the $skip query option, replacing any previously set value
+func (q *Query) Skip(n int) *Query {
+	q.skip, q.hasSkip = n, true
+	return q
+}
+
+// Count sets the $count query option, replacing any previously set value
+func (q *Query) Count(count bool) *Query {
+	q.count = count
+	return q
+}
+
+// Apply sets the $apply query option to the passed set of transformations, e.g. "groupby((Country))"
+func (q *Query) Apply(transformations string) *Query {
+	q.apply = transformations
+	return q
+}
+
+// Search sets the $search query option to the passed search expression
+func (q *Query) Search(expression string) *Query {
+	q.search = expression
+	return q
+}
+
+// options returns the query options, their values escaped using the passed escape function, separated by the
+// passed separator
+func (q *Query) options(separator string, escape func(string) string) string {
+	var options []string
+	add := func(name, value string) {
+		options = append(options, name+"="+escape(value))
+	}
+	if q.apply != "" {
+		add("$apply", q.apply)
+	}
+	if q.filter != nil {
+		add("$filter", q.filter.String())
+	}
+	if q.search != "" {
+		add("$search", q.search)
+	}
+	if len(q.selects) > 0 {
+		add("$select", strings.Join(q.selects, ","))
+	}
+	if len(q.expands) > 0 {
+		expands := make([]string, len(q.expands))
+		for i, expand := range q.expands {
+			expands[i] = escape(expand.path)
+			if expand.options != nil {
+				// Nested options are separated by semicolons and have their values escaped individually, so any
+				// semicolon or parenthesis in a literal can't end the option, or the nested options, early
+				if nested := expand.options.options(";", escapeNestedValue); nested != "" {
+					expands[i] += "(" + nested + ")"
+				}
+			}
+		}
+		// The paths and nested options are escaped already
+		options = append(options, "$expand="+strings.Join(expands, ","))
+	}
+	if len(q.orderBy) > 0 {
+		add("$orderby", strings.Join(q.orderBy, ","))
+	}
+	if q.hasSkip {
+		add("$skip", strconv.Itoa(q.skip))
+	}
+	if q.hasTop {
+		add("$top", strconv.Itoa(q.top))
+	}
+	if q.count {
+		add("$count", "true")
+	}
+	return strings.Join(options, separator)
+}
+
+// String renders the canonical URL, relative to the service root, for the query
+func (q *Query) String() string {
+	options := q.options("&", escapeQueryValue)
+	if options == "" {
+		return q.resource
+	}
+	return q.resource + "?" + options
+}
+
+// escapeQueryValue percent-encodes the characters in a query option value that would otherwise either break the
+// URL or change the meaning of the query. Unlike url.QueryEscape it leaves the characters that have a meaning in
+// OData expressions, like quotes, parentheses and commas, alone and encodes spaces as %20 instead of +.
+func escapeQueryValue(value string) string {
+	return percentEncode(value, "-._~!$'()*,;:@/?=")
+}
+
+// escapeNestedValue escapes the value of a nested query option, like escapeQueryValue does, but also percent-encodes
+// semicolons, which separate the nested options, and, in string literals, parentheses, which enclose them
+func escapeNestedValue(value string) string {
+	var out strings.Builder
+	inLiteral := false
+	start := 0
+	flush := func(end int) {
+		if inLiteral {
+			out.WriteString(percentEncode(value[start:end], "-._~!$'*,:@/?="))
+		} else {
+			out.WriteString(percentEncode(value[start:end], "-._~!$'()*,:@/?="))
+		}
+		start = end
+	}
+	for i := 0; i < len(value); i++ {
+		// A doubled quote in a literal simply ends and starts the literal again
+		if value[i] == '\'' {
+			flush(i)
+			inLiteral = !inLiteral
+		}
+	}
+	flush(len(value))
+	return out.String()
+}
+
+// Expr is a node in the expression tree of a $filter, or any other, expression
+type Expr interface {
+	String() string
+	precedence() int
+}
+
+// Precedence of the various expressions, higher binds tighter
+const (
+	precedenceOr = iota + 1
+	precedenceAnd
+	precedenceComparison
+	precedenceNot
+	precedencePrimary
+)
+
+type propertyExpr struct {
+	path string
+}
+
+func (e propertyExpr) String() string  { return e.path }
+func (e propertyExpr) precedence() int { return precedencePrimary }
+
+// Prop returns an expression referring to a property, or property path like "Customer/Country"
+func Prop(path string) Expr {
+	return propertyExpr{path: path}
+}
+
+type literalExpr struct {
+	text string
+}
+
+func (e literalExpr) String() string  { return e.text }
+func (e literalExpr) precedence() int { return precedencePrimary }
+
+// String returns a string literal, single quotes in the value are escaped by doubling them
+func String(value string) Expr {
+	return literalExpr{text: QuoteString(value)}
+}
+
+// Int returns an integer literal
+func Int(value int64) Expr {
+	return literalExpr{text: strconv.FormatInt(value, 10)}
+}
+
+// Float returns a floating point literal
+func Float(value float64) Expr {
+	return literalExpr{text: strconv.FormatFloat(value, 'g', -1, 64)}
+}
+
+// Bool returns a boolean literal
+func Bool(value bool) Expr {
+	return literalExpr{text: strconv.FormatBool(value)}
+}
+
+// Null returns the null literal
+func Null() Expr {
+	return literalExpr{text: "null"}
+}
+
+// DateTime returns an Edm.DateTimeOffset literal
+func DateTime(value time.Time) Expr {
+	return literalExpr{text: value.Format(time.RFC3339Nano)}
+}
+
+// QuoteString returns the value as an OData string literal, enclosed in single quotes with any single quote in
+// the value doubled
+func QuoteString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+type binaryExpr struct {
+	op          string
+	left, right Expr
+	prec        int
+}
+
+func (e binaryExpr) String() string {
+	return parenthesize(e.left, e.prec) + " " + e.op + " " + parenthesize(e.right, e.prec+1)
+}
+func (e binaryExpr) precedence() int { return e.prec }
+
+// parenthesize renders the operand, enclosing it in parentheses if it binds less tight than required
+func parenthesize(operand Expr, prec int) string {
+	if operand.precedence() < prec {
+		return "(" + operand.String() + ")"
+	}
+	return operand.String()
+}
+
+// Eq returns the expression left eq right
+func Eq(left, right Expr) Expr { return binaryExpr{"eq", left, right, precedenceComparison} }
+
+// Ne returns the expression left ne right
+func Ne(left, right Expr) Expr { return binaryExpr{"ne", left, right, precedenceComparison} }
+
+// Gt returns the expression left gt right
+func Gt(left, right Expr) Expr { return binaryExpr{"gt", left, right, precedenceComparison} }
+
+// Ge returns the expression left ge right
+func Ge(left, right Expr) Expr { return binaryExpr{"ge", left, right, precedenceComparison} }
+
+// Lt returns the expression left lt right
+func Lt(left, right Expr) Expr { return binaryExpr{"lt", left, right, precedenceComparison} }
+
+// Le returns the expression left le right
+func Le(left, right Expr) Expr { return binaryExpr{"le", left, right, precedenceComparison} }
+
+// And returns the conjunction of the passed expressions
+func And(exprs ...Expr) Expr {
+	return logical("and", precedenceAnd, exprs)
+}
+
+// Or returns the disjunction of the passed expressions
+func Or(exprs ...Expr) Expr {
+	return logical("or", precedenceOr, exprs)
+}
+
+func logical(op string, prec int, exprs []Expr) Expr {
+	if len(exprs) == 0 {
+		return Bool(op == "and")
+	}
+	expr := exprs[0]
+	for _, next := range exprs[1:] {
+		expr = binaryExpr{op, expr, next, prec}
+	}
+	return expr
+}
+
+type notExpr struct {
+	operand Expr
+}
+
+func (e notExpr) String() string  { return "not " + parenthesize(e.operand, precedencePrimary) }
+func (e notExpr) precedence() int { return precedenceNot }
+
+// Not returns the negation of the passed expression
+func Not(expr Expr) Expr {
+	return notExpr{operand: expr}
+}
+
+type inExpr struct {
+	operand Expr
+	values  []Expr
+}
+
+func (e inExpr) String() string {
+	values := make([]string, len(e.values))
+	for i, value := range e.values {
+		values[i] = value.String()
+	}
+	return parenthesize(e.operand, precedencePrimary) + " in (" + strings.Join(values, ",") + ")"
+}
+func (e inExpr) precedence() int { return precedenceComparison }
+
+// In returns the expression operand in (values...)
+func In(operand Expr, values ...Expr) Expr {
+	return inExpr{operand: operand, values: values}
+}
+
+type callExpr struct {
+	function string
+	args     []Expr
+}
+
+func (e callExpr) String() string {
+	args := make([]string, len(e.args))
+	for i, arg := range e.args {
+		args[i] = arg.String()
+	}
+	return e.function + "(" + strings.Join(args, ",") + ")"
+}
+func (e callExpr) precedence() int { return precedencePrimary }
+
+// Call returns a call to the passed canonical, or custom, function with the passed arguments
+func Call(function string, args ...Expr) Expr {
+	return callExpr{function: function, args: args}
+}
+
+// Contains returns the expression contains(expr,substring)
+func Contains(expr Expr, substring string) Expr {
+	return Call("contains", expr, String(substring))
+}
+
+// StartsWith returns the expression startswith(expr,prefix)
+func StartsWith(expr Expr, prefix string) Expr {
+	return Call("startswith", expr, String(prefix))
+}
+
+// EndsWith returns the expression endswith(expr,suffix)
+func EndsWith(expr Expr, suffix string) Expr {
+	return Call("endswith", expr, String(suffix))
+}
+
+type lambdaExpr struct {
+	path, operator, variable string
+	predicate                Expr
+}
+
+func (e lambdaExpr) String() string {
+	if e.predicate == nil {
+		return e.path + "/" + e.operator + "()"
+	}
+	return e.path + "/" + e.operator + "(" + e.variable + ":" + e.predicate.String() + ")"
+}
+func (e lambdaExpr) precedence() int { return precedencePrimary }
+
+// Any returns the expression path/any(variable:predicate), or path/any() if predicate is nil
+func Any(path, variable string, predicate Expr) Expr {
+	return lambdaExpr{path: path, operator: "any", variable: variable, predicate: predicate}
+}
+
+// All returns the expression path/all(variable:predicate)
+func All(path, variable string, predicate Expr) Expr {
+	return lambdaExpr{path: path, operator: "all", variable: variable, predicate: predicate}
+}
+
+// StringKey returns the key predicate, e.g. ('Sales'), addressing an entity with the passed string key in a
+// resource path. Single quotes are doubled, as required for OData string literals, and any character that isn't
+// allowed in a path segment, or that has a special meaning in a URL, like space, '#', '%', '/' or '?', is
+// percent-encoded.
+func StringKey(value string) string {
+	return "(" + PathLiteral(value) + ")"
+}
+
+// PathLiteral returns the value as a string literal, escaped the same way StringKey does, to be used in a resource
+// path, e.g. as a value in a key predicate with multiple key properties
+func PathLiteral(value string) string {
+	return escapePathSegment(QuoteString(value))
+}
+
+// escapePathSegment percent-encodes all characters not allowed as is in a path segment. Note that we also
+// encode '+' as some services decode it to a space.
+func escapePathSegment(segment string) string {
+	return percentEncode(segment, "-._~!$&'()*,;=:@")
+}
+
+// percentEncode percent-encodes every byte in s that is neither alphanumeric nor one of the passed safe characters
+func percentEncode(s, safe string) string {
+	const hex = "0123456789ABCDEF"
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			out.WriteByte(c)
+		case strings.IndexByte(safe, c) >= 0:
+			out.WriteByte(c)
+		default:
+			out.WriteByte('%')
+			out.WriteByte(hex[c>>4])
+			out.WriteByte(hex[c&15])
+		}
+	}
+	return out.String()
+}
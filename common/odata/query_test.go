@@ -0,0 +1,96 @@
+package odata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStringKey(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Sales", "('Sales')"},
+		{"O'Neil", "('O''Neil')"},
+		{"Sales Plan", "('Sales%20Plan')"},
+		{"}ElementAttributes_Customers", "('%7DElementAttributes_Customers')"},
+		{"a/b?c#d%e", "('a%2Fb%3Fc%23d%25e')"},
+		{"1+1", "('1%2B1')"},
+		{"Köln", "('K%C3%B6ln')"},
+		{"", "('')"},
+	}
+	for _, test := range tests {
+		if got := StringKey(test.value); got != test.want {
+			t.Errorf("StringKey(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestQueryString(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *Query
+		want  string
+	}{
+		{
+			name:  "resource only",
+			query: NewQuery("Cubes"),
+			want:  "Cubes",
+		},
+		{
+			name:  "select, order and paging",
+			query: NewQuery("Cubes").Select("Name", "Rules").OrderBy("Name").Skip(10).Top(5).Count(true),
+			want:  "Cubes?$select=Name,Rules&$orderby=Name&$skip=10&$top=5&$count=true",
+		},
+		{
+			name:  "filter with quotes, spaces and ampersand",
+			query: NewQuery("Customers").Filter(Eq(Prop("CompanyName"), String("O'Neil & Sons"))),
+			want:  "Customers?$filter=CompanyName%20eq%20'O''Neil%20%26%20Sons'",
+		},
+		{
+			name:  "nested expand",
+			query: NewQuery("Orders").Select("OrderID").Expand("Order_Details", NewQuery("").Select("ProductID", "Quantity").Top(2)),
+			want:  "Orders?$select=OrderID&$expand=Order_Details($select=ProductID,Quantity;$top=2)",
+		},
+		{
+			name:  "nested filter with semicolon and parentheses in a literal",
+			query: NewQuery("Dimensions").Expand("Hierarchies", NewQuery("").Filter(Eq(Prop("Name"), String("a;b (c)"))).Select("Name")),
+			want:  "Dimensions?$expand=Hierarchies($filter=Name%20eq%20'a%3Bb%20%28c%29';$select=Name)",
+		},
+		{
+			name:  "nested filter with function call and nested expand",
+			query: NewQuery("Cubes").Expand("Dimensions", NewQuery("").Filter(StartsWith(Prop("Name"), "O'Ne)")).Expand("Hierarchies", NewQuery("").Select("Name").Top(1))),
+			want:  "Cubes?$expand=Dimensions($filter=startswith(Name,'O''Ne%29');$expand=Hierarchies($select=Name;$top=1))",
+		},
+		{
+			name:  "repeated setters replace the previous value",
+			query: NewQuery("Cubes").Top(5).Top(10).Skip(1).Skip(2).Count(true).Count(true),
+			want:  "Cubes?$skip=2&$top=10&$count=true",
+		},
+		{
+			name:  "repeated nested setters replace the previous value",
+			query: NewQuery("Orders").Expand("Order_Details", NewQuery("").Top(1).Top(3).Count(true).Count(true)),
+			want:  "Orders?$expand=Order_Details($top=3;$count=true)",
+		},
+		{
+			name:  "expand without options",
+			query: NewQuery("Dimensions").Expand("Hierarchies", nil),
+			want:  "Dimensions?$expand=Hierarchies",
+		},
+		{
+			name:  "logical operators and precedence",
+			query: NewQuery("Elements").Filter(And(Or(Eq(Prop("Type"), String("Numeric")), Eq(Prop("Type"), String("String"))), Not(StartsWith(Prop("Name"), "}")))),
+			want:  "Elements?$filter=(Type%20eq%20'Numeric'%20or%20Type%20eq%20'String')%20and%20not%20startswith(Name,'%7D')",
+		},
+		{
+			name:  "date time literal",
+			query: NewQuery("Orders").Filter(Ge(Prop("OrderDate"), DateTime(time.Date(1997, 1, 1, 0, 0, 0, 0, time.UTC)))),
+			want:  "Orders?$filter=OrderDate%20ge%201997-01-01T00:00:00Z",
+		},
+	}
+	for _, test := range tests {
+		if got := test.query.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package odata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy defines if, and how, requests failing with a transient error get retried. Requests using an
+// idempotent method (GET, HEAD, OPTIONS, PUT and DELETE) are retried automatically, requests using any other
+// method, like POST, are only retried if the request's context was marked using RetryNonIdempotent.
+// Note: retrying a PUT or DELETE is only safe as long as the request isn't conditional, e.g. using If-Match, as the
+// retry of a request that did succeed, but the response of which got lost, would fail the condition.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of times a request gets retried
+	MaxRetries int
+	// InitialBackoff is the time to wait before the first retry, defaults to 500ms
+	InitialBackoff time.Duration
+	// MaxBackoff caps the, exponentially growing, time to wait between retries, as well as any delay requested by
+	// the service using the Retry-After header, defaults to 30s
+	MaxBackoff time.Duration
+	// Multiplier is the factor the backoff grows with after every retry, defaults to 2
+	Multiplier float64
+	// Jitter is the fraction, between 0 and 1, of the backoff that gets randomized to avoid retries in lock step
+	Jitter float64
+	// StatusCodes are the response status codes considered transient, defaults to 429, 502, 503 and 504
+	StatusCodes []int
+}
+
+// DefaultRetryPolicy returns a retry policy retrying transient failures up to 5 times with exponential backoff
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{MaxRetries: 5, Jitter: 0.2}
+}
+
+type retryNonIdempotentKey struct{}
+
+// RetryNonIdempotent returns a context which marks requests executed with it as safe to retry even though the
+// method used, typically POST, isn't idempotent by definition. Only use it for requests that are safe to repeat,
+// for example a tm1.Update that sets values as opposed to one that uses "+" spreading to increment them.
+func RetryNonIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryNonIdempotentKey{}, true)
+}
+
+// isRetryable returns if the request may be retried given its method and context
+func isRetryable(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	retry, _ := req.Context().Value(retryNonIdempotentKey{}).(bool)
+	return retry
+}
+
+// isTransientStatus returns if the status code is one the policy considers transient
+func (policy *RetryPolicy) isTransientStatus(statusCode int) bool {
+	statusCodes := policy.StatusCodes
+	if statusCodes == nil {
+		statusCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	for _, code := range statusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the time to wait before the retry with the passed, zero based, index
+func (policy *RetryPolicy) backoff(retry int) time.Duration {
+	initial := policy.InitialBackoff
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+	maxBackoff := policy.maxBackoff()
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	backoff := float64(initial) * math.Pow(multiplier, float64(retry))
+	if backoff > float64(maxBackoff) {
+		backoff = float64(maxBackoff)
+	}
+	if policy.Jitter > 0 {
+		jitter := math.Min(policy.Jitter, 1)
+		backoff = backoff * (1 - jitter + 2*jitter*rand.Float64())
+	}
+	return time.Duration(backoff)
+}
+
+// maxBackoff returns the maximum time to wait between retries
+func (policy *RetryPolicy) maxBackoff() time.Duration {
+	if policy.MaxBackoff <= 0 {
+		return 30 * time.Second
+	}
+	return policy.MaxBackoff
+}
+
+// retryAfter returns the delay requested by the service using the Retry-After header, if any
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// RateLimiter limits the rate at which a client sends requests using a token bucket, allowing bursts of up to
+// Burst requests while, on average, not exceeding Rate requests per second
+type RateLimiter struct {
+	Rate  float64
+	Burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a new rate limiter allowing rate requests per second with bursts of up to burst requests
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{Rate: rate, Burst: burst, tokens: float64(burst)}
+}
+
+// Wait blocks until the limiter allows a request to be sent or the context gets cancelled
+func (limiter *RateLimiter) Wait(ctx context.Context) error {
+	if limiter.Rate <= 0 {
+		return nil
+	}
+	limiter.mu.Lock()
+	now := time.Now()
+	if !limiter.last.IsZero() {
+		limiter.tokens = math.Min(float64(limiter.Burst), limiter.tokens+now.Sub(limiter.last).Seconds()*limiter.Rate)
+	}
+	limiter.last = now
+	// Take our token, going into debt if there is none, and wait until the debt is paid off
+	limiter.tokens--
+	wait := time.Duration(0)
+	if limiter.tokens < 0 {
+		wait = time.Duration(-limiter.tokens / limiter.Rate * float64(time.Second))
+	}
+	limiter.mu.Unlock()
+	if wait == 0 {
+		return nil
+	}
+	return sleep(ctx, wait)
+}
+
+// sleep waits for the passed duration unless the context gets cancelled first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// do executes the request, applying the client's rate limiter and retry policy, if any
+func (client *Client) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for retry := 0; ; retry++ {
+		if client.RateLimiter != nil {
+			if err := client.RateLimiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		// Every attempt needs its own request, with a fresh body, as the previous attempt consumed it
+		attempt := req
+		if retry > 0 {
+			attempt = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attempt.Body = body
+			}
+		}
+		resp, err := client.Do(attempt)
+
+		// Figure out if we should, and are allowed to, retry
+		policy := client.RetryPolicy
+		if policy == nil || retry >= policy.MaxRetries || !isRetryable(req) {
+			return resp, err
+		}
+		var delay time.Duration
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return nil, err
+			}
+			delay = policy.backoff(retry)
+		} else if policy.isTransientStatus(resp.StatusCode) {
+			delay = policy.backoff(retry)
+			// Honor the delay the service asks for, but don't let it stall us for longer than the policy allows
+			if after := retryAfter(resp); after > delay {
+				delay = time.Duration(math.Min(float64(after), float64(policy.maxBackoff())))
+			}
+			// Drain and close the body of the response we are discarding so the connection can be reused
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		} else {
+			return resp, nil
+		}
+		if Verbose == true {
+			if err != nil {
+				fmt.Println("Retrying", req.Method, req.URL, "in", delay, "after:", err)
+			} else {
+				fmt.Println("Retrying", req.Method, req.URL, "in", delay, "after:", resp.Status)
+			}
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
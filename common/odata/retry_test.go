@@ -0,0 +1,107 @@
+package odata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		retry  int
+		want   time.Duration
+	}{
+		{"default initial backoff", RetryPolicy{}, 0, 500 * time.Millisecond},
+		{"default multiplier", RetryPolicy{}, 3, 4 * time.Second},
+		{"custom initial backoff and multiplier", RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 3}, 2, 900 * time.Millisecond},
+		{"capped at default max backoff", RetryPolicy{}, 10, 30 * time.Second},
+		{"capped at max backoff", RetryPolicy{MaxBackoff: time.Second}, 5, time.Second},
+	}
+	for _, test := range tests {
+		if got := test.policy.backoff(test.retry); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(0); got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("got %v, want a backoff between 800ms and 1.2s", got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"soon", 0},
+	}
+	for _, test := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if test.header != "" {
+			resp.Header.Set("Retry-After", test.header)
+		}
+		if got := retryAfter(resp); got != test.want {
+			t.Errorf("Retry-After %q: got %v, want %v", test.header, got, test.want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		method string
+		ctx    context.Context
+		want   bool
+	}{
+		{"GET", context.Background(), true},
+		{"PUT", context.Background(), true},
+		{"DELETE", context.Background(), true},
+		{"POST", context.Background(), false},
+		{"PATCH", context.Background(), false},
+		{"POST", RetryNonIdempotent(context.Background()), true},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequestWithContext(test.ctx, test.method, "http://localhost/", nil)
+		if got := isRetryable(req); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.method, got, test.want)
+		}
+	}
+}
+
+func TestRetryAfterCappedAtMaxBackoff(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			// Ask for an hour, which the policy should cap at its max backoff
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	quiet(t)
+	client := &Client{RetryPolicy: &RetryPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := client.ExecuteGETRequestContext(ctx, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || attempts != 2 {
+		t.Errorf("got status %d after %d attempts, want %d after 2", resp.StatusCode, attempts, http.StatusOK)
+	}
+}
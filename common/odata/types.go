@@ -0,0 +1,36 @@
+package odata
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// dateTimeOffsetLayouts are the layouts accepted for Edm.DateTimeOffset values. Next to RFC3339 we accept values
+// without seconds, which some services, TM1 for one, return if the seconds are 0.
+var dateTimeOffsetLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04Z07:00"}
+
+// DateTimeOffset represents an Edm.DateTimeOffset value, which, unlike time.Time, also unmarshals from values in
+// which the seconds are omitted
+type DateTimeOffset struct {
+	time.Time
+}
+
+// UnmarshalJSON unmarshals an Edm.DateTimeOffset value, leaving the time untouched if the value is null
+func (t *DateTimeOffset) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	var err error
+	for _, layout := range dateTimeOffsetLayouts {
+		var parsed time.Time
+		if parsed, err = time.Parse(layout, value); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,303 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// Member defines the structure of a single member, an element of a hierarchy, in a tuple on an axis of a cellset
+type Member struct {
+	Name       string
+	UniqueName string
+}
+
+// Tuple defines the structure of a single tuple, one member of every hierarchy on the axis, on an axis of a cellset
+type Tuple struct {
+	Ordinal int
+	Members []Member
+}
+
+// Axis defines the structure of a single axis of a cellset, the hierarchies on the axis and its tuples
+type Axis struct {
+	Ordinal     int
+	Hierarchies []HierarchyRef
+	Tuples      []Tuple
+}
+
+// Cell defines the structure of a single cell in a cellset. The Value, depending on the cell, either is a number,
+// a string or, for cells without a value, nil.
+type Cell struct {
+	Ordinal        int
+	Value          interface{}
+	FormattedValue string
+}
+
+// Number returns the numeric value of the cell, or 0 if the cell has no numeric value
+func (cell *Cell) Number() float64 {
+	if number, ok := cell.Value.(float64); ok {
+		return number
+	}
+	return 0
+}
+
+// String returns the string value of the cell, or the formatted value if the cell has a numeric value
+func (cell *Cell) String() string {
+	if text, ok := cell.Value.(string); ok {
+		return text
+	}
+	return cell.FormattedValue
+}
+
+// CellSet holds the result of executing an MDX query. Axes holds the axes of the query, in order, columns first,
+// rows second. The Slicer, if the query has any, holds the single tuple of members of the hierarchies that aren't on
+// any of the axes, which are either specified in the WHERE clause of the query or default members. Cells holds all
+// cells, in order of their ordinal, in which the tuples on the first axis vary the fastest.
+type CellSet struct {
+	ID     string
+	Axes   []*Axis
+	Slicer *Axis
+	Cells  []Cell
+}
+
+// cellSetRead defines the structure of a single Cellset entity as read from the server, with its axes and cells
+// expanded
+type cellSetRead struct {
+	ID   string
+	Axes []struct {
+		Ordinal     int
+		Cardinality int
+		Hierarchies []struct {
+			Name      string
+			Dimension struct {
+				Name string
+			}
+		}
+		Tuples []Tuple
+	}
+	Cells []Cell
+}
+
+// cellSetQuery returns the query options to expand a cellset with everything we read of it
+func cellSetQuery(resource string) *odata.Query {
+	return odata.NewQuery(resource).Select("ID").
+		Expand("Axes", odata.NewQuery("").Select("Ordinal", "Cardinality").
+			Expand("Hierarchies", odata.NewQuery("").Select("Name").Expand("Dimension", odata.NewQuery("").Select("Name"))).
+			Expand("Tuples", odata.NewQuery("").Select("Ordinal").Expand("Members", odata.NewQuery("").Select("Name", "UniqueName")))).
+		Expand("Cells", odata.NewQuery("").Select("Ordinal", "Value", "FormattedValue"))
+}
+
+// ExecuteMDX executes the MDX query on the TM1 server and returns the resulting cellset, with all its axes and cells.
+// The cellset is deleted from the server once it has been read.
+func ExecuteMDX(ctx context.Context, client *odata.Client, root, mdx string) (*CellSet, error) {
+	jMDX, err := json.Marshal(map[string]string{"MDX": mdx})
+	if err != nil {
+		return nil, err
+	}
+	return executeCellSet(ctx, client, root, "ExecuteMDX", string(jMDX), queryAxisCount(mdx))
+}
+
+// executeCellSet executes the action, returning a cellset, on the TM1 server, reads the resulting cellset, with its
+// query having the specified number of axes, and deletes it from the server again
+func executeCellSet(ctx context.Context, client *odata.Client, root, action, body string, axisCount int) (*CellSet, error) {
+	urlStr := root + cellSetQuery(action).String()
+	resp, err := client.ExecutePOSTRequestContext(ctx, urlStr, "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	err = odata.ValidateStatusCode(resp, 201, func() string {
+		return "Failed to execute " + action + "."
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &odata.ResponseError{URL: urlStr, Err: err}
+	}
+	res := &cellSetRead{}
+	if err = json.Unmarshal(data, res); err != nil {
+		return nil, &odata.ResponseError{URL: urlStr, Err: err}
+	}
+
+	// The cellset is kept on the server, for the duration of the session, until it is deleted. Since we have all
+	// we need from it, we delete it straight away. Failing to do so doesn't invalidate the result we've got, which
+	// is why any error doing so is ignored.
+	DeleteCellSet(ctx, client, root, res.ID)
+
+	return res.cellSet(axisCount)
+}
+
+// DeleteCellSet deletes the cellset with the specified ID from the TM1 server
+func DeleteCellSet(ctx context.Context, client *odata.Client, root, id string) error {
+	resp, err := client.ExecuteDELETERequestContext(ctx, root+CellSetPath(id))
+	return expectStatus(resp, err, 204, func() string {
+		return "Failed to delete cellset '" + id + "'."
+	})
+}
+
+// cellSet returns the cellset, as read from the server, as a CellSet. The server returns the slicer, if there is
+// one, as the last axis, following the axes of the query, so if there are more axes than the query has, the number
+// of which needs to be passed, the last one is the slicer.
+func (read *cellSetRead) cellSet(axisCount int) (*CellSet, error) {
+	if len(read.Axes) != axisCount && len(read.Axes) != axisCount+1 {
+		return nil, fmt.Errorf("cellset '%s' has %d axes but its query has %d", read.ID, len(read.Axes), axisCount)
+	}
+	cellSet := &CellSet{ID: read.ID}
+	cellCount := 1
+	for i, readAxis := range read.Axes {
+		if len(readAxis.Tuples) != readAxis.Cardinality {
+			return nil, fmt.Errorf("axis %d of cellset '%s' has %d tuples but a cardinality of %d", readAxis.Ordinal, read.ID, len(readAxis.Tuples), readAxis.Cardinality)
+		}
+		axis := &Axis{Ordinal: readAxis.Ordinal, Hierarchies: make([]HierarchyRef, len(readAxis.Hierarchies)), Tuples: readAxis.Tuples}
+		for j, hierarchy := range readAxis.Hierarchies {
+			axis.Hierarchies[j] = HierarchyRef{Dimension: hierarchy.Dimension.Name, Hierarchy: hierarchy.Name}
+		}
+		if i < axisCount {
+			cellSet.Axes = append(cellSet.Axes, axis)
+			cellCount *= readAxis.Cardinality
+		} else {
+			cellSet.Slicer = axis
+		}
+	}
+
+	// Cells are returned in order of their ordinal, but don't rely on that, nor on every cell being returned
+	cellSet.Cells = make([]Cell, cellCount)
+	for i := range cellSet.Cells {
+		cellSet.Cells[i].Ordinal = i
+	}
+	for _, cell := range read.Cells {
+		if cell.Ordinal < 0 || cell.Ordinal >= cellCount {
+			return nil, fmt.Errorf("cell %d of cellset '%s' is out of range, the cellset has %d cells", cell.Ordinal, read.ID, cellCount)
+		}
+		cellSet.Cells[cell.Ordinal] = cell
+	}
+	return cellSet, nil
+}
+
+// Cell returns the cell at the intersection of the tuples, on each of the axes, with the specified ordinals, or nil
+// if there is no such cell. Like with MDX the ordinal of the tuple on the first axis, the columns, comes first.
+func (cellSet *CellSet) Cell(ordinals ...int) *Cell {
+	if len(ordinals) != len(cellSet.Axes) {
+		return nil
+	}
+	ordinal, stride := 0, 1
+	for i, axis := range cellSet.Axes {
+		if ordinals[i] < 0 || ordinals[i] >= len(axis.Tuples) {
+			return nil
+		}
+		ordinal += ordinals[i] * stride
+		stride *= len(axis.Tuples)
+	}
+	return &cellSet.Cells[ordinal]
+}
+
+// Grid defines the structure of the cells of a cellset, with at most two axes, as a table. The columns are the
+// tuples on the first axis, the rows the tuples on the second axis. A cellset without a second axis, or without any
+// axis at all, results in a single row, or column, with an empty tuple.
+type Grid struct {
+	Columns []Tuple
+	Rows    []Tuple
+	Slicer  []Member
+	Cells   [][]Cell
+}
+
+// Grid returns the cells of the cellset as a table, with a row per tuple on the rows axis
+func (cellSet *CellSet) Grid() (*Grid, error) {
+	if len(cellSet.Axes) > 2 {
+		return nil, fmt.Errorf("cellset '%s' has %d axes, a grid can have at most 2", cellSet.ID, len(cellSet.Axes))
+	}
+	grid := &Grid{Columns: []Tuple{{}}, Rows: []Tuple{{}}}
+	if len(cellSet.Axes) > 0 {
+		grid.Columns = cellSet.Axes[0].Tuples
+	}
+	if len(cellSet.Axes) > 1 {
+		grid.Rows = cellSet.Axes[1].Tuples
+	}
+	if cellSet.Slicer != nil && len(cellSet.Slicer.Tuples) > 0 {
+		grid.Slicer = cellSet.Slicer.Tuples[0].Members
+	}
+	grid.Cells = make([][]Cell, len(grid.Rows))
+	for row := range grid.Rows {
+		grid.Cells[row] = cellSet.Cells[row*len(grid.Columns) : (row+1)*len(grid.Columns)]
+	}
+	return grid, nil
+}
+
+// queryAxisCount returns the number of axes, the number of axis specifications in the SELECT clause, of the MDX
+// query. Only the SELECT clause of the query itself counts, not the ones of any subquery in its FROM clause, nor
+// anything in its WITH clause, hence only keywords outside of any parentheses or braces are considered. Names in
+// square brackets, strings and comments are skipped, as they could contain anything.
+func queryAxisCount(mdx string) int {
+	count, depth, selecting := 0, 0, false
+	runes := []rune(mdx)
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case runes[i] == '[':
+			i = skipQuoted(runes, i, ']')
+		case runes[i] == '"' || runes[i] == '\'':
+			i = skipQuoted(runes, i, runes[i])
+		case runes[i] == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			i++
+		case (runes[i] == '/' || runes[i] == '-') && i+1 < len(runes) && runes[i+1] == runes[i]:
+			for ; i < len(runes) && runes[i] != '\n'; i++ {
+			}
+		case runes[i] == '(' || runes[i] == '{':
+			depth++
+		case runes[i] == ')' || runes[i] == '}':
+			depth--
+		case unicode.IsLetter(runes[i]) || runes[i] == '_':
+			start := i
+			for ; i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_'); i++ {
+			}
+			if depth != 0 {
+				continue
+			}
+			switch strings.ToUpper(string(runes[start : i+1])) {
+			case "SELECT":
+				selecting = true
+			case "ON":
+				if selecting == true {
+					count++
+				}
+			case "FROM":
+				// Axes are only specified in the SELECT clause, which ends with the FROM clause
+				return count
+			}
+		}
+	}
+	return count
+}
+
+// skipQuoted returns the index of the character closing the name, or string, starting at the specified index. A
+// closing character within the name, or string, itself is doubled.
+func skipQuoted(runes []rune, start int, closing rune) int {
+	for i := start + 1; i < len(runes); i++ {
+		if runes[i] == closing {
+			if i+1 < len(runes) && runes[i+1] == closing {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(runes)
+}
+
+// MDXName returns the name as an MDX name, enclosed in square brackets with any ']' in the name doubled
+func MDXName(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+// MDXMember returns the unique name of the element in the specified hierarchy, to be used as a member in MDX
+func MDXMember(dimension, hierarchy, element string) string {
+	return MDXName(dimension) + "." + MDXName(hierarchy) + "." + MDXName(element)
+}
@@ -0,0 +1,293 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+func TestQueryAxisCount(t *testing.T) {
+	tests := []struct {
+		name string
+		mdx  string
+		want int
+	}{
+		{"no axes", "SELECT FROM [Sales]", 0},
+		{"columns", "SELECT {[Measures].[Revenue]} ON COLUMNS FROM [Sales]", 1},
+		{"columns and rows", "SELECT {[Measures].[Revenue]} ON 0, {[Products].Members} ON 1 FROM [Sales]", 2},
+		{"three axes", "select {[A].Members} on 0, {[B].Members} on 1, {[C].Members} on 2 from [Cube]", 3},
+		{"non empty", "SELECT NON EMPTY {[Measures].[Revenue]} ON COLUMNS, NON EMPTY {[Products].Members} ON ROWS FROM [Sales]", 2},
+		{"slicer", "SELECT {[Measures].[Revenue]} ON COLUMNS FROM [Sales] WHERE ([Time].[1997])", 1},
+		{"with member", "WITH MEMBER [Measures].[Margin] AS [Measures].[Revenue] - [Measures].[Cost] SELECT {[Measures].[Margin]} ON 0 FROM [Sales]", 1},
+		{"with set containing a select", "WITH SET [Top] AS {TopCount([Products].Members, 10, [Measures].[Revenue])} SELECT [Top] ON 0 FROM [Sales]", 1},
+		{"subquery", "SELECT {[Measures].[Revenue]} ON 0 FROM (SELECT {[Products].[Chai]} ON 0, {[Time].[1997]} ON 1 FROM [Sales])", 1},
+		{"on in names", "SELECT {[Measures].[ON]} ON 0, {[Products].[Select ON 1]} ON 1 FROM [Sales]", 2},
+		{"on in strings", `WITH MEMBER [Measures].[Label] AS "ON 1 FROM" SELECT {[Measures].[Label]} ON 0 FROM [Sales]`, 1},
+		{"on in comments", "SELECT /* ON 1 */ {[Measures].[Revenue]} ON 0 // ON 2\n-- ON 3\nFROM [Sales]", 1},
+		{"on as part of an identifier", "SELECT {[Products].Members} ON 0, {ONLINE} ON 1 FROM [Sales]", 2},
+	}
+	for _, test := range tests {
+		if got := queryAxisCount(test.mdx); got != test.want {
+			t.Errorf("%s: got %d axes, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+// testCellSetRead returns a cellset, as it would be read from the server, with axes with the specified cardinalities
+// and a cell, with its ordinal as value, for every intersection of the tuples on all but the last of the axes
+func testCellSetRead(t *testing.T, cardinalities ...int) *cellSetRead {
+	var axes []string
+	for i, cardinality := range cardinalities {
+		var tuples []string
+		for j := 0; j < cardinality; j++ {
+			tuples = append(tuples, fmt.Sprintf(`{"Ordinal":%d,"Members":[{"Name":"M%d.%d"}]}`, j, i, j))
+		}
+		axes = append(axes, fmt.Sprintf(`{"Ordinal":%d,"Cardinality":%d,"Hierarchies":[{"Name":"H%d","Dimension":{"Name":"D%d"}}],"Tuples":[%s]}`,
+			i, cardinality, i, i, strings.Join(tuples, ",")))
+	}
+	cellCount := 1
+	for _, cardinality := range cardinalities[:len(cardinalities)-1] {
+		cellCount *= cardinality
+	}
+	var cells []string
+	for i := 0; i < cellCount; i++ {
+		cells = append(cells, fmt.Sprintf(`{"Ordinal":%d,"Value":%d}`, i, i))
+	}
+	read := &cellSetRead{}
+	err := json.Unmarshal([]byte(`{"ID":"test","Axes":[`+strings.Join(axes, ",")+`],"Cells":[`+strings.Join(cells, ",")+`]}`), read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return read
+}
+
+func TestCellSet(t *testing.T) {
+	tests := []struct {
+		name          string
+		cardinalities []int
+		axisCount     int
+		wantErr       bool
+		wantAxes      int
+		wantSlicer    bool
+	}{
+		{"columns and rows without slicer", []int{3, 2, 1}, 3, false, 3, false},
+		{"columns and rows with slicer", []int{3, 2, 1}, 2, false, 2, true},
+		{"only a slicer", []int{1}, 0, false, 0, true},
+		{"more axes than the query", []int{3, 2, 1}, 1, true, 0, false},
+		{"fewer axes than the query", []int{3, 1}, 3, true, 0, false},
+	}
+	for _, test := range tests {
+		cellSet, err := testCellSetRead(t, test.cardinalities...).cellSet(test.axisCount)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: got no error, want one", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: got %v, want no error", test.name, err)
+			continue
+		}
+		if len(cellSet.Axes) != test.wantAxes || (cellSet.Slicer != nil) != test.wantSlicer {
+			t.Errorf("%s: got %d axes and slicer %v, want %d axes and slicer %v", test.name, len(cellSet.Axes), cellSet.Slicer != nil, test.wantAxes, test.wantSlicer)
+		}
+		if cellSet.Slicer != nil && cellSet.Slicer.Hierarchies[0].Dimension != fmt.Sprintf("D%d", len(test.cardinalities)-1) {
+			t.Errorf("%s: got slicer %v, want the last axis", test.name, cellSet.Slicer.Hierarchies)
+		}
+	}
+}
+
+func TestCellSetInvalid(t *testing.T) {
+	read := testCellSetRead(t, 3, 2, 1)
+	read.Axes[0].Cardinality = 4
+	if _, err := read.cellSet(2); err == nil {
+		t.Errorf("tuples not matching the cardinality: got no error, want one")
+	}
+	read = testCellSetRead(t, 3, 2, 1)
+	read.Cells = append(read.Cells, Cell{Ordinal: 6})
+	if _, err := read.cellSet(2); err == nil {
+		t.Errorf("cell out of range: got no error, want one")
+	}
+}
+
+func TestCellSetCell(t *testing.T) {
+	cellSet, err := testCellSetRead(t, 3, 2, 1).cellSet(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ordinals []int
+		want     int
+	}{
+		{[]int{0, 0}, 0},
+		{[]int{2, 0}, 2},
+		{[]int{0, 1}, 3},
+		{[]int{2, 1}, 5},
+		{[]int{3, 0}, -1},
+		{[]int{0, 2}, -1},
+		{[]int{-1, 0}, -1},
+		{[]int{0}, -1},
+		{[]int{0, 0, 0}, -1},
+	}
+	for _, test := range tests {
+		cell := cellSet.Cell(test.ordinals...)
+		if test.want < 0 {
+			if cell != nil {
+				t.Errorf("Cell(%v): got cell %d, want nil", test.ordinals, cell.Ordinal)
+			}
+			continue
+		}
+		if cell == nil || cell.Ordinal != test.want || cell.Number() != float64(test.want) {
+			t.Errorf("Cell(%v): got %v, want cell %d", test.ordinals, cell, test.want)
+		}
+	}
+}
+
+func TestCellSetGrid(t *testing.T) {
+	tests := []struct {
+		name          string
+		cardinalities []int
+		wantErr       bool
+		wantColumns   int
+		wantRows      int
+	}{
+		{"no axes", []int{1}, false, 1, 1},
+		{"columns", []int{3, 1}, false, 3, 1},
+		{"columns and rows", []int{3, 2, 1}, false, 3, 2},
+		{"three axes", []int{3, 2, 2, 1}, true, 0, 0},
+	}
+	for _, test := range tests {
+		cellSet, err := testCellSetRead(t, test.cardinalities...).cellSet(len(test.cardinalities) - 1)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		grid, err := cellSet.Grid()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: got no error, want one", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: got %v, want no error", test.name, err)
+			continue
+		}
+		if len(grid.Columns) != test.wantColumns || len(grid.Rows) != test.wantRows || len(grid.Cells) != test.wantRows {
+			t.Errorf("%s: got %d columns and %d rows, want %d and %d", test.name, len(grid.Columns), len(grid.Rows), test.wantColumns, test.wantRows)
+			continue
+		}
+		if len(grid.Slicer) != 1 {
+			t.Errorf("%s: got slicer %v, want a single member", test.name, grid.Slicer)
+		}
+		for row := range grid.Cells {
+			for column := range grid.Cells[row] {
+				if want := row*test.wantColumns + column; grid.Cells[row][column].Ordinal != want {
+					t.Errorf("%s: got cell %d at row %d, column %d, want %d", test.name, grid.Cells[row][column].Ordinal, row, column, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCellValue(t *testing.T) {
+	tests := []struct {
+		cell       Cell
+		wantNumber float64
+		wantString string
+	}{
+		{Cell{Value: 1234.5, FormattedValue: "1,234.50"}, 1234.5, "1,234.50"},
+		{Cell{Value: "Chai", FormattedValue: "Chai"}, 0, "Chai"},
+		{Cell{Value: nil, FormattedValue: ""}, 0, ""},
+	}
+	for _, test := range tests {
+		if got := test.cell.Number(); got != test.wantNumber {
+			t.Errorf("Number() of %v: got %v, want %v", test.cell.Value, got, test.wantNumber)
+		}
+		if got := test.cell.String(); got != test.wantString {
+			t.Errorf("String() of %v: got %q, want %q", test.cell.Value, got, test.wantString)
+		}
+	}
+}
+
+// recordedCellSet is the response of the TM1 server to an MDX query on the Sales cube, with two products on the
+// rows, two measures on the columns and the year in the slicer
+const recordedCellSet = `{"@odata.context":"$metadata#Cellsets(ID,Axes(Ordinal,Cardinality,Hierarchies(Name,Dimension(Name)),Tuples(Ordinal,Members(Name,UniqueName))),Cells(Ordinal,Value,FormattedValue))/$entity",` +
+	`"ID":"iAAAAC7AjQgEAAAA",` +
+	`"Axes":[` +
+	`{"Ordinal":0,"Cardinality":2,"Hierarchies":[{"Name":"Measures","Dimension":{"Name":"Measures"}}],"Tuples":[` +
+	`{"Ordinal":0,"Members":[{"Name":"Quantity","UniqueName":"[Measures].[Measures].[Quantity]"}]},` +
+	`{"Ordinal":1,"Members":[{"Name":"Revenue","UniqueName":"[Measures].[Measures].[Revenue]"}]}]},` +
+	`{"Ordinal":1,"Cardinality":2,"Hierarchies":[{"Name":"Products","Dimension":{"Name":"Products"}}],"Tuples":[` +
+	`{"Ordinal":0,"Members":[{"Name":"P-1","UniqueName":"[Products].[Products].[P-1]"}]},` +
+	`{"Ordinal":1,"Members":[{"Name":"P-2","UniqueName":"[Products].[Products].[P-2]"}]}]},` +
+	`{"Ordinal":2,"Cardinality":1,"Hierarchies":[{"Name":"Years","Dimension":{"Name":"Time"}}],"Tuples":[` +
+	`{"Ordinal":0,"Members":[{"Name":"1997","UniqueName":"[Time].[Years].[1997]"}]}]}],` +
+	`"Cells":[` +
+	`{"Ordinal":0,"Value":42,"FormattedValue":"42"},` +
+	`{"Ordinal":1,"Value":756,"FormattedValue":"756.00"},` +
+	`{"Ordinal":2,"Value":null,"FormattedValue":""},` +
+	`{"Ordinal":3,"Value":1102.5,"FormattedValue":"1,102.50"}]}`
+
+func TestExecuteMDX(t *testing.T) {
+	verbose := odata.Verbose
+	odata.Verbose = false
+	t.Cleanup(func() { odata.Verbose = verbose })
+
+	mdx := "SELECT {[Measures].[Quantity], [Measures].[Revenue]} ON COLUMNS, NON EMPTY {[Products].[P-1], [Products].[P-2]} ON ROWS FROM [Sales] WHERE ([Time].[Years].[1997])"
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case "POST":
+			var body struct{ MDX string }
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.MDX != mdx {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, recordedCellSet)
+		case "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	cellSet, err := ExecuteMDX(context.Background(), &odata.Client{}, server.URL+"/", mdx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"POST /ExecuteMDX", "DELETE /" + CellSetPath("iAAAAC7AjQgEAAAA")}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+	grid, err := cellSet.Grid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grid.Slicer) != 1 || grid.Slicer[0].Name != "1997" {
+		t.Errorf("got slicer %+v, want 1997", grid.Slicer)
+	}
+	tests := []struct {
+		row, column int
+		product     string
+		measure     string
+		value       string
+	}{
+		{0, 0, "P-1", "Quantity", "42"},
+		{0, 1, "P-1", "Revenue", "756.00"},
+		{1, 0, "P-2", "Quantity", ""},
+		{1, 1, "P-2", "Revenue", "1,102.50"},
+	}
+	for _, test := range tests {
+		product, measure := grid.Rows[test.row].Members[0].Name, grid.Columns[test.column].Members[0].Name
+		if value := grid.Cells[test.row][test.column].String(); product != test.product || measure != test.measure || value != test.value {
+			t.Errorf("cell %d,%d: got %s, %s: %q, want %s, %s: %q", test.row, test.column, product, measure, value, test.product, test.measure, test.value)
+		}
+	}
+}
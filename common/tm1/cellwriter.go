@@ -0,0 +1,153 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// HierarchyRef identifies a hierarchy, by name, in a dimension
+type HierarchyRef struct {
+	Dimension string
+	Hierarchy string
+}
+
+// CellWriter collects updates to cells in a cube, checking that every tuple has exactly one element for every
+// dimension of the cube, and writes them, in one go, using the cube's Update action
+type CellWriter struct {
+	Cube        string
+	Hierarchies []HierarchyRef
+	updates     []CellUpdate
+}
+
+// NewCellWriter creates a new CellWriter for the cube with the specified name and dimensions, in order, presuming
+// every dimension has a same named hierarchy, which is used to look up the elements in
+func NewCellWriter(cube string, dimensions ...string) *CellWriter {
+	hierarchies := make([]HierarchyRef, len(dimensions))
+	for i, dimension := range dimensions {
+		hierarchies[i] = HierarchyRef{Dimension: dimension, Hierarchy: dimension}
+	}
+	return &CellWriter{Cube: cube, Hierarchies: hierarchies}
+}
+
+// LoadCellWriter creates a new CellWriter for the cube with the specified name, requesting the dimensions of the
+// cube from the TM1 server, so the tuples get validated against the actual dimensions of the cube
+func LoadCellWriter(ctx context.Context, client *odata.Client, root, cube string) (*CellWriter, error) {
+	dimensions, err := odata.GetCollection[Dimension](ctx, client, root+odata.NewQuery(CubePath(cube)+"/Dimensions").Select("Name").String())
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(dimensions.Value))
+	for i, dimension := range dimensions.Value {
+		names[i] = dimension.Name
+	}
+	return NewCellWriter(cube, names...), nil
+}
+
+// WriteNumber adds an update setting the cell, identified by the tuple of element names, to the numeric value
+func (writer *CellWriter) WriteNumber(value float64, tuple ...string) error {
+	return writer.add(value, tuple)
+}
+
+// WriteString adds an update setting the cell, identified by the tuple of element names, to the string value
+func (writer *CellWriter) WriteString(value string, tuple ...string) error {
+	return writer.add(value, tuple)
+}
+
+// Spread adds an update applying the spreading expression, e.g. "+5" to add 5 to the current value of the cell,
+// to the cell identified by the tuple of element names
+func (writer *CellWriter) Spread(expression string, tuple ...string) error {
+	return writer.add(expression, tuple)
+}
+
+// Add adds an update to the cell, identified by the tuple of element names, adding the numeric value to the
+// current value of the cell, which allows loading data without first having to aggregate it
+func (writer *CellWriter) Add(value float64, tuple ...string) error {
+	expression := strconv.FormatFloat(value, 'f', -1, 64)
+	if !strings.HasPrefix(expression, "-") {
+		expression = "+" + expression
+	}
+	return writer.Spread(expression, tuple...)
+}
+
+// Tuple returns the tuple, with the elements in the order of the dimensions of the cube, for the cell identified by
+// the passed map of dimension names to element names, so callers don't have to depend on the order of the dimensions
+func (writer *CellWriter) Tuple(elements map[string]string) ([]string, error) {
+	if len(elements) != len(writer.Hierarchies) {
+		return nil, fmt.Errorf("cell %v has %d elements but cube '%s' has %d dimensions", elements, len(elements), writer.Cube, len(writer.Hierarchies))
+	}
+	tuple := make([]string, len(writer.Hierarchies))
+	for i, hierarchy := range writer.Hierarchies {
+		element, ok := elements[hierarchy.Dimension]
+		if !ok {
+			return nil, fmt.Errorf("cell %v has no element for dimension '%s' of cube '%s'", elements, hierarchy.Dimension, writer.Cube)
+		}
+		tuple[i] = element
+	}
+	return tuple, nil
+}
+
+func (writer *CellWriter) add(value interface{}, tuple []string) error {
+	if len(tuple) != len(writer.Hierarchies) {
+		return fmt.Errorf("tuple %q has %d elements but cube '%s' has %d dimensions", tuple, len(tuple), writer.Cube, len(writer.Hierarchies))
+	}
+	slice := make([]string, len(tuple))
+	for i, element := range tuple {
+		slice[i] = ElementPath(writer.Hierarchies[i].Dimension, writer.Hierarchies[i].Hierarchy, element)
+	}
+	writer.updates = append(writer.updates, CellUpdate{Slice: slice, Value: value})
+	return nil
+}
+
+// Len returns the number of updates collected so far
+func (writer *CellWriter) Len() int {
+	return len(writer.updates)
+}
+
+// Path returns the path, relative to the service root, of the Update action of the cube
+func (writer *CellWriter) Path() string {
+	return CubePath(writer.Cube) + "/tm1.Update"
+}
+
+// JSON returns the JSON specification of the collected updates to be passed to the Update action of the cube
+func (writer *CellWriter) JSON() (string, error) {
+	updates := writer.updates
+	if updates == nil {
+		updates = []CellUpdate{}
+	}
+	jUpdates, err := json.Marshal(updates)
+	if err != nil {
+		return "", err
+	}
+	return string(jUpdates), nil
+}
+
+// Write sends the collected updates to the TM1 server, after which the writer is ready to collect the next set
+func (writer *CellWriter) Write(ctx context.Context, client *odata.Client, root string) error {
+	if len(writer.updates) == 0 {
+		return nil
+	}
+	jUpdates, err := writer.JSON()
+	if err != nil {
+		return err
+	}
+	resp, err := client.ExecutePOSTRequestContext(ctx, root+writer.Path(), "application/json", jUpdates)
+	if err != nil {
+		return err
+	}
+
+	// By default an empty response is expected, hence the 204
+	err = odata.ValidateStatusCode(resp, 204, func() string {
+		return "Updating cells in cube '" + writer.Cube + "'."
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	writer.updates = nil
+	return nil
+}
@@ -0,0 +1,84 @@
+package tm1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCellWriterArity(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(writer *CellWriter) error
+		valid bool
+	}{
+		{"number", func(writer *CellWriter) error { return writer.WriteNumber(10, "Chai", "1997", "Revenue") }, true},
+		{"string", func(writer *CellWriter) error { return writer.WriteString("n/a", "Chai", "1997", "Revenue") }, true},
+		{"spread", func(writer *CellWriter) error { return writer.Spread("+5", "Chai", "1997", "Revenue") }, true},
+		{"add", func(writer *CellWriter) error { return writer.Add(-2.5, "Chai", "1997", "Revenue") }, true},
+		{"too few elements", func(writer *CellWriter) error { return writer.WriteNumber(10, "Chai", "1997") }, false},
+		{"too many elements", func(writer *CellWriter) error { return writer.WriteNumber(10, "Chai", "1997", "Revenue", "Extra") }, false},
+		{"no elements", func(writer *CellWriter) error { return writer.Add(1) }, false},
+	}
+	for _, test := range tests {
+		writer := NewCellWriter("Sales", "Products", "Time", "Measures")
+		err := test.write(writer)
+		if test.valid && err != nil {
+			t.Errorf("%s: got %v, want no error", test.name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: got no error, want one", test.name)
+		}
+		if want := map[bool]int{true: 1, false: 0}[test.valid]; writer.Len() != want {
+			t.Errorf("%s: got %d updates, want %d", test.name, writer.Len(), want)
+		}
+	}
+}
+
+func TestCellWriterJSON(t *testing.T) {
+	writer := NewCellWriter("Sales", "Products", "Measures")
+	if jUpdates, err := writer.JSON(); err != nil || jUpdates != "[]" {
+		t.Errorf("empty writer: got %q, %v, want []", jUpdates, err)
+	}
+	writer.WriteNumber(10, "Chai", "Revenue")
+	writer.Add(2.5, "O'Neil", "Revenue")
+	jUpdates, err := writer.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"Slice@odata.bind":["Dimensions('Products')/Hierarchies('Products')/Elements('Chai')","Dimensions('Measures')/Hierarchies('Measures')/Elements('Revenue')"],"Value":10},` +
+		`{"Slice@odata.bind":["Dimensions('Products')/Hierarchies('Products')/Elements('O''Neil')","Dimensions('Measures')/Hierarchies('Measures')/Elements('Revenue')"],"Value":"+2.5"}]`
+	if jUpdates != want {
+		t.Errorf("got\n%s\nwant\n%s", jUpdates, want)
+	}
+	if path := writer.Path(); path != "Cubes('Sales')/tm1.Update" {
+		t.Errorf("got path %q, want %q", path, "Cubes('Sales')/tm1.Update")
+	}
+}
+
+func TestCellWriterTuple(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements map[string]string
+		want     []string
+		err      string
+	}{
+		{"in cube order", map[string]string{"Measures": "Revenue", "Time": "1997", "Products": "Chai"}, []string{"Chai", "1997", "Revenue"}, ""},
+		{"missing dimension", map[string]string{"Products": "Chai", "Time": "1997"}, nil, "has 2 elements but cube 'Sales' has 3 dimensions"},
+		{"unknown dimension", map[string]string{"Products": "Chai", "Time": "1997", "Customers": "ALFKI"}, nil, "has no element for dimension 'Measures'"},
+	}
+	for _, test := range tests {
+		writer := NewCellWriter("Sales", "Products", "Time", "Measures")
+		tuple, err := writer.Tuple(test.elements)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !reflect.DeepEqual(tuple, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, tuple, test.want)
+		}
+	}
+}
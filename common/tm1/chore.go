@@ -0,0 +1,301 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// ChoreExecutionMode defines whether the processes in a chore are committed together, or each on their own
+type ChoreExecutionMode string
+
+// The modes in which a chore can execute its processes
+const (
+	ChoreSingleCommit   ChoreExecutionMode = "SingleCommit"
+	ChoreMultipleCommit ChoreExecutionMode = "MultipleCommit"
+)
+
+// Chore defines the structure of a single chore, executing its tasks, in order, every Frequency starting at
+// StartTime, but only while the chore is Active
+type Chore struct {
+	Name          string
+	StartTime     time.Time
+	DSTSensitive  bool
+	Active        bool
+	ExecutionMode ChoreExecutionMode
+	Frequency     time.Duration
+	Tasks         []ChoreTask
+}
+
+// ChoreTask defines the structure of a single task, or step, of a chore, executing the process with the values of
+// the parameters, which only need a Name and Value
+type ChoreTask struct {
+	Process    string
+	Parameters []ProcessParameter
+}
+
+// CreateChore creates a new, inactive, chore, executing its tasks every frequency starting at the start time
+func CreateChore(name string, startTime time.Time, frequency time.Duration) *Chore {
+	return &Chore{Name: name, StartTime: startTime, Frequency: frequency, ExecutionMode: ChoreSingleCommit}
+}
+
+// AddTask adds a task, executing the process with the values of the parameters, to the chore
+func (chore *Chore) AddTask(process string, parameters ...ProcessParameter) {
+	chore.Tasks = append(chore.Tasks, ChoreTask{Process: process, Parameters: parameters})
+}
+
+// Equal reports whether both chores have the same definition, including their tasks, in order, and the values of
+// their parameters. The names of the chores, processes and parameters, like in TM1, are compared ignoring case and
+// spaces, the start times are compared as instants, irrespective of the time zone they are in.
+func (chore *Chore) Equal(other *Chore) bool {
+	if nameKey(chore.Name) != nameKey(other.Name) || chore.StartTime.Equal(other.StartTime) == false ||
+		chore.DSTSensitive != other.DSTSensitive || chore.Active != other.Active ||
+		chore.ExecutionMode != other.ExecutionMode || chore.Frequency != other.Frequency ||
+		len(chore.Tasks) != len(other.Tasks) {
+		return false
+	}
+	for i, task := range chore.Tasks {
+		if task.equal(&other.Tasks[i]) == false {
+			return false
+		}
+	}
+	return true
+}
+
+// equal reports whether both tasks execute the same process with the same values of its parameters. Values are
+// compared in their textual form, as the server returns the value of a numeric parameter as a number.
+func (task *ChoreTask) equal(other *ChoreTask) bool {
+	if nameKey(task.Process) != nameKey(other.Process) || len(task.Parameters) != len(other.Parameters) {
+		return false
+	}
+	for i, parameter := range task.Parameters {
+		if nameKey(parameter.Name) != nameKey(other.Parameters[i].Name) ||
+			fmt.Sprint(parameter.Value) != fmt.Sprint(other.Parameters[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// choreFrequency returns the frequency as the duration TM1 uses for the frequency of a chore, e.g. P1DT02H30M00S
+func choreFrequency(frequency time.Duration) string {
+	seconds := int64(frequency / time.Second)
+	return fmt.Sprintf("P%dDT%02dH%02dM%02dS", seconds/86400, seconds%86400/3600, seconds%3600/60, seconds%60)
+}
+
+// parseChoreFrequency parses the frequency, as used by TM1 for the frequency of a chore, into a duration
+func parseChoreFrequency(frequency string) (time.Duration, error) {
+	var days, hours, minutes, seconds int64
+	if _, err := fmt.Sscanf(frequency, "P%dDT%dH%dM%dS", &days, &hours, &minutes, &seconds); err != nil {
+		return 0, fmt.Errorf("invalid chore frequency '%s': %w", frequency, err)
+	}
+	return time.Duration(((days*24+hours)*60+minutes)*60+seconds) * time.Second, nil
+}
+
+// chorePost defines the structure of the JSON payload used to create, or update, a chore on the TM1 server
+type chorePost struct {
+	Name          string
+	StartTime     string
+	DSTSensitive  bool
+	Active        bool
+	ExecutionMode ChoreExecutionMode
+	Frequency     string
+	Tasks         []choreTaskPost `json:",omitempty"`
+}
+
+// choreTaskPost defines the structure of the JSON payload for a single task of a chore
+type choreTaskPost struct {
+	ProcessBind string             `json:"Process@odata.bind"`
+	Parameters  []ProcessParameter `json:",omitempty"`
+}
+
+// post returns the chore in the structure of the JSON payload used to create it
+func (chore *Chore) post() *chorePost {
+	post := &chorePost{
+		Name:          chore.Name,
+		StartTime:     chore.StartTime.Format(time.RFC3339),
+		DSTSensitive:  chore.DSTSensitive,
+		Active:        chore.Active,
+		ExecutionMode: chore.ExecutionMode,
+		Frequency:     choreFrequency(chore.Frequency),
+	}
+	for _, task := range chore.Tasks {
+		post.Tasks = append(post.Tasks, task.post())
+	}
+	return post
+}
+
+// post returns the task in the structure of the JSON payload used to create, or update, it
+func (task *ChoreTask) post() choreTaskPost {
+	return choreTaskPost{ProcessBind: ProcessPath(task.Process), Parameters: task.Parameters}
+}
+
+// choreRead defines the structure of a single Chore entity as read from the server, with its tasks expanded
+type choreRead struct {
+	Name          string
+	StartTime     odata.DateTimeOffset
+	DSTSensitive  bool
+	Active        bool
+	ExecutionMode ChoreExecutionMode
+	Frequency     string
+	Tasks         []struct {
+		Step    int
+		Process struct {
+			Name string
+		}
+		Parameters []ProcessParameter
+	}
+}
+
+// choreQuery returns the query options to expand a chore, or collection of chores, with everything we read of it
+func choreQuery(resource string) *odata.Query {
+	return odata.NewQuery(resource).Select("Name", "StartTime", "DSTSensitive", "Active", "ExecutionMode", "Frequency").
+		Expand("Tasks", odata.NewQuery("").Select("Step", "Parameters").Expand("Process", odata.NewQuery("").Select("Name")))
+}
+
+// chore returns the chore, as read from the server, as a Chore
+func (read *choreRead) chore() (*Chore, error) {
+	chore := &Chore{Name: read.Name, StartTime: read.StartTime.Time, DSTSensitive: read.DSTSensitive, Active: read.Active, ExecutionMode: read.ExecutionMode}
+	var err error
+	if chore.Frequency, err = parseChoreFrequency(read.Frequency); err != nil {
+		return nil, err
+	}
+	for _, task := range read.Tasks {
+		chore.Tasks = append(chore.Tasks, ChoreTask{Process: task.Process.Name, Parameters: task.Parameters})
+	}
+	return chore, nil
+}
+
+// GetChore retrieves the chore with the specified name, including its tasks, from the TM1 server
+// Note: if the chore doesn't exist the returned error satisfies odata.IsNotFound
+func GetChore(ctx context.Context, client *odata.Client, root, name string) (*Chore, error) {
+	res, err := odata.GetEntity[choreRead](ctx, client, root+choreQuery(ChorePath(name)).String())
+	if err != nil {
+		return nil, err
+	}
+	return res.chore()
+}
+
+// GetChores retrieves all chores, including their tasks, from the TM1 server
+func GetChores(ctx context.Context, client *odata.Client, root string) ([]*Chore, error) {
+	res, err := odata.GetCollection[choreRead](ctx, client, root+choreQuery("Chores").OrderBy("Name").String())
+	if err != nil {
+		return nil, err
+	}
+	chores := make([]*Chore, len(res.Value))
+	for i, read := range res.Value {
+		if chores[i], err = read.chore(); err != nil {
+			return nil, err
+		}
+	}
+	return chores, nil
+}
+
+// PostChore creates the chore, including its tasks, on the TM1 server
+func PostChore(ctx context.Context, client *odata.Client, root string, chore *Chore) error {
+	jChore, err := json.Marshal(chore.post())
+	if err != nil {
+		return err
+	}
+	resp, err := client.ExecutePOSTRequestContext(ctx, root+"Chores", "application/json", string(jChore))
+	return expectStatus(resp, err, 201, func() string {
+		return "Failed to create chore '" + chore.Name + "'."
+	})
+}
+
+// UpdateChore replaces the definition, including the tasks, of the, existing, chore on the TM1 server
+// Note: the chore is read before it is updated, outside of the change set doing so, as we need to know whether it is
+// active and how many tasks it has. If someone else changes the chore in between, e.g. activates it or adds a task,
+// the update either fails, leaving the chore untouched, or leaves tasks beyond the ones of the chore behind, which is
+// why the chore is read back once updated, failing if it doesn't match the chore it was meant to be updated to.
+func UpdateChore(ctx context.Context, client *odata.Client, root string, chore *Chore) error {
+	// The tasks are updated one by one, so we need to know how many the chore currently has
+	current, err := GetChore(ctx, client, root, chore.Name)
+	if err != nil {
+		return err
+	}
+
+	// An active chore can't be changed, so, in one change set, deactivate the chore, update its properties and
+	// tasks, and activate it again if it is meant to be active
+	post := chore.post()
+	post.Active, post.Tasks = false, nil
+	jChore, err := json.Marshal(post)
+	if err != nil {
+		return err
+	}
+	batch := &odata.Batch{}
+	changeSet := batch.SequentialChangeSet()
+	if current.Active == true {
+		changeSet.Add("POST", ChorePath(chore.Name)+"/tm1.Deactivate", "")
+	}
+	changeSet.Add("PATCH", ChorePath(chore.Name), string(jChore))
+	for step := len(current.Tasks) - 1; step >= len(chore.Tasks); step-- {
+		changeSet.Add("DELETE", ChoreTaskPath(chore.Name, step), "")
+	}
+	for step, task := range chore.Tasks {
+		jTask, err := json.Marshal(task.post())
+		if err != nil {
+			return err
+		}
+		if step < len(current.Tasks) {
+			changeSet.Add("PATCH", ChoreTaskPath(chore.Name, step), string(jTask))
+		} else {
+			changeSet.Add("POST", ChorePath(chore.Name)+"/Tasks", string(jTask))
+		}
+	}
+	if chore.Active == true {
+		changeSet.Add("POST", ChorePath(chore.Name)+"/tm1.Activate", "")
+	}
+	result, err := client.ExecuteBatch(ctx, root, batch)
+	if err != nil {
+		return err
+	}
+	if err = result.Err(); err != nil {
+		return err
+	}
+
+	// Validate that the chore ended up the way it was meant to, and not in a mix of our and someone else's changes
+	updated, err := GetChore(ctx, client, root, chore.Name)
+	if err != nil {
+		return err
+	}
+	if updated.Equal(chore) == false {
+		return fmt.Errorf("chore '%s' was changed on the server while being updated, it no longer matches its definition", chore.Name)
+	}
+	return nil
+}
+
+// DeleteChore deletes the chore with the specified name from the TM1 server
+func DeleteChore(ctx context.Context, client *odata.Client, root, name string) error {
+	resp, err := client.ExecuteDELETERequestContext(ctx, root+ChorePath(name))
+	return expectStatus(resp, err, 204, func() string {
+		return "Failed to delete chore '" + name + "'."
+	})
+}
+
+// ActivateChore activates the chore with the specified name, having it execute on its schedule
+func ActivateChore(ctx context.Context, client *odata.Client, root, name string) error {
+	return executeChoreAction(ctx, client, root, name, "Activate")
+}
+
+// DeactivateChore deactivates the chore with the specified name, so it no longer executes on its schedule
+func DeactivateChore(ctx context.Context, client *odata.Client, root, name string) error {
+	return executeChoreAction(ctx, client, root, name, "Deactivate")
+}
+
+// ExecuteChore executes the chore with the specified name now, regardless of its schedule, and waits for it to
+// complete
+func ExecuteChore(ctx context.Context, client *odata.Client, root, name string) error {
+	return executeChoreAction(ctx, client, root, name, "Execute")
+}
+
+// executeChoreAction invokes the, parameterless, action on the chore with the specified name
+func executeChoreAction(ctx context.Context, client *odata.Client, root, name, action string) error {
+	resp, err := client.ExecutePOSTRequestContext(ctx, root+ChorePath(name)+"/tm1."+action, "", "")
+	return expectStatus(resp, err, 204, func() string {
+		return "Failed to " + action + " chore '" + name + "'."
+	})
+}
@@ -0,0 +1,146 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+func TestChoreFrequency(t *testing.T) {
+	tests := []struct {
+		frequency time.Duration
+		want      string
+	}{
+		{0, "P0DT00H00M00S"},
+		{30 * time.Second, "P0DT00H00M30S"},
+		{15 * time.Minute, "P0DT00H15M00S"},
+		{24 * time.Hour, "P1DT00H00M00S"},
+		{7*24*time.Hour + 2*time.Hour + 3*time.Minute + 4*time.Second, "P7DT02H03M04S"},
+	}
+	for _, test := range tests {
+		if got := choreFrequency(test.frequency); got != test.want {
+			t.Errorf("choreFrequency(%v): got %q, want %q", test.frequency, got, test.want)
+		}
+		got, err := parseChoreFrequency(test.want)
+		if err != nil || got != test.frequency {
+			t.Errorf("parseChoreFrequency(%q): got %v, %v, want %v", test.want, got, err, test.frequency)
+		}
+	}
+}
+
+func TestParseChoreFrequencyInvalid(t *testing.T) {
+	for _, frequency := range []string{"", "1d", "PT1H", "P1D", "P1DT02H03M"} {
+		if _, err := parseChoreFrequency(frequency); err == nil {
+			t.Errorf("parseChoreFrequency(%q) succeeded, want an error", frequency)
+		}
+	}
+}
+
+func TestChoreEqual(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 2, 0, 0, 0, time.UTC)
+	reload := func() *Chore {
+		chore := CreateChore("Reload", start, 24*time.Hour)
+		chore.AddTask("Reload Sales", ProcessParameter{Name: "Year", Value: 1997}, ProcessParameter{Name: "Cube", Value: "Sales"})
+		return chore
+	}
+	tests := []struct {
+		name   string
+		modify func(chore *Chore)
+		want   bool
+	}{
+		{"identical", func(chore *Chore) {}, true},
+		{"names ignoring case and spaces", func(chore *Chore) {
+			chore.Name = "RE LOAD"
+			chore.Tasks[0].Process = "reloadsales"
+			chore.Tasks[0].Parameters[0].Name = "year"
+		}, true},
+		{"start time in another time zone", func(chore *Chore) {
+			chore.StartTime = start.In(time.FixedZone("CET", 3600))
+		}, true},
+		{"numeric parameter value as read back", func(chore *Chore) { chore.Tasks[0].Parameters[0].Value = 1997.0 }, true},
+		{"other start time", func(chore *Chore) { chore.StartTime = start.Add(time.Hour) }, false},
+		{"other frequency", func(chore *Chore) { chore.Frequency = time.Hour }, false},
+		{"active", func(chore *Chore) { chore.Active = true }, false},
+		{"other execution mode", func(chore *Chore) { chore.ExecutionMode = ChoreMultipleCommit }, false},
+		{"other process", func(chore *Chore) { chore.Tasks[0].Process = "Clear Sales" }, false},
+		{"other parameter value", func(chore *Chore) { chore.Tasks[0].Parameters[0].Value = 1998 }, false},
+		{"extra task", func(chore *Chore) { chore.AddTask("Clear Sales") }, false},
+	}
+	for _, test := range tests {
+		chore := reload()
+		test.modify(chore)
+		if got := reload().Equal(chore); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUpdateChore(t *testing.T) {
+	verbose := odata.Verbose
+	odata.Verbose = false
+	t.Cleanup(func() { odata.Verbose = verbose })
+
+	const (
+		task    = `{"Step":0,"Process":{"Name":"Reload Sales"},"Parameters":[{"Name":"Year","Value":1997}]}`
+		current = `{"Name":"Reload","StartTime":"2020-01-01T02:00Z","DSTSensitive":false,"Active":true,"ExecutionMode":"SingleCommit","Frequency":"P1DT00H00M00S","Tasks":[` + task + `]}`
+	)
+	chore := CreateChore("Reload", time.Date(2020, time.January, 1, 2, 0, 0, 0, time.UTC), 12*time.Hour)
+	chore.AddTask("Reload Sales", ProcessParameter{Name: "Year", Value: 1997})
+	chore.Active = true
+
+	tests := []struct {
+		name    string
+		updated string
+		wantErr bool
+	}{
+		{"updated as defined", strings.Replace(current, "P1DT00H00M00S", "P0DT12H00M00S", 1), false},
+		{"task added by someone else", strings.Replace(strings.Replace(current, "P1DT00H00M00S", "P0DT12H00M00S", 1), task, task+","+strings.Replace(task, `"Step":0`, `"Step":1`, 1), 1), true},
+	}
+	for _, test := range tests {
+		var requests []string
+		reads := []string{current, test.updated}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" {
+				fmt.Fprint(w, reads[0])
+				reads = reads[1:]
+				return
+			}
+			var batch odata.Batch
+			if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			result := odata.BatchResult{}
+			for _, req := range batch.Requests {
+				requests = append(requests, req.Method+" "+req.URL)
+				result.Responses = append(result.Responses, &odata.BatchResponse{ID: req.ID, Status: http.StatusNoContent})
+			}
+			json.NewEncoder(w).Encode(result)
+		}))
+
+		err := UpdateChore(context.Background(), &odata.Client{}, server.URL+"/", chore)
+		server.Close()
+		if test.wantErr && err == nil {
+			t.Errorf("%s: got no error, want one", test.name)
+		} else if !test.wantErr && err != nil {
+			t.Errorf("%s: got %v, want no error", test.name, err)
+		}
+		want := []string{
+			"POST " + ChorePath("Reload") + "/tm1.Deactivate",
+			"PATCH " + ChorePath("Reload"),
+			"PATCH " + ChoreTaskPath("Reload", 0),
+			"POST " + ChorePath("Reload") + "/tm1.Activate",
+		}
+		if !reflect.DeepEqual(requests, want) {
+			t.Errorf("%s: got requests %q, want %q", test.name, requests, want)
+		}
+	}
+}
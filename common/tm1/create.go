@@ -0,0 +1,161 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// expectStatus validates that the request executed, and the response has the expected status code, after which it
+// closes the response. It's meant to directly wrap the call executing a request, the response of which isn't needed.
+func expectStatus(resp *http.Response, err error, statusCode int, logFmt func() string) error {
+	if err != nil {
+		return err
+	}
+	err = odata.ValidateStatusCode(resp, statusCode, logFmt)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PostDimension creates the dimension, including the element attributes, and their default values, of its first
+// hierarchy, as well as the localized attribute values of all its hierarchies, on the TM1 server
+func PostDimension(ctx context.Context, client *odata.Client, root string, dimension *Dimension) error {
+
+	// Create a JSON representation for the dimension
+	jDimension, err := json.Marshal(dimension)
+	if err != nil {
+		return err
+	}
+
+	// Creating the dimension takes a few steps, creating the dimension, its attributes and setting
+	// the, default and localized, attribute values, which we'll send to the TM1 server as a single
+	// batch, in one change set, so either all of them succeed or the server rolls back all of them,
+	// not leaving a half built dimension behind.
+	// First POST the dimension itself
+	batch := &odata.Batch{}
+	changeSet := batch.SequentialChangeSet()
+	createDimensionReq := changeSet.Add("POST", "Dimensions", string(jDimension))
+
+	// Secondly create the element attributes, 'Caption' and any other attribute the dimension defines,
+	// on the first hierarchy of the dimension
+	hierarchy := dimension.Hierarchies[0]
+	createAttributeReqs := make([]*odata.BatchRequest, len(hierarchy.Attributes))
+	for i, attribute := range hierarchy.Attributes {
+		jAttribute, _ := json.Marshal(attribute)
+		createAttributeReqs[i] = changeSet.Add("POST", ElementAttributesPath(dimension.Name, hierarchy.Name), string(jAttribute))
+	}
+
+	// Now that the attributes exist lets set their values accordingly for this
+	// we'll simply update the }ElementAttributes_DIMENSION cube directly, updating the
+	// default value. Note: TM1 Server doesn't support passing the attribute values as
+	// part of the dimension definition just yet (should shortly), so for now this is the
+	// easiest way around that. Alternatively, one could have updated the attribute
+	// values for elements one by one by POSTing to or PATCHing the LocalizedAttributes
+	// of the individual elements.
+	attributes := dimension.AttributesWriter()
+	jAttributes, err := attributes.JSON()
+	if err != nil {
+		return err
+	}
+	updateAttributesReq := changeSet.Add("POST", attributes.Path(), jAttributes)
+
+	// Last but not least, set the attribute values, captions typically, in the cultures the dimension has been
+	// localized for by POSTing them to the LocalizedAttributes of the individual elements, in every hierarchy.
+	var localizeReqs []*odata.BatchRequest
+	for _, hierarchy := range dimension.Hierarchies {
+		for _, element := range hierarchy.LocalizedElements() {
+			for _, localized := range hierarchy.LocalizedAttributes(element) {
+				jLocalized, _ := json.Marshal(localized)
+				localizeReqs = append(localizeReqs, changeSet.Add("POST", LocalizedAttributesPath(dimension.Name, hierarchy.Name, element), string(jLocalized)))
+			}
+		}
+	}
+
+	// Execute the batch
+	result, err := client.ExecuteBatch(ctx, root, batch)
+	if err != nil {
+		return err
+	}
+
+	// Validate that the dimension got created successfully
+	err = result.Validate(createDimensionReq, 201, func() string {
+		return "Failed to create dimension '" + dimension.Name + "'."
+	})
+	if err != nil {
+		return err
+	}
+
+	// Validate that the element attributes got created successfully as well
+	for i, attribute := range hierarchy.Attributes {
+		err = result.Validate(createAttributeReqs[i], 201, func() string {
+			return "Creating element attribute '" + attribute.Name + "' for dimension '" + dimension.Name + "'."
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Validate that the update executed successfully (by default an empty response is expected, hence the 204).
+	err = result.Validate(updateAttributesReq, 204, func() string {
+		return "Setting attribute values for elements in dimension '" + dimension.Name + "'."
+	})
+	if err != nil {
+		return err
+	}
+
+	// Validate that the localized attribute values got set successfully
+	for _, localizeReq := range localizeReqs {
+		err = result.Validate(localizeReq, 201, func() string {
+			return "Setting localized attribute values for elements in dimension '" + dimension.Name + "'."
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteDimension deletes the dimension with the specified name from the TM1 server
+func DeleteDimension(ctx context.Context, client *odata.Client, root, name string) error {
+	resp, err := client.ExecuteDELETERequestContext(ctx, root+DimensionPath(name))
+	return expectStatus(resp, err, 204, func() string {
+		return "Failed to delete dimension '" + name + "'."
+	})
+}
+
+// PostCube creates the cube on the TM1 server
+func PostCube(ctx context.Context, client *odata.Client, root string, cube *Cube) error {
+	jCube, err := json.Marshal(cube.Post())
+	if err != nil {
+		return err
+	}
+	resp, err := client.ExecutePOSTRequestContext(ctx, root+"Cubes", "application/json", string(jCube))
+	return expectStatus(resp, err, 201, func() string {
+		return "Failed to create cube '" + cube.Name + "'."
+	})
+}
+
+// UpdateCubeRules replaces the rules of the cube with the specified name
+func UpdateCubeRules(ctx context.Context, client *odata.Client, root, name, rules string) error {
+	jRules, err := json.Marshal(struct{ Rules string }{rules})
+	if err != nil {
+		return err
+	}
+	resp, err := client.ExecutePATCHRequestContext(ctx, root+CubePath(name), "application/json", string(jRules))
+	return expectStatus(resp, err, 204, func() string {
+		return "Failed to update the rules of cube '" + name + "'."
+	})
+}
+
+// DeleteCube deletes the cube with the specified name from the TM1 server
+func DeleteCube(ctx context.Context, client *odata.Client, root, name string) error {
+	resp, err := client.ExecuteDELETERequestContext(ctx, root+CubePath(name))
+	return expectStatus(resp, err, 204, func() string {
+		return "Failed to delete cube '" + name + "'."
+	})
+}
@@ -0,0 +1,62 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+func TestPostDimensionLocalizesAllHierarchies(t *testing.T) {
+	verbose := odata.Verbose
+	odata.Verbose = false
+	t.Cleanup(func() { odata.Verbose = verbose })
+
+	var localized []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch odata.Batch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		result := odata.BatchResult{}
+		for _, req := range batch.Requests {
+			status := http.StatusCreated
+			if strings.HasSuffix(req.URL, "/tm1.Update") {
+				status = http.StatusNoContent
+			}
+			if strings.HasSuffix(req.URL, "/LocalizedAttributes") {
+				localized = append(localized, req.URL)
+			}
+			result.Responses = append(result.Responses, &odata.BatchResponse{ID: req.ID, Status: status})
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	dimension := CreateDimension("Time")
+	days := dimension.AddHierarchy("Time")
+	days.AddElement("01-1997", "Jan 1997")
+	days.SetLocalizedCaption("01-1997", "fr", "janv. 1997")
+	months := dimension.AddHierarchy("Months")
+	months.AddElement("Months", "All Months")
+	months.AddElement("January", "")
+	months.AddEdge("Months", "January")
+	months.SetLocalizedCaption("January", "fr", "janvier")
+	if err := PostDimension(context.Background(), &odata.Client{}, server.URL+"/", dimension); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		LocalizedAttributesPath("Time", "Time", "01-1997"),
+		LocalizedAttributesPath("Time", "Months", "January"),
+	}
+	if !reflect.DeepEqual(localized, want) {
+		t.Errorf("got localized attribute requests %v, want %v", localized, want)
+	}
+}
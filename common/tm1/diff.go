@@ -0,0 +1,380 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// ChangeKind defines the kind of a change to a dimension
+type ChangeKind string
+
+// The kinds of changes, in the order in which they get applied. Edges get removed before, and added after, changing
+// the type of elements, as a consolidated element can only become a leaf once it lost its components and a leaf can
+// only get components once it became a consolidated element.
+const (
+	ChangeAddAttribute   ChangeKind = "add attribute"
+	ChangeAddHierarchy   ChangeKind = "add hierarchy"
+	ChangeAddElement     ChangeKind = "add element"
+	ChangeRemoveEdge     ChangeKind = "remove edge"
+	ChangeElementType    ChangeKind = "change element type"
+	ChangeAddEdge        ChangeKind = "add edge"
+	ChangeEdgeWeight     ChangeKind = "change edge weight"
+	ChangeRemoveElement  ChangeKind = "remove element"
+	ChangeAttributeValue ChangeKind = "change attribute value"
+	ChangeLocalizedValue ChangeKind = "change localized value"
+)
+
+// Change defines a single change to a hierarchy of a dimension. Edges are identified by the Parent and the
+// component, in Element, they link. Localized attribute values are identified by the Culture as well.
+type Change struct {
+	Kind      ChangeKind
+	Hierarchy string
+	Element   string      `json:",omitempty"`
+	Parent    string      `json:",omitempty"`
+	Attribute string      `json:",omitempty"`
+	Culture   string      `json:",omitempty"`
+	Old       interface{} `json:",omitempty"`
+	New       interface{} `json:",omitempty"`
+}
+
+// String returns a human readable description of the change
+func (change Change) String() string {
+	switch change.Kind {
+	case ChangeAddAttribute:
+		return fmt.Sprintf("%s: add %v attribute '%s'", change.Hierarchy, change.New, change.Attribute)
+	case ChangeAddHierarchy:
+		return fmt.Sprintf("%s: add hierarchy", change.Hierarchy)
+	case ChangeAddElement, ChangeRemoveElement:
+		return fmt.Sprintf("%s: %s '%s'", change.Hierarchy, change.Kind, change.Element)
+	case ChangeElementType:
+		return fmt.Sprintf("%s: change type of element '%s' from %v to %v", change.Hierarchy, change.Element, change.Old, change.New)
+	case ChangeAddEdge, ChangeRemoveEdge:
+		return fmt.Sprintf("%s: %s '%s' -> '%s'", change.Hierarchy, change.Kind, change.Parent, change.Element)
+	case ChangeEdgeWeight:
+		return fmt.Sprintf("%s: change weight of edge '%s' -> '%s' from %v to %v", change.Hierarchy, change.Parent, change.Element, change.Old, change.New)
+	case ChangeAttributeValue:
+		return fmt.Sprintf("%s: change '%s' of element '%s' from %q to %q", change.Hierarchy, change.Attribute, change.Element, fmt.Sprint(change.Old), fmt.Sprint(change.New))
+	case ChangeLocalizedValue:
+		return fmt.Sprintf("%s: change '%s' of element '%s' in culture '%s' from %q to %q", change.Hierarchy, change.Attribute, change.Element, change.Culture, fmt.Sprint(change.Old), fmt.Sprint(change.New))
+	}
+	return fmt.Sprintf("%s: %s", change.Hierarchy, change.Kind)
+}
+
+// DimensionDiff holds the changes required to turn the current definition of a dimension, as on the server, into
+// the desired one
+type DimensionDiff struct {
+	Dimension string
+	Changes   []Change
+	current   *Dimension
+	desired   *Dimension
+}
+
+// DiffDimension compares the current definition of the dimension, as read from the server, with the desired one
+// and returns the changes, ordered the way they need to be applied, required to turn the first into the second.
+// Hierarchies that only exist on the server, like the Leaves hierarchy TM1 maintains, and attributes that only exist
+// on the server are left alone. Like createDimension in the builder does, the attributes, and their values, are
+// only compared for the first hierarchy of the dimension. The localized attribute values are compared for every
+// hierarchy, of which only the ones that are part of the desired definition are compared, any others are left alone.
+func DiffDimension(current, desired *Dimension) *DimensionDiff {
+	diff := &DimensionDiff{Dimension: desired.Name, current: current, desired: desired}
+	currentHierarchies := make(map[string]*Hierarchy)
+	for _, hierarchy := range current.Hierarchies {
+		currentHierarchies[nameKey(hierarchy.Name)] = hierarchy
+	}
+
+	// The changes per hierarchy, per kind, which get concatenated in the order of the kinds in the end
+	changes := make(map[ChangeKind][]Change)
+	add := func(change Change) {
+		changes[change.Kind] = append(changes[change.Kind], change)
+	}
+	for i, hierarchy := range desired.Hierarchies {
+		currentHierarchy := currentHierarchies[nameKey(hierarchy.Name)]
+		if currentHierarchy == nil {
+			// Adding the hierarchy doesn't set its localized attribute values, those still need to be set
+			add(Change{Kind: ChangeAddHierarchy, Hierarchy: hierarchy.Name})
+			diffLocalized(&Hierarchy{Name: hierarchy.Name}, hierarchy, add)
+			continue
+		}
+		diffHierarchy(currentHierarchy, hierarchy, i == 0, add)
+	}
+	for _, kind := range []ChangeKind{ChangeAddAttribute, ChangeAddHierarchy, ChangeAddElement, ChangeRemoveEdge, ChangeElementType, ChangeAddEdge, ChangeEdgeWeight, ChangeRemoveElement, ChangeAttributeValue, ChangeLocalizedValue} {
+		diff.Changes = append(diff.Changes, changes[kind]...)
+	}
+	return diff
+}
+
+// edgeKey returns the key identifying the edge linking parent and component
+func edgeKey(parent, component string) string {
+	return nameKey(parent) + "\x00" + nameKey(component)
+}
+
+// diffHierarchy reports, using add, the changes required to turn the current hierarchy into the desired one
+func diffHierarchy(current, desired *Hierarchy, attributes bool, add func(Change)) {
+	// Elements
+	currentElements := make(map[string]*Element)
+	for i := range current.Elements {
+		currentElements[nameKey(current.Elements[i].Name)] = &current.Elements[i]
+	}
+	desiredElements := make(map[string]*Element)
+	for i := range desired.Elements {
+		element := &desired.Elements[i]
+		desiredElements[nameKey(element.Name)] = element
+		currentElement := currentElements[nameKey(element.Name)]
+		if currentElement == nil {
+			add(Change{Kind: ChangeAddElement, Hierarchy: desired.Name, Element: element.Name, New: element.Type})
+		} else if element.Type != "" && element.Type != currentElement.Type {
+			// Elements without explicit type get whatever type the TM1 server derives, so only compare explicit ones
+			add(Change{Kind: ChangeElementType, Hierarchy: desired.Name, Element: element.Name, Old: currentElement.Type, New: element.Type})
+		}
+	}
+	for _, element := range current.Elements {
+		if desiredElements[nameKey(element.Name)] == nil {
+			add(Change{Kind: ChangeRemoveElement, Hierarchy: desired.Name, Element: element.Name})
+		}
+	}
+
+	// Edges, a re-parented element simply shows as an edge removed and another one added
+	currentEdges := make(map[string]*Edge)
+	for i := range current.Edges {
+		currentEdges[edgeKey(current.Edges[i].ParentName, current.Edges[i].ComponentName)] = &current.Edges[i]
+	}
+	desiredEdges := make(map[string]*Edge)
+	for i := range desired.Edges {
+		edge := &desired.Edges[i]
+		desiredEdges[edgeKey(edge.ParentName, edge.ComponentName)] = edge
+		currentEdge := currentEdges[edgeKey(edge.ParentName, edge.ComponentName)]
+		if currentEdge == nil {
+			add(Change{Kind: ChangeAddEdge, Hierarchy: desired.Name, Parent: edge.ParentName, Element: edge.ComponentName, New: edge.Weight})
+		} else if edge.Weight != currentEdge.Weight {
+			add(Change{Kind: ChangeEdgeWeight, Hierarchy: desired.Name, Parent: edge.ParentName, Element: edge.ComponentName, Old: currentEdge.Weight, New: edge.Weight})
+		}
+	}
+	for _, edge := range current.Edges {
+		if desiredEdges[edgeKey(edge.ParentName, edge.ComponentName)] != nil {
+			continue
+		}
+		// Removing an element removes its edges as well
+		if desiredElements[nameKey(edge.ParentName)] == nil || desiredElements[nameKey(edge.ComponentName)] == nil {
+			continue
+		}
+		add(Change{Kind: ChangeRemoveEdge, Hierarchy: desired.Name, Parent: edge.ParentName, Element: edge.ComponentName})
+	}
+
+	// Localized attribute values are compared for every hierarchy, the attributes, and their values, only for the first
+	diffLocalized(current, desired, add)
+	if attributes == false {
+		return
+	}
+
+	// Attributes and their values, in which we treat a value that isn't set the same as an empty one
+	for _, attribute := range desired.Attributes {
+		if current.Attribute(attribute.Name) == nil {
+			add(Change{Kind: ChangeAddAttribute, Hierarchy: desired.Name, Attribute: attribute.Name, New: attribute.Type})
+		}
+	}
+	for _, element := range desired.Elements {
+		for _, attribute := range desired.Attributes {
+			currentValue := attributeValue(current, element.Name, attribute)
+			desiredValue := attributeValue(desired, element.Name, attribute)
+			if currentValue != desiredValue {
+				add(Change{Kind: ChangeAttributeValue, Hierarchy: desired.Name, Element: element.Name, Attribute: attribute.Name, Old: currentValue, New: desiredValue})
+			}
+		}
+	}
+}
+
+// diffLocalized reports, using add, the changes required to turn the current localized attribute values of the
+// hierarchy into the desired ones, in order of element, culture and attribute to keep the changes deterministic
+func diffLocalized(current, desired *Hierarchy, add func(Change)) {
+	for _, element := range desired.LocalizedElements() {
+		for _, localized := range desired.LocalizedAttributes(element) {
+			currentValues := localizedValues(current, element, localized.LocaleID)
+			attributes := make([]string, 0, len(localized.Attributes))
+			for attribute := range localized.Attributes {
+				attributes = append(attributes, attribute)
+			}
+			sort.Strings(attributes)
+			for _, attribute := range attributes {
+				if currentValues[attribute] != localized.Attributes[attribute] {
+					add(Change{Kind: ChangeLocalizedValue, Hierarchy: desired.Name, Element: element, Attribute: attribute, Culture: localized.LocaleID, Old: currentValues[attribute], New: localized.Attributes[attribute]})
+				}
+			}
+		}
+	}
+}
+
+// localizedValues returns the localized attribute values of the element in the culture, or nil if the element
+// hasn't been localized for the culture
+func localizedValues(hierarchy *Hierarchy, element, culture string) map[string]string {
+	for name, cultures := range hierarchy.LocalizedValues {
+		if nameKey(name) != nameKey(element) {
+			continue
+		}
+		for localeID, values := range cultures {
+			if strings.EqualFold(localeID, culture) {
+				return values
+			}
+		}
+	}
+	return nil
+}
+
+// attributeValue returns the value of the attribute of the element, or the zero value for the type of the
+// attribute if it isn't set
+func attributeValue(hierarchy *Hierarchy, element string, attribute ElementAttribute) interface{} {
+	value := hierarchy.AttributeValues[element][attribute.Name]
+	if value == nil {
+		// Names are case and space insensitive, the current hierarchy might use a different spelling
+		for name, values := range hierarchy.AttributeValues {
+			if nameKey(name) == nameKey(element) {
+				value = values[attribute.Name]
+				break
+			}
+		}
+	}
+	if value == nil {
+		if attribute.Type == AttributeNumeric {
+			return 0.0
+		}
+		return ""
+	}
+	return value
+}
+
+// Empty reports whether there are no changes at all
+func (diff *DimensionDiff) Empty() bool {
+	return len(diff.Changes) == 0
+}
+
+// Apply applies the changes to the dimension on the TM1 server, using the minimal set of POST, PATCH and DELETE
+// requests, which get sent as a single batch, in one change set, so either all of them succeed or none of them do
+func (diff *DimensionDiff) Apply(ctx context.Context, client *odata.Client, root string) error {
+	if diff.Empty() {
+		return nil
+	}
+	batch := &odata.Batch{}
+	changeSet := batch.SequentialChangeSet()
+	var attributes *CellWriter
+	var localized []*Change
+	for i, change := range diff.Changes {
+		hierarchy := HierarchyPath(diff.Dimension, change.Hierarchy)
+		switch change.Kind {
+		case ChangeAddAttribute:
+			jAttribute, _ := json.Marshal(ElementAttribute{Name: change.Attribute, Type: change.New.(AttributeType)})
+			changeSet.Add("POST", ElementAttributesPath(diff.Dimension, change.Hierarchy), string(jAttribute))
+		case ChangeAddHierarchy:
+			jHierarchy, _ := json.Marshal(diff.desiredHierarchy(change.Hierarchy))
+			changeSet.Add("POST", DimensionPath(diff.Dimension)+"/Hierarchies", string(jHierarchy))
+		case ChangeAddElement:
+			jElement, _ := json.Marshal(Element{Name: change.Element, Type: change.New.(ElementType)})
+			changeSet.Add("POST", hierarchy+"/Elements", string(jElement))
+		case ChangeElementType:
+			jElement, _ := json.Marshal(struct{ Type ElementType }{change.New.(ElementType)})
+			changeSet.Add("PATCH", ElementPath(diff.Dimension, change.Hierarchy, change.Element), string(jElement))
+		case ChangeRemoveEdge:
+			changeSet.Add("DELETE", EdgePath(diff.Dimension, change.Hierarchy, change.Parent, change.Element), "")
+		case ChangeAddEdge:
+			jEdge, _ := json.Marshal(Edge{ParentName: change.Parent, ComponentName: change.Element, Weight: change.New.(float64)})
+			changeSet.Add("POST", hierarchy+"/Edges", string(jEdge))
+		case ChangeEdgeWeight:
+			jEdge, _ := json.Marshal(struct{ Weight float64 }{change.New.(float64)})
+			changeSet.Add("PATCH", EdgePath(diff.Dimension, change.Hierarchy, change.Parent, change.Element), string(jEdge))
+		case ChangeRemoveElement:
+			changeSet.Add("DELETE", ElementPath(diff.Dimension, change.Hierarchy, change.Element), "")
+		case ChangeAttributeValue:
+			// Attribute values all get written in one go, using the Update action of the attributes cube
+			if attributes == nil {
+				attributesCube := AttributesCubeName(diff.Dimension)
+				attributes = &CellWriter{Cube: attributesCube, Hierarchies: []HierarchyRef{{diff.Dimension, change.Hierarchy}, {attributesCube, attributesCube}}}
+			}
+			var err error
+			switch value := change.New.(type) {
+			case float64:
+				err = attributes.WriteNumber(value, change.Element, change.Attribute)
+			default:
+				err = attributes.WriteString(fmt.Sprint(value), change.Element, change.Attribute)
+			}
+			if err != nil {
+				return err
+			}
+		case ChangeLocalizedValue:
+			// Localized attribute values get written per element and culture, once all other changes are made
+			localized = append(localized, &diff.Changes[i])
+		}
+	}
+	if attributes != nil {
+		jAttributes, err := attributes.JSON()
+		if err != nil {
+			return err
+		}
+		changeSet.Add("POST", attributes.Path(), jAttributes)
+	}
+	diff.applyLocalized(changeSet, localized)
+
+	result, err := client.ExecuteBatch(ctx, root, batch)
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// desiredHierarchy returns the desired definition of the hierarchy with the specified name
+func (diff *DimensionDiff) desiredHierarchy(name string) *Hierarchy {
+	for _, hierarchy := range diff.desired.Hierarchies {
+		if nameKey(hierarchy.Name) == nameKey(name) {
+			return hierarchy
+		}
+	}
+	return nil
+}
+
+// applyLocalized adds the requests setting the changed localized attribute values to the change set, one per element
+// and culture. An element that hasn't been localized for the culture yet gets its values POSTed, otherwise the
+// values get PATCHed, passing the current values of the attributes that don't change along, so none get lost.
+func (diff *DimensionDiff) applyLocalized(changeSet *odata.ChangeSet, changes []*Change) {
+	var keys []string
+	updates := make(map[string]*LocalizedAttributes)
+	elements := make(map[string]*Change)
+	for _, change := range changes {
+		key := nameKey(change.Hierarchy) + "\x00" + nameKey(change.Element) + "\x00" + strings.ToLower(change.Culture)
+		if updates[key] == nil {
+			keys = append(keys, key)
+			elements[key] = change
+			updates[key] = &LocalizedAttributes{LocaleID: change.Culture, Attributes: make(map[string]string)}
+			if hierarchy := diff.currentHierarchy(change.Hierarchy); hierarchy != nil {
+				for attribute, value := range localizedValues(hierarchy, change.Element, change.Culture) {
+					updates[key].Attributes[attribute] = value
+				}
+			}
+		}
+		updates[key].Attributes[change.Attribute] = change.New.(string)
+	}
+	for _, key := range keys {
+		change := elements[key]
+		path := LocalizedAttributesPath(diff.Dimension, change.Hierarchy, change.Element)
+		hierarchy := diff.currentHierarchy(change.Hierarchy)
+		if hierarchy == nil || localizedValues(hierarchy, change.Element, change.Culture) == nil {
+			jLocalized, _ := json.Marshal(updates[key])
+			changeSet.Add("POST", path, string(jLocalized))
+		} else {
+			jLocalized, _ := json.Marshal(struct{ Attributes map[string]string }{updates[key].Attributes})
+			changeSet.Add("PATCH", path+odata.StringKey(change.Culture), string(jLocalized))
+		}
+	}
+}
+
+// currentHierarchy returns the current definition of the hierarchy with the specified name, or nil if it doesn't
+// exist on the server yet
+func (diff *DimensionDiff) currentHierarchy(name string) *Hierarchy {
+	for _, hierarchy := range diff.current.Hierarchies {
+		if nameKey(hierarchy.Name) == nameKey(name) {
+			return hierarchy
+		}
+	}
+	return nil
+}
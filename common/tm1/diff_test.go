@@ -0,0 +1,181 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// productsDimension returns a Products dimension with a total, the elements, with their captions, and, optionally,
+// the French captions of the elements
+func productsDimension(elements map[string]string, localized map[string]string) *Dimension {
+	dimension := CreateDimension("Products")
+	hierarchy := dimension.AddHierarchy("Products")
+	hierarchy.AddElement("Total", "All Products")
+	for _, name := range []string{"Chai", "Chang", "Ikura"} {
+		caption, ok := elements[name]
+		if !ok {
+			continue
+		}
+		hierarchy.AddElement(name, caption)
+		hierarchy.AddEdge("Total", name)
+	}
+	for element, caption := range localized {
+		hierarchy.SetLocalizedCaption(element, "fr", caption)
+	}
+	return dimension
+}
+
+// typedDimension returns a Products dimension with a Total, of the passed type, and a Chai element, linked by the
+// passed edges, if any
+func typedDimension(totalType ElementType, edges ...[2]string) *Dimension {
+	dimension := CreateDimension("Products")
+	hierarchy := dimension.AddHierarchy("Products")
+	hierarchy.AddTypedElement("Total", "", totalType)
+	hierarchy.AddTypedElement("Chai", "", ElementNumeric)
+	for _, edge := range edges {
+		hierarchy.AddEdge(edge[0], edge[1])
+	}
+	return dimension
+}
+
+func TestDiffDimension(t *testing.T) {
+	tests := []struct {
+		name    string
+		current *Dimension
+		desired *Dimension
+		want    []Change
+	}{
+		{
+			name:    "unchanged",
+			current: productsDimension(map[string]string{"Chai": "Chai", "Chang": "Chang"}, nil),
+			desired: productsDimension(map[string]string{"Chai": "Chai", "Chang": "Chang"}, nil),
+		},
+		{
+			name:    "element added and removed",
+			current: productsDimension(map[string]string{"Chai": "Chai", "Chang": "Chang"}, nil),
+			desired: productsDimension(map[string]string{"Chai": "Chai", "Ikura": "Ikura"}, nil),
+			want: []Change{
+				{Kind: ChangeAddElement, Hierarchy: "Products", Element: "Ikura", New: ElementType("")},
+				{Kind: ChangeAddEdge, Hierarchy: "Products", Parent: "Total", Element: "Ikura", New: 1.0},
+				{Kind: ChangeRemoveElement, Hierarchy: "Products", Element: "Chang"},
+				{Kind: ChangeAttributeValue, Hierarchy: "Products", Element: "Ikura", Attribute: CaptionAttribute, Old: "", New: "Ikura"},
+			},
+		},
+		{
+			name:    "caption changed",
+			current: productsDimension(map[string]string{"Chai": "Chai"}, nil),
+			desired: productsDimension(map[string]string{"Chai": "Chai Tea"}, nil),
+			want: []Change{
+				{Kind: ChangeAttributeValue, Hierarchy: "Products", Element: "Chai", Attribute: CaptionAttribute, Old: "Chai", New: "Chai Tea"},
+			},
+		},
+		{
+			name:    "names compared ignoring case and spaces",
+			current: productsDimension(map[string]string{"Chai": "Chai"}, nil),
+			desired: func() *Dimension {
+				dimension := productsDimension(map[string]string{"Chai": "Chai"}, nil)
+				dimension.Hierarchies[0].Name = "products"
+				dimension.Hierarchies[0].Elements[0].Name = "TO TAL"
+				dimension.Hierarchies[0].Edges[0].ParentName = "total"
+				return dimension
+			}(),
+		},
+		{
+			name:    "localized captions",
+			current: productsDimension(map[string]string{"Chai": "Chai", "Chang": "Chang"}, map[string]string{"Chai": "Thé"}),
+			desired: productsDimension(map[string]string{"Chai": "Chai", "Chang": "Chang"}, map[string]string{"Chai": "Thé Chai", "Chang": "Chang"}),
+			want: []Change{
+				{Kind: ChangeLocalizedValue, Hierarchy: "Products", Element: "Chai", Attribute: CaptionAttribute, Culture: "fr", Old: "Thé", New: "Thé Chai"},
+				{Kind: ChangeLocalizedValue, Hierarchy: "Products", Element: "Chang", Attribute: CaptionAttribute, Culture: "fr", Old: "", New: "Chang"},
+			},
+		},
+		{
+			name:    "localized captions only on the server are left alone",
+			current: productsDimension(map[string]string{"Chai": "Chai"}, map[string]string{"Chai": "Thé"}),
+			desired: productsDimension(map[string]string{"Chai": "Chai"}, nil),
+		},
+		{
+			name:    "hierarchy added",
+			current: productsDimension(map[string]string{"Chai": "Chai"}, nil),
+			desired: func() *Dimension {
+				dimension := productsDimension(map[string]string{"Chai": "Chai"}, nil)
+				dimension.AddHierarchy("By Supplier").AddElement("Exotic Liquids", "")
+				return dimension
+			}(),
+			want: []Change{
+				{Kind: ChangeAddHierarchy, Hierarchy: "By Supplier"},
+			},
+		},
+		{
+			name:    "consolidated element becoming a leaf",
+			current: typedDimension(ElementConsolidated, [2]string{"Total", "Chai"}),
+			desired: typedDimension(ElementNumeric),
+			want: []Change{
+				{Kind: ChangeRemoveEdge, Hierarchy: "Products", Parent: "Total", Element: "Chai"},
+				{Kind: ChangeElementType, Hierarchy: "Products", Element: "Total", Old: ElementConsolidated, New: ElementNumeric},
+			},
+		},
+		{
+			name:    "leaf becoming a consolidated element",
+			current: typedDimension(ElementNumeric),
+			desired: typedDimension(ElementConsolidated, [2]string{"Total", "Chai"}),
+			want: []Change{
+				{Kind: ChangeElementType, Hierarchy: "Products", Element: "Total", Old: ElementNumeric, New: ElementConsolidated},
+				{Kind: ChangeAddEdge, Hierarchy: "Products", Parent: "Total", Element: "Chai", New: 1.0},
+			},
+		},
+	}
+	for _, test := range tests {
+		diff := DiffDimension(test.current, test.desired)
+		if len(test.want) == 0 {
+			if !diff.Empty() {
+				t.Errorf("%s: got changes %v, want none", test.name, diff.Changes)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(diff.Changes, test.want) {
+			t.Errorf("%s: got changes\n%v\nwant\n%v", test.name, diff.Changes, test.want)
+		}
+	}
+}
+
+func TestDimensionDiffApply(t *testing.T) {
+	verbose := odata.Verbose
+	odata.Verbose = false
+	t.Cleanup(func() { odata.Verbose = verbose })
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch odata.Batch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		result := odata.BatchResult{}
+		for _, req := range batch.Requests {
+			requests = append(requests, req.Method+" "+req.URL)
+			result.Responses = append(result.Responses, &odata.BatchResponse{ID: req.ID, Status: http.StatusOK})
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	// A consolidated element can only become a leaf once its edges are gone
+	diff := DiffDimension(typedDimension(ElementConsolidated, [2]string{"Total", "Chai"}), typedDimension(ElementNumeric))
+	if err := diff.Apply(context.Background(), &odata.Client{}, server.URL+"/"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"DELETE " + EdgePath("Products", "Products", "Total", "Chai"),
+		"PATCH " + ElementPath("Products", "Products", "Total"),
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %v, want %v", requests, want)
+	}
+}
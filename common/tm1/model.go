@@ -0,0 +1,295 @@
+package tm1
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Model holds the definitions of the dimensions, cubes and processes making up a TM1 model
+type Model struct {
+	Dimensions []*Dimension
+	Cubes      []*Cube
+	Processes  []*Process
+}
+
+// The folders, within the model folder, holding the files for the different types of objects
+const (
+	dimensionsFolder = "dimensions"
+	cubesFolder      = "cubes"
+	processesFolder  = "processes"
+)
+
+// The files the objects of a model get written to are meant to be read, reviewed and diffed by humans. Attribute
+// values are stored with the elements and code, like rules and TI procedures, is stored as an array of lines.
+
+// DimensionFile defines the structure of the file a dimension gets written to
+type DimensionFile struct {
+	Name        string
+	Hierarchies []HierarchyFile
+}
+
+// HierarchyFile defines the structure of a single hierarchy in a dimension file
+type HierarchyFile struct {
+	Name       string
+	Attributes []ElementAttribute `json:",omitempty"`
+	Elements   []ElementFile
+	Edges      []Edge `json:",omitempty"`
+}
+
+// ElementFile defines the structure of a single element, with its attribute values, in a dimension file
+type ElementFile struct {
+	Name       string
+	Type       ElementType                  `json:",omitempty"`
+	Attributes map[string]interface{}       `json:",omitempty"`
+	Localized  map[string]map[string]string `json:",omitempty"`
+}
+
+// CubeFile defines the structure of the file a cube gets written to
+type CubeFile struct {
+	Name       string
+	Dimensions []string
+	Rules      []string `json:",omitempty"`
+}
+
+// ProcessFile defines the structure of the file a process gets written to
+type ProcessFile struct {
+	Name              string
+	HasSecurityAccess bool
+	Parameters        []ProcessParameter `json:",omitempty"`
+	DataSource        json.RawMessage    `json:",omitempty"`
+	Variables         []ProcessVariable  `json:",omitempty"`
+	Prolog            []string           `json:",omitempty"`
+	Metadata          []string           `json:",omitempty"`
+	Data              []string           `json:",omitempty"`
+	Epilog            []string           `json:",omitempty"`
+}
+
+// splitLines splits code into its lines, dropping the carriage returns TM1 uses in line breaks
+func splitLines(code string) []string {
+	if code == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+}
+
+// joinLines joins the lines into code, using the line breaks TM1 uses
+func joinLines(lines []string) string {
+	return strings.Join(lines, "\r\n")
+}
+
+// File returns the dimension in the structure of the file it gets written to
+func (dimension *Dimension) File() *DimensionFile {
+	file := &DimensionFile{Name: dimension.Name, Hierarchies: make([]HierarchyFile, len(dimension.Hierarchies))}
+	for i, hierarchy := range dimension.Hierarchies {
+		file.Hierarchies[i] = HierarchyFile{Name: hierarchy.Name, Attributes: hierarchy.Attributes, Elements: make([]ElementFile, len(hierarchy.Elements)), Edges: hierarchy.Edges}
+		for j, element := range hierarchy.Elements {
+			elementFile := ElementFile{Name: element.Name, Type: element.Type}
+			if values := hierarchy.AttributeValues[element.Name]; len(values) > 0 {
+				elementFile.Attributes = values
+			}
+			if localized := hierarchy.LocalizedValues[element.Name]; len(localized) > 0 {
+				elementFile.Localized = localized
+			}
+			file.Hierarchies[i].Elements[j] = elementFile
+		}
+	}
+	return file
+}
+
+// Dimension returns the dimension defined by the file
+func (file *DimensionFile) Dimension() (*Dimension, error) {
+	dimension := CreateDimension(file.Name)
+	for _, hierarchyFile := range file.Hierarchies {
+		hierarchy := dimension.AddHierarchy(hierarchyFile.Name)
+		hierarchy.Attributes = append([]ElementAttribute{}, hierarchyFile.Attributes...)
+		hierarchy.Edges = hierarchyFile.Edges
+		for _, element := range hierarchyFile.Elements {
+			hierarchy.Elements = append(hierarchy.Elements, Element{Name: element.Name, Type: element.Type})
+			for attribute, value := range element.Attributes {
+				var err error
+				if number, ok := value.(float64); ok {
+					err = hierarchy.SetNumericAttribute(element.Name, attribute, number)
+				} else if text, ok := value.(string); ok {
+					err = hierarchy.SetStringAttribute(element.Name, attribute, text)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			for culture, values := range element.Localized {
+				for attribute, value := range values {
+					if err := hierarchy.SetLocalizedAttribute(element.Name, culture, attribute, value); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+	return dimension, nil
+}
+
+// File returns the cube in the structure of the file it gets written to
+func (cube *Cube) File() *CubeFile {
+	return &CubeFile{Name: cube.Name, Dimensions: cube.Dimensions, Rules: splitLines(cube.Rules)}
+}
+
+// Cube returns the cube defined by the file
+func (file *CubeFile) Cube() *Cube {
+	return &Cube{Name: file.Name, Dimensions: file.Dimensions, Rules: joinLines(file.Rules)}
+}
+
+// File returns the process in the structure of the file it gets written to
+func (process *Process) File() *ProcessFile {
+	return &ProcessFile{
+		Name:              process.Name,
+		HasSecurityAccess: process.HasSecurityAccess,
+		Parameters:        process.Parameters,
+		DataSource:        process.DataSource,
+		Variables:         process.Variables,
+		Prolog:            splitLines(process.PrologProcedure),
+		Metadata:          splitLines(process.MetadataProcedure),
+		Data:              splitLines(process.DataProcedure),
+		Epilog:            splitLines(process.EpilogProcedure),
+	}
+}
+
+// Process returns the process defined by the file
+func (file *ProcessFile) Process() *Process {
+	return &Process{
+		Name:              file.Name,
+		HasSecurityAccess: file.HasSecurityAccess,
+		Parameters:        file.Parameters,
+		DataSource:        file.DataSource,
+		Variables:         file.Variables,
+		PrologProcedure:   joinLines(file.Prolog),
+		MetadataProcedure: joinLines(file.Metadata),
+		DataProcedure:     joinLines(file.Data),
+		EpilogProcedure:   joinLines(file.Epilog),
+	}
+}
+
+// WriteModel writes the model to the specified folder, one file per object, in a subfolder per type of object.
+// Any file in those subfolders for an object that is no longer part of the model gets removed.
+func WriteModel(folder string, model *Model) error {
+	files := make(map[string]map[string]interface{})
+	files[dimensionsFolder] = make(map[string]interface{})
+	for _, dimension := range model.Dimensions {
+		files[dimensionsFolder][dimension.Name] = dimension.File()
+	}
+	files[cubesFolder] = make(map[string]interface{})
+	for _, cube := range model.Cubes {
+		files[cubesFolder][cube.Name] = cube.File()
+	}
+	files[processesFolder] = make(map[string]interface{})
+	for _, process := range model.Processes {
+		files[processesFolder][process.Name] = process.File()
+	}
+
+	for subfolder, objects := range files {
+		path := filepath.Join(folder, subfolder)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+		existing, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, file := range existing {
+			if _, ok := objects[strings.TrimSuffix(filepath.Base(file), ".json")]; !ok {
+				if err = os.Remove(file); err != nil {
+					return err
+				}
+			}
+		}
+		for name, object := range objects {
+			jObject, err := json.MarshalIndent(object, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err = ioutil.WriteFile(filepath.Join(path, name+".json"), append(jObject, '\n'), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadModel reads the model, as written by WriteModel, from the specified folder. Missing subfolders are simply
+// treated as empty.
+func ReadModel(folder string) (*Model, error) {
+	model := &Model{}
+	err := readFiles(filepath.Join(folder, dimensionsFolder), func(data []byte) error {
+		file := &DimensionFile{}
+		if err := json.Unmarshal(data, file); err != nil {
+			return err
+		}
+		dimension, err := file.Dimension()
+		if err != nil {
+			return err
+		}
+		model.Dimensions = append(model.Dimensions, dimension)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readFiles(filepath.Join(folder, cubesFolder), func(data []byte) error {
+		file := &CubeFile{}
+		if err := json.Unmarshal(data, file); err != nil {
+			return err
+		}
+		model.Cubes = append(model.Cubes, file.Cube())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = readFiles(filepath.Join(folder, processesFolder), func(data []byte) error {
+		file := &ProcessFile{}
+		if err := json.Unmarshal(data, file); err != nil {
+			return err
+		}
+		model.Processes = append(model.Processes, file.Process())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// readFiles calls processFile with the content of every JSON file, in alphabetical order, in the folder
+func readFiles(folder string, processFile func([]byte) error) error {
+	files, err := filepath.Glob(filepath.Join(folder, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err = processFile(data); err != nil {
+			return &FileError{File: file, Err: err}
+		}
+	}
+	return nil
+}
+
+// FileError is returned when a file of a model could not be processed
+type FileError struct {
+	File string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.File + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,109 @@
+package tm1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		code  string
+		lines []string
+	}{
+		{"", nil},
+		{"SKIPCHECK;", []string{"SKIPCHECK;"}},
+		{"SKIPCHECK;\r\n\r\n['Revenue'] = N: ['Quantity'] * ['Price'];", []string{"SKIPCHECK;", "", "['Revenue'] = N: ['Quantity'] * ['Price'];"}},
+	}
+	for _, test := range tests {
+		lines := splitLines(test.code)
+		if !reflect.DeepEqual(lines, test.lines) {
+			t.Errorf("%q: got %q, want %q", test.code, lines, test.lines)
+		}
+		if code := joinLines(lines); code != test.code {
+			t.Errorf("%q: joined back into %q", test.code, code)
+		}
+	}
+}
+
+func TestModelRoundTrip(t *testing.T) {
+	products := CreateDimension("Products")
+	hierarchy := products.AddHierarchy("Products")
+	hierarchy.AddAttribute("Price", AttributeNumeric)
+	hierarchy.AddTypedElement("Total", "All Products", ElementConsolidated)
+	hierarchy.AddElement("Chai", "Chai")
+	hierarchy.AddWeightedEdge("Total", "Chai", 2)
+	hierarchy.SetNumericAttribute("Chai", "Price", 18)
+	hierarchy.SetLocalizedCaption("Chai", "fr", "Thé")
+	products.AddHierarchy("By Supplier").AddElement("Exotic Liquids", "")
+
+	model := &Model{
+		Dimensions: []*Dimension{products},
+		Cubes:      []*Cube{{Name: "Sales", Dimensions: []string{"Products", "Measures"}, Rules: "SKIPCHECK;\r\n['Revenue'] = N: 1;"}},
+		Processes: []*Process{{
+			Name:            "Reload",
+			PrologProcedure: "CubeClearData('Sales');\r\nSaveDataAll;",
+			DataSource:      json.RawMessage(`{"Type":"None"}`),
+			Parameters:      []ProcessParameter{{Name: "pYear", Value: 1997.0, Type: "Numeric"}},
+		}},
+	}
+	folder := t.TempDir()
+
+	// A file of an object that no longer is part of the model gets removed
+	stale := filepath.Join(folder, cubesFolder, "Budget.json")
+	if err := os.MkdirAll(filepath.Dir(stale), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteModel(folder, model); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("got %v for the stale cube file, want it removed", err)
+	}
+	got, err := ReadModel(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The data source gets written indented, compact it again before comparing it
+	for _, process := range got.Processes {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, process.DataSource); err != nil {
+			t.Fatal(err)
+		}
+		process.DataSource = compact.Bytes()
+	}
+	if !reflect.DeepEqual(got.Dimensions, model.Dimensions) {
+		t.Errorf("got dimension\n%+v\nwant\n%+v", got.Dimensions[0].Hierarchies[0], model.Dimensions[0].Hierarchies[0])
+	}
+	if !reflect.DeepEqual(got.Cubes, model.Cubes) {
+		t.Errorf("got cubes %+v, want %+v", got.Cubes, model.Cubes)
+	}
+	if !reflect.DeepEqual(got.Processes, model.Processes) {
+		t.Errorf("got processes %+v, want %+v", got.Processes, model.Processes)
+	}
+}
+
+func TestReadModelInvalidFile(t *testing.T) {
+	folder := t.TempDir()
+	file := filepath.Join(folder, dimensionsFolder, "Products.json")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(`{"Name":"Products","Hierarchies":[{"Name":"Products","Elements":[{"Name":"Chai","Attributes":{"Price":18}}]}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ReadModel(folder)
+	var fileErr *FileError
+	if !errors.As(err, &fileErr) || fileErr.File != file {
+		t.Errorf("got %v, want a file error for %s", err, file)
+	}
+}
@@ -0,0 +1,109 @@
+package tm1
+
+import (
+	"strconv"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// The functions in here return the paths, relative to the service root, of the entities in the TM1 Server schema.
+// Names of objects in TM1 can contain pretty much any character, including single quotes, spaces, slashes and the
+// curly braces used by control objects like '}ElementAttributes_Customers', so never concatenate a name into a
+// path yourself but use these functions, which properly escape the names, instead. The returned paths can be used
+// in request URLs as well as for entity references, e.g. in @odata.bind annotations.
+
+// DimensionPath returns the path of the dimension with the specified name
+func DimensionPath(dimension string) string {
+	return "Dimensions" + odata.StringKey(dimension)
+}
+
+// HierarchyPath returns the path of the specified hierarchy in the specified dimension
+func HierarchyPath(dimension, hierarchy string) string {
+	return DimensionPath(dimension) + "/Hierarchies" + odata.StringKey(hierarchy)
+}
+
+// ElementPath returns the path of the specified element in the specified hierarchy
+func ElementPath(dimension, hierarchy, element string) string {
+	return HierarchyPath(dimension, hierarchy) + "/Elements" + odata.StringKey(element)
+}
+
+// EdgePath returns the path of the edge linking the specified parent and component in the specified hierarchy
+func EdgePath(dimension, hierarchy, parent, component string) string {
+	return HierarchyPath(dimension, hierarchy) + "/Edges(ParentName=" + odata.PathLiteral(parent) + ",ComponentName=" + odata.PathLiteral(component) + ")"
+}
+
+// ElementAttributesPath returns the path of the collection of element attributes of the specified hierarchy
+func ElementAttributesPath(dimension, hierarchy string) string {
+	return HierarchyPath(dimension, hierarchy) + "/ElementAttributes"
+}
+
+// LocalizedAttributesPath returns the path of the collection of localized attribute values of the specified element
+func LocalizedAttributesPath(dimension, hierarchy, element string) string {
+	return ElementPath(dimension, hierarchy, element) + "/LocalizedAttributes"
+}
+
+// CubePath returns the path of the cube with the specified name
+func CubePath(cube string) string {
+	return "Cubes" + odata.StringKey(cube)
+}
+
+// ViewsPath returns the path of the collection of, public or private, views on the cube with the specified name
+func ViewsPath(cube string, private bool) string {
+	if private == true {
+		return CubePath(cube) + "/PrivateViews"
+	}
+	return CubePath(cube) + "/Views"
+}
+
+// ViewPath returns the path of the, public or private, view with the specified name on the specified cube
+func ViewPath(cube, view string, private bool) string {
+	return ViewsPath(cube, private) + odata.StringKey(view)
+}
+
+// SubsetsPath returns the path of the collection of, public or private, subsets of the specified hierarchy
+func SubsetsPath(dimension, hierarchy string, private bool) string {
+	if private == true {
+		return HierarchyPath(dimension, hierarchy) + "/PrivateSubsets"
+	}
+	return HierarchyPath(dimension, hierarchy) + "/Subsets"
+}
+
+// SubsetPath returns the path of the, public or private, subset with the specified name of the specified hierarchy
+func SubsetPath(dimension, hierarchy, subset string, private bool) string {
+	return SubsetsPath(dimension, hierarchy, private) + odata.StringKey(subset)
+}
+
+// ProcessPath returns the path of the TurboIntegrator process with the specified name
+func ProcessPath(process string) string {
+	return "Processes" + odata.StringKey(process)
+}
+
+// ErrorLogFilePath returns the path of the error log file, written by a process, with the specified name
+func ErrorLogFilePath(filename string) string {
+	return "ErrorLogFiles" + odata.StringKey(filename)
+}
+
+// ChorePath returns the path of the chore with the specified name
+func ChorePath(chore string) string {
+	return "Chores" + odata.StringKey(chore)
+}
+
+// ChoreTaskPath returns the path of the task, identified by its zero based step, of the chore with the specified name
+func ChoreTaskPath(chore string, step int) string {
+	return ChorePath(chore) + "/Tasks(" + strconv.Itoa(step) + ")"
+}
+
+// GitPlanPath returns the path of the git plan with the specified ID
+func GitPlanPath(id string) string {
+	return "GitPlans" + odata.StringKey(id)
+}
+
+// AttributesCubeName returns the name of the control cube holding the element attributes of the specified dimension
+func AttributesCubeName(dimension string) string {
+	return "}ElementAttributes_" + dimension
+}
+
+// CellSetPath returns the path of the cellset with the specified ID
+func CellSetPath(id string) string {
+	return "Cellsets" + odata.StringKey(id)
+}
@@ -0,0 +1,36 @@
+package tm1
+
+import "testing"
+
+func TestPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"dimension", DimensionPath("Products"), "Dimensions('Products')"},
+		{"dimension with quote and space", DimensionPath("O'Neil Sons"), "Dimensions('O''Neil%20Sons')"},
+		{"hierarchy", HierarchyPath("Time", "Leaves"), "Dimensions('Time')/Hierarchies('Leaves')"},
+		{"element with slash", ElementPath("Time", "Time", "1997/01"), "Dimensions('Time')/Hierarchies('Time')/Elements('1997%2F01')"},
+		{"edge", EdgePath("Products", "Products", "Beverages", "P-1"), "Dimensions('Products')/Hierarchies('Products')/Edges(ParentName='Beverages',ComponentName='P-1')"},
+		{"edge with quotes", EdgePath("Customers", "Customers", "Bon app'", "Cactus & co"), "Dimensions('Customers')/Hierarchies('Customers')/Edges(ParentName='Bon%20app''',ComponentName='Cactus%20&%20co')"},
+		{"element attributes", ElementAttributesPath("Products", "Products"), "Dimensions('Products')/Hierarchies('Products')/ElementAttributes"},
+		{"localized attributes", LocalizedAttributesPath("Time", "Time", "Jan"), "Dimensions('Time')/Hierarchies('Time')/Elements('Jan')/LocalizedAttributes"},
+		{"cube", CubePath("Sales"), "Cubes('Sales')"},
+		{"control cube", CubePath(AttributesCubeName("Customers")), "Cubes('%7DElementAttributes_Customers')"},
+		{"public view", ViewPath("Sales", "Revenue by Year", false), "Cubes('Sales')/Views('Revenue%20by%20Year')"},
+		{"private view", ViewPath("Sales", "Mine", true), "Cubes('Sales')/PrivateViews('Mine')"},
+		{"public subset", SubsetPath("Products", "Products", "All #1", false), "Dimensions('Products')/Hierarchies('Products')/Subsets('All%20%231')"},
+		{"private subset", SubsetPath("Products", "Products", "Mine", true), "Dimensions('Products')/Hierarchies('Products')/PrivateSubsets('Mine')"},
+		{"process", ProcessPath("}bedrock.cube.data?clear"), "Processes('%7Dbedrock.cube.data%3Fclear')"},
+		{"error log file", ErrorLogFilePath("TM1ProcessError_1.log"), "ErrorLogFiles('TM1ProcessError_1.log')"},
+		{"chore task", ChoreTaskPath("Nightly 100%", 2), "Chores('Nightly%20100%25')/Tasks(2)"},
+		{"git plan", GitPlanPath("abc+1"), "GitPlans('abc%2B1')"},
+		{"cellset", CellSetPath("iAAAAD"), "Cellsets('iAAAAD')"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, test.got, test.want)
+		}
+	}
+}
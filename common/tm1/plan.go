@@ -0,0 +1,261 @@
+package tm1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// PlanAction defines the action a step in a plan takes
+type PlanAction string
+
+// The actions a step in a plan can take
+const (
+	PlanCreate PlanAction = "create"
+	PlanUpdate PlanAction = "update"
+	PlanDelete PlanAction = "delete"
+)
+
+// PlanStep defines a single step in a plan, creating, updating or deleting a single object
+type PlanStep struct {
+	Action  PlanAction
+	Kind    string
+	Name    string
+	Changes []string
+	apply   func(ctx context.Context, client *odata.Client, root string) error
+}
+
+// String returns a human readable description of the step
+func (step PlanStep) String() string {
+	symbol := map[PlanAction]string{PlanCreate: "+", PlanUpdate: "~", PlanDelete: "-"}[step.Action]
+	description := fmt.Sprintf("%s %s %s '%s'", symbol, step.Action, step.Kind, step.Name)
+	for _, change := range step.Changes {
+		description += "\n    " + change
+	}
+	return description
+}
+
+// Plan holds the steps, in the order they need to be applied, that bring the objects on the TM1 server in line
+// with a model
+type Plan struct {
+	Steps []PlanStep
+}
+
+// Empty reports whether the server is in line with the model already
+func (plan *Plan) Empty() bool {
+	return len(plan.Steps) == 0
+}
+
+// isControlObject reports whether the name is the name of a control object
+func isControlObject(name string) bool {
+	return strings.HasPrefix(name, "}")
+}
+
+// PlanModel compares the model with the objects on the TM1 server and returns the plan that brings the server in
+// line with the model. Objects missing on the server get created, objects that differ get updated and objects on
+// the server that aren't part of the model get deleted. Control objects are managed by TM1 and left alone, if the
+// model has any they are skipped. Dimensions are created first and deleted last, so cubes never refer to missing
+// dimensions.
+// Note: the dimensions of an existing cube can't be changed, the plan fails if the model requires that.
+func PlanModel(ctx context.Context, client *odata.Client, root string, model *Model) (*Plan, error) {
+	dimensions, err := GetDimensions(ctx, client, root, false)
+	if err != nil {
+		return nil, err
+	}
+	cubes, err := GetCubes(ctx, client, root, false)
+	if err != nil {
+		return nil, err
+	}
+	processes, err := GetProcesses(ctx, client, root, false)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	var deletes []PlanStep
+
+	// Dimensions
+	currentDimensions := make(map[string]*Dimension)
+	for _, dimension := range dimensions {
+		currentDimensions[nameKey(dimension.Name)] = dimension
+	}
+	modelDimensions := make(map[string]bool)
+	for _, dimension := range model.Dimensions {
+		if isControlObject(dimension.Name) {
+			continue
+		}
+		if err = dimension.Validate(); err != nil {
+			return nil, err
+		}
+		modelDimensions[nameKey(dimension.Name)] = true
+		current := currentDimensions[nameKey(dimension.Name)]
+		if current == nil {
+			dimension := dimension
+			plan.Steps = append(plan.Steps, PlanStep{Action: PlanCreate, Kind: "dimension", Name: dimension.Name, apply: func(ctx context.Context, client *odata.Client, root string) error {
+				return PostDimension(ctx, client, root, dimension)
+			}})
+			continue
+		}
+		diff := DiffDimension(current, dimension)
+		if diff.Empty() {
+			continue
+		}
+		step := PlanStep{Action: PlanUpdate, Kind: "dimension", Name: dimension.Name, apply: diff.Apply}
+		for _, change := range diff.Changes {
+			step.Changes = append(step.Changes, change.String())
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	for _, dimension := range dimensions {
+		if modelDimensions[nameKey(dimension.Name)] == false {
+			name := dimension.Name
+			deletes = append(deletes, PlanStep{Action: PlanDelete, Kind: "dimension", Name: name, apply: func(ctx context.Context, client *odata.Client, root string) error {
+				return DeleteDimension(ctx, client, root, name)
+			}})
+		}
+	}
+
+	// Cubes
+	currentCubes := make(map[string]*Cube)
+	for _, cube := range cubes {
+		currentCubes[nameKey(cube.Name)] = cube
+	}
+	modelCubes := make(map[string]bool)
+	for _, cube := range model.Cubes {
+		if isControlObject(cube.Name) {
+			continue
+		}
+		modelCubes[nameKey(cube.Name)] = true
+		current := currentCubes[nameKey(cube.Name)]
+		cube := cube
+		if current == nil {
+			plan.Steps = append(plan.Steps, PlanStep{Action: PlanCreate, Kind: "cube", Name: cube.Name, apply: func(ctx context.Context, client *odata.Client, root string) error {
+				return PostCube(ctx, client, root, cube)
+			}})
+			continue
+		}
+		if sameNames(current.Dimensions, cube.Dimensions) == false {
+			return nil, fmt.Errorf("the dimensions of cube '%s' can't be changed from %q to %q", cube.Name, current.Dimensions, cube.Dimensions)
+		}
+		if joinLines(splitLines(current.Rules)) != joinLines(splitLines(cube.Rules)) {
+			plan.Steps = append(plan.Steps, PlanStep{Action: PlanUpdate, Kind: "cube", Name: cube.Name, Changes: []string{"update rules"}, apply: func(ctx context.Context, client *odata.Client, root string) error {
+				return UpdateCubeRules(ctx, client, root, cube.Name, cube.Rules)
+			}})
+		}
+	}
+	var cubeDeletes []PlanStep
+	for _, cube := range cubes {
+		if modelCubes[nameKey(cube.Name)] == false {
+			name := cube.Name
+			cubeDeletes = append(cubeDeletes, PlanStep{Action: PlanDelete, Kind: "cube", Name: name, apply: func(ctx context.Context, client *odata.Client, root string) error {
+				return DeleteCube(ctx, client, root, name)
+			}})
+		}
+	}
+
+	// Processes
+	currentProcesses := make(map[string]*Process)
+	for _, process := range processes {
+		currentProcesses[nameKey(process.Name)] = process
+	}
+	modelProcesses := make(map[string]bool)
+	for _, process := range model.Processes {
+		if isControlObject(process.Name) {
+			continue
+		}
+		modelProcesses[nameKey(process.Name)] = true
+		current := currentProcesses[nameKey(process.Name)]
+		process := process
+		if current == nil {
+			plan.Steps = append(plan.Steps, PlanStep{Action: PlanCreate, Kind: "process", Name: process.Name, apply: func(ctx context.Context, client *odata.Client, root string) error {
+				return PostProcess(ctx, client, root, process)
+			}})
+			continue
+		}
+		changes, err := processChanges(current, process)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) > 0 {
+			plan.Steps = append(plan.Steps, PlanStep{Action: PlanUpdate, Kind: "process", Name: process.Name, Changes: changes, apply: func(ctx context.Context, client *odata.Client, root string) error {
+				return UpdateProcess(ctx, client, root, process)
+			}})
+		}
+	}
+	var processDeletes []PlanStep
+	for _, process := range processes {
+		if modelProcesses[nameKey(process.Name)] == false {
+			name := process.Name
+			processDeletes = append(processDeletes, PlanStep{Action: PlanDelete, Kind: "process", Name: name, apply: func(ctx context.Context, client *odata.Client, root string) error {
+				return DeleteProcess(ctx, client, root, name)
+			}})
+		}
+	}
+
+	// Processes might refer to cubes, and cubes to dimensions, so delete in that order
+	plan.Steps = append(plan.Steps, processDeletes...)
+	plan.Steps = append(plan.Steps, cubeDeletes...)
+	plan.Steps = append(plan.Steps, deletes...)
+	return plan, nil
+}
+
+// sameNames reports whether both lists hold the same names, in the same order
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if nameKey(a[i]) != nameKey(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// processChanges returns the parts of the process that differ, comparing them in the form they are written to a
+// file in, which normalizes the line breaks and the formatting of the data source
+func processChanges(current, desired *Process) ([]string, error) {
+	currentFile, desiredFile := current.File(), desired.File()
+	parts := []struct {
+		name             string
+		current, desired interface{}
+	}{
+		{"security access", currentFile.HasSecurityAccess, desiredFile.HasSecurityAccess},
+		{"parameters", currentFile.Parameters, desiredFile.Parameters},
+		{"data source", currentFile.DataSource, desiredFile.DataSource},
+		{"variables", currentFile.Variables, desiredFile.Variables},
+		{"prolog", currentFile.Prolog, desiredFile.Prolog},
+		{"metadata", currentFile.Metadata, desiredFile.Metadata},
+		{"data", currentFile.Data, desiredFile.Data},
+		{"epilog", currentFile.Epilog, desiredFile.Epilog},
+	}
+	var changes []string
+	for _, part := range parts {
+		jCurrent, err := json.Marshal(part.current)
+		if err != nil {
+			return nil, err
+		}
+		jDesired, err := json.Marshal(part.desired)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(jCurrent, jDesired) == false {
+			changes = append(changes, "update "+part.name)
+		}
+	}
+	return changes, nil
+}
+
+// Apply applies the steps of the plan, one after the other, stopping at the first step that fails
+func (plan *Plan) Apply(ctx context.Context, client *odata.Client, root string) error {
+	for _, step := range plan.Steps {
+		if err := step.apply(ctx, client, root); err != nil {
+			return fmt.Errorf("%s %s '%s': %w", step.Action, step.Kind, step.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+func TestProcessChanges(t *testing.T) {
+	current := &Process{
+		Name:            "Reload",
+		PrologProcedure: "CubeClearData('Sales');\r\nSaveDataAll;",
+		DataSource:      json.RawMessage(`{"Type": "None"}`),
+		Parameters:      []ProcessParameter{{Name: "pYear", Value: 1997.0, Type: "Numeric"}},
+	}
+	tests := []struct {
+		name   string
+		change func(process *Process)
+		want   []string
+	}{
+		{"unchanged", func(process *Process) {}, nil},
+		{"line breaks and data source formatting", func(process *Process) {
+			process.PrologProcedure = "CubeClearData('Sales');\nSaveDataAll;"
+			process.DataSource = json.RawMessage(`{"Type":"None"}`)
+		}, nil},
+		{"code", func(process *Process) {
+			process.PrologProcedure = "CubeClearData('Sales');"
+			process.EpilogProcedure = "SaveDataAll;"
+		}, []string{"update prolog", "update epilog"}},
+		{"parameters and security access", func(process *Process) {
+			process.Parameters = []ProcessParameter{{Name: "pYear", Value: 1998.0, Type: "Numeric"}}
+			process.HasSecurityAccess = true
+		}, []string{"update security access", "update parameters"}},
+	}
+	for _, test := range tests {
+		desired := *current
+		test.change(&desired)
+		changes, err := processChanges(current, &desired)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !reflect.DeepEqual(changes, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, changes, test.want)
+		}
+	}
+}
+
+func TestPlanModel(t *testing.T) {
+	server, _ := readServer(t, map[string]string{
+		"/Dimensions": `{"value":[` +
+			`{"Name":"Measures","Hierarchies":[{"Name":"Measures","Elements":[{"Name":"Quantity","Attributes":{"Caption":"Quantity"}}],"ElementAttributes":[{"Name":"Caption","Type":"String"}]}]},` +
+			`{"Name":"Products","Hierarchies":[{"Name":"Products","Elements":[{"Name":"Chai","Attributes":{"Caption":"Chai"}}],"ElementAttributes":[{"Name":"Caption","Type":"String"}]}]},` +
+			`{"Name":"Suppliers","Hierarchies":[{"Name":"Suppliers","Elements":[{"Name":"Exotic Liquids"}]}]}]}`,
+		"/Cubes": `{"value":[` +
+			`{"Name":"Budget","Dimensions":[{"Name":"Products"}]},` +
+			`{"Name":"Sales","Rules":"SKIPCHECK;","Dimensions":[{"Name":"Products"},{"Name":"Measures"}]}]}`,
+		"/Processes": `{"value":[{"Name":"Reload","PrologProcedure":"SaveDataAll;"}]}`,
+	})
+
+	measures := CreateDimension("Measures")
+	measures.AddHierarchy("Measures").AddElement("Quantity", "Quantity")
+	products := CreateDimension("Products")
+	products.AddHierarchy("Products").AddElement("Chai", "Chai Tea")
+	customers := CreateDimension("Customers")
+	customers.AddHierarchy("Customers").AddElement("ALFKI", "")
+	model := &Model{
+		Dimensions: []*Dimension{measures, products, customers},
+		Cubes:      []*Cube{{Name: "Sales", Dimensions: []string{"Products", "Measures"}, Rules: "SKIPCHECK;\r\nFEEDERS;"}},
+		Processes:  []*Process{{Name: "Reload", PrologProcedure: "SaveDataAll;"}, {Name: "}Internal"}},
+	}
+	plan, err := PlanModel(context.Background(), &odata.Client{}, server.URL+"/", model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var steps []string
+	for _, step := range plan.Steps {
+		steps = append(steps, strings.SplitN(step.String(), "\n", 2)[0])
+	}
+	want := []string{
+		"~ update dimension 'Products'",
+		"+ create dimension 'Customers'",
+		"~ update cube 'Sales'",
+		"- delete cube 'Budget'",
+		"- delete dimension 'Suppliers'",
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("got steps\n%s\nwant\n%s", strings.Join(steps, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The dimensions of an existing cube can't be changed
+	model.Cubes[0].Dimensions = []string{"Measures", "Products"}
+	if _, err = PlanModel(context.Background(), &odata.Client{}, server.URL+"/", model); err == nil || !strings.Contains(err.Error(), "can't be changed") {
+		t.Errorf("changed cube dimensions: got %v, want an error", err)
+	}
+}
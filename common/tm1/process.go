@@ -0,0 +1,238 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// Process defines the structure of a single Process entity, a TurboIntegrator process, in the TM1 Server schema
+// Note: the data source is kept as is, its properties depending on the type of the data source.
+type Process struct {
+	Name              string
+	HasSecurityAccess bool
+	PrologProcedure   string
+	MetadataProcedure string
+	DataProcedure     string
+	EpilogProcedure   string
+	DataSource        json.RawMessage    `json:",omitempty"`
+	Parameters        []ProcessParameter `json:",omitempty"`
+	Variables         []ProcessVariable  `json:",omitempty"`
+}
+
+// ProcessParameter defines the structure of a single parameter of a process, the Value of which, depending on the
+// Type, either is a string or a number
+type ProcessParameter struct {
+	Name   string
+	Prompt string      `json:",omitempty"`
+	Value  interface{} `json:",omitempty"`
+	Type   string      `json:",omitempty"`
+}
+
+// ProcessVariable defines the structure of a single variable, the columns of the data source, of a process
+type ProcessVariable struct {
+	Name      string
+	Type      string
+	Position  int
+	StartByte int
+	EndByte   int
+}
+
+// Equal reports whether both processes have the same definition, their procedures, parameters, data source and
+// variables, compared in the form they are written to a file in, which normalizes the line breaks and formatting
+func (process *Process) Equal(other *Process) bool {
+	changes, err := processChanges(process, other)
+	return err == nil && len(changes) == 0 && nameKey(process.Name) == nameKey(other.Name)
+}
+
+// processQuery returns the query options selecting the properties of a process we read
+func processQuery(resource string) *odata.Query {
+	return odata.NewQuery(resource).Select("Name", "HasSecurityAccess", "PrologProcedure", "MetadataProcedure", "DataProcedure", "EpilogProcedure", "DataSource", "Parameters", "Variables")
+}
+
+// GetProcess retrieves the process with the specified name from the TM1 server
+// Note: if the process doesn't exist the returned error satisfies odata.IsNotFound
+func GetProcess(ctx context.Context, client *odata.Client, root, name string) (*Process, error) {
+	return odata.GetEntity[Process](ctx, client, root+processQuery(ProcessPath(name)).String())
+}
+
+// GetProcesses retrieves all processes, optionally including the control processes, from the TM1 server
+func GetProcesses(ctx context.Context, client *odata.Client, root string, controlObjects bool) ([]*Process, error) {
+	query := processQuery("Processes").OrderBy("Name")
+	if filter := controlObjectFilter(controlObjects); filter != nil {
+		query.Filter(filter)
+	}
+	res, err := odata.GetCollection[*Process](ctx, client, root+query.String())
+	if err != nil {
+		return nil, err
+	}
+	return res.Value, nil
+}
+
+// PostProcess creates the process on the TM1 server
+func PostProcess(ctx context.Context, client *odata.Client, root string, process *Process) error {
+	jProcess, err := json.Marshal(process)
+	if err != nil {
+		return err
+	}
+	resp, err := client.ExecutePOSTRequestContext(ctx, root+"Processes", "application/json", string(jProcess))
+	return expectStatus(resp, err, 201, func() string {
+		return "Failed to create process '" + process.Name + "'."
+	})
+}
+
+// UpdateProcess replaces the definition of the, existing, process on the TM1 server
+func UpdateProcess(ctx context.Context, client *odata.Client, root string, process *Process) error {
+	jProcess, err := json.Marshal(process)
+	if err != nil {
+		return err
+	}
+	resp, err := client.ExecutePATCHRequestContext(ctx, root+ProcessPath(process.Name), "application/json", string(jProcess))
+	return expectStatus(resp, err, 204, func() string {
+		return "Failed to update process '" + process.Name + "'."
+	})
+}
+
+// DeleteProcess deletes the process with the specified name from the TM1 server
+func DeleteProcess(ctx context.Context, client *odata.Client, root, name string) error {
+	resp, err := client.ExecuteDELETERequestContext(ctx, root+ProcessPath(name))
+	return expectStatus(resp, err, 204, func() string {
+		return "Failed to delete process '" + name + "'."
+	})
+}
+
+// ProcessExecuteStatus defines the status with which the execution of a process completed
+type ProcessExecuteStatus string
+
+// The statuses with which the execution of a process can complete
+const (
+	ProcessCompletedSuccessfully ProcessExecuteStatus = "CompletedSuccessfully"
+	ProcessAborted               ProcessExecuteStatus = "Aborted"
+	ProcessHasMinorErrors        ProcessExecuteStatus = "HasMinorErrors"
+	ProcessQuitCalled            ProcessExecuteStatus = "QuitCalled"
+	ProcessRollbackCalled        ProcessExecuteStatus = "RollbackCalled"
+)
+
+// ProcessExecuteResult defines the structure of the result of executing a process. If the execution ran into any
+// errors the server logged them to the error log file, the content of which is captured in ErrorLog.
+type ProcessExecuteResult struct {
+	Process                  string `json:"-"`
+	ProcessExecuteStatusCode ProcessExecuteStatus
+	ErrorLogFile             *struct {
+		Filename string
+	}
+	ErrorLog string `json:"-"`
+}
+
+// Err returns a *ProcessError if the process didn't complete successfully, or nil if it did
+func (result *ProcessExecuteResult) Err() error {
+	if result.ProcessExecuteStatusCode == ProcessCompletedSuccessfully {
+		return nil
+	}
+	return &ProcessError{Process: result.Process, Status: result.ProcessExecuteStatusCode, ErrorLog: result.ErrorLog}
+}
+
+// ProcessError is returned when a process was executed but didn't complete successfully
+type ProcessError struct {
+	Process  string
+	Status   ProcessExecuteStatus
+	ErrorLog string
+}
+
+func (e *ProcessError) Error() string {
+	msg := "process '" + e.Process + "' completed with status " + string(e.Status)
+	if e.ErrorLog != "" {
+		msg += ":\n" + e.ErrorLog
+	}
+	return msg
+}
+
+// processExecute defines the structure of the JSON payload used to execute a, named or unnamed, process
+type processExecute struct {
+	Process    *Process           `json:",omitempty"`
+	Parameters []ProcessParameter `json:",omitempty"`
+}
+
+// ExecuteProcess executes the process with the specified name, passing the values of the parameters, which only
+// need a Name and Value, and waits for it to complete. Note that a process that ran, but ran into errors, doesn't
+// result in an error, the result, including the content of the error log file, if any, tells how it went.
+func ExecuteProcess(ctx context.Context, client *odata.Client, root, name string, parameters ...ProcessParameter) (*ProcessExecuteResult, error) {
+	return executeProcess(ctx, client, root, name, ProcessPath(name)+"/tm1.ExecuteWithReturn", &processExecute{Parameters: parameters})
+}
+
+// ExecuteProcessCode executes the, unnamed, process, which doesn't need to exist on the TM1 server, passing the
+// values of the parameters, and waits for it to complete, like ExecuteProcess does
+func ExecuteProcessCode(ctx context.Context, client *odata.Client, root string, process *Process, parameters ...ProcessParameter) (*ProcessExecuteResult, error) {
+	return executeProcess(ctx, client, root, process.Name, "ExecuteProcessWithReturn", &processExecute{Process: process, Parameters: parameters})
+}
+
+// executeProcess invokes the action executing the process and returns its result, with the error log captured
+func executeProcess(ctx context.Context, client *odata.Client, root, name, action string, execute *processExecute) (*ProcessExecuteResult, error) {
+	jExecute, err := json.Marshal(execute)
+	if err != nil {
+		return nil, err
+	}
+	urlStr := root + odata.NewQuery(action).Expand("ErrorLogFile", odata.NewQuery("").Select("Filename")).String()
+	resp, err := client.ExecutePOSTRequestContext(ctx, urlStr, "application/json", string(jExecute))
+	if err != nil {
+		return nil, err
+	}
+	err = odata.ValidateStatusCode(resp, 200, func() string {
+		return "Failed to execute " + action + "."
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &odata.ResponseError{URL: urlStr, Err: err}
+	}
+	result := &ProcessExecuteResult{Process: name}
+	if err = json.Unmarshal(body, result); err != nil {
+		return nil, &odata.ResponseError{URL: urlStr, Err: err}
+	}
+	if result.ErrorLogFile != nil && result.ErrorLogFile.Filename != "" {
+		result.ErrorLog, err = GetErrorLogFile(ctx, client, root, result.ErrorLogFile.Filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// GetErrorLogFile retrieves the content of the error log file, written by a process, with the specified name
+func GetErrorLogFile(ctx context.Context, client *odata.Client, root, filename string) (string, error) {
+	// The content of the error log file is plain text, not JSON, so accept anything
+	resp, err := client.ExecuteGETRequestExContext(ctx, root+ErrorLogFilePath(filename)+"/Content", func(req *http.Request) {
+		req.Header.Set("Accept", "*/*")
+	})
+	if err != nil {
+		return "", err
+	}
+	err = odata.ValidateStatusCode(resp, 200, func() string {
+		return "Failed to retrieve error log file '" + filename + "'."
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", &odata.ResponseError{URL: root + ErrorLogFilePath(filename) + "/Content", Err: err}
+	}
+	return string(content), nil
+}
+
+// SaveDataAll saves the data of all cubes, committing any changes made since the last save to disk, by executing an
+// unnamed process calling the SaveDataAll TurboIntegrator function
+func SaveDataAll(ctx context.Context, client *odata.Client, root string) error {
+	result, err := ExecuteProcessCode(ctx, client, root, &Process{Name: "SaveDataAll", PrologProcedure: "SaveDataAll;"})
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
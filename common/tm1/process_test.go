@@ -0,0 +1,83 @@
+package tm1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+func TestExecuteProcess(t *testing.T) {
+	verbose := odata.Verbose
+	odata.Verbose = false
+	t.Cleanup(func() { odata.Verbose = verbose })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/" + ProcessPath("Load") + "/tm1.ExecuteWithReturn":
+			fmt.Fprint(w, `{"ProcessExecuteStatusCode":"CompletedSuccessfully","ErrorLogFile":null}`)
+		case "/" + ProcessPath("Reload") + "/tm1.ExecuteWithReturn":
+			fmt.Fprint(w, `{"ProcessExecuteStatusCode":"HasMinorErrors","ErrorLogFile":{"Filename":"TM1ProcessError_Reload.log"}}`)
+		case "/" + ErrorLogFilePath("TM1ProcessError_Reload.log") + "/Content":
+			// Like TM1, refuse to return the plain text content as JSON
+			if r.Header.Get("Accept") == "application/json" {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "Data Source line (1) Error: Data procedure line (3): Dimension element \"P-99\" not found")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		process  string
+		status   ProcessExecuteStatus
+		errorLog string
+	}{
+		{"completed successfully", "Load", ProcessCompletedSuccessfully, ""},
+		{"minor errors", "Reload", ProcessHasMinorErrors, "Data Source line (1) Error: Data procedure line (3): Dimension element \"P-99\" not found"},
+	}
+	for _, test := range tests {
+		result, err := ExecuteProcess(context.Background(), &odata.Client{}, server.URL+"/", test.process, ProcessParameter{Name: "pYear", Value: 1997})
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if result.ProcessExecuteStatusCode != test.status || result.ErrorLog != test.errorLog {
+			t.Errorf("%s: got %s with error log %q, want %s with %q", test.name, result.ProcessExecuteStatusCode, result.ErrorLog, test.status, test.errorLog)
+		}
+		var processErr *ProcessError
+		if err = result.Err(); test.status == ProcessCompletedSuccessfully && err != nil {
+			t.Errorf("%s: got %v, want no error", test.name, err)
+		} else if test.status != ProcessCompletedSuccessfully && (!errors.As(err, &processErr) || processErr.Process != test.process) {
+			t.Errorf("%s: got %v, want a process error", test.name, err)
+		}
+	}
+}
+
+func TestProcessEqual(t *testing.T) {
+	process := &Process{Name: "Reload", PrologProcedure: "SaveDataAll;"}
+	tests := []struct {
+		name  string
+		other *Process
+		want  bool
+	}{
+		{"same", &Process{Name: "Reload", PrologProcedure: "SaveDataAll;"}, true},
+		{"name differing in case and spaces", &Process{Name: "RE LOAD", PrologProcedure: "SaveDataAll;"}, true},
+		{"other name", &Process{Name: "Load", PrologProcedure: "SaveDataAll;"}, false},
+		{"other code", &Process{Name: "Reload"}, false},
+	}
+	for _, test := range tests {
+		if got := process.Equal(test.other); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
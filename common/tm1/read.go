@@ -0,0 +1,179 @@
+package tm1
+
+import (
+	"context"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// elementRead defines the structure of a single Element entity as read from the server, including the values of
+// its attributes, in the default culture as well as in every culture the element has been localized for
+type elementRead struct {
+	Name                string
+	Type                ElementType
+	Attributes          map[string]interface{}
+	LocalizedAttributes []struct {
+		LocaleID   string
+		Attributes map[string]interface{}
+	}
+}
+
+// hierarchyRead defines the structure of a single Hierarchy entity as read from the server, with its elements,
+// edges and element attributes expanded
+type hierarchyRead struct {
+	Name              string
+	Elements          []elementRead
+	Edges             []Edge
+	ElementAttributes []ElementAttribute
+}
+
+// dimensionRead defines the structure of a single Dimension entity as read from the server
+type dimensionRead struct {
+	Name        string
+	Hierarchies []hierarchyRead
+}
+
+// cubeRead defines the structure of a single Cube entity as read from the server, with its dimensions expanded
+type cubeRead struct {
+	Name       string
+	Dimensions []struct {
+		Name string
+	}
+	Rules string
+}
+
+// controlObjectFilter returns the filter excluding control objects, the names of which start with a '}', or nil if
+// they shouldn't be excluded
+func controlObjectFilter(controlObjects bool) odata.Expr {
+	if controlObjects == true {
+		return nil
+	}
+	return odata.Not(odata.StartsWith(odata.Prop("Name"), "}"))
+}
+
+// hierarchyQuery returns the nested query options to expand a hierarchy with everything we read of it
+func hierarchyQuery() *odata.Query {
+	return odata.NewQuery("").Select("Name").
+		Expand("Elements", odata.NewQuery("").Select("Name", "Type", "Attributes").
+			Expand("LocalizedAttributes", odata.NewQuery("").Select("LocaleID", "Attributes"))).
+		Expand("Edges", odata.NewQuery("").Select("ParentName", "ComponentName", "Weight")).
+		Expand("ElementAttributes", odata.NewQuery("").Select("Name", "Type"))
+}
+
+// GetDimension retrieves the dimension with the specified name, with all its hierarchies, including their elements,
+// edges, element attributes and attribute values, localized ones included, from the TM1 server
+// Note: if the dimension doesn't exist the returned error satisfies odata.IsNotFound
+func GetDimension(ctx context.Context, client *odata.Client, root, name string) (*Dimension, error) {
+	query := odata.NewQuery(DimensionPath(name)).Select("Name").Expand("Hierarchies", hierarchyQuery())
+	res, err := odata.GetEntity[dimensionRead](ctx, client, root+query.String())
+	if err != nil {
+		return nil, err
+	}
+	dimension := CreateDimension(res.Name)
+	for _, hierarchy := range res.Hierarchies {
+		hierarchy.addTo(dimension)
+	}
+	return dimension, nil
+}
+
+// GetDimensions retrieves all dimensions, optionally including the control dimensions, like GetDimension does
+func GetDimensions(ctx context.Context, client *odata.Client, root string, controlObjects bool) ([]*Dimension, error) {
+	query := odata.NewQuery("Dimensions").Select("Name").Expand("Hierarchies", hierarchyQuery()).OrderBy("Name")
+	if filter := controlObjectFilter(controlObjects); filter != nil {
+		query.Filter(filter)
+	}
+	res, err := odata.GetCollection[dimensionRead](ctx, client, root+query.String())
+	if err != nil {
+		return nil, err
+	}
+	dimensions := make([]*Dimension, len(res.Value))
+	for i, read := range res.Value {
+		dimensions[i] = CreateDimension(read.Name)
+		for _, hierarchy := range read.Hierarchies {
+			hierarchy.addTo(dimensions[i])
+		}
+	}
+	return dimensions, nil
+}
+
+// GetHierarchy retrieves the specified hierarchy, including its elements, edges, element attributes and attribute
+// values, from the TM1 server. The hierarchy is returned as part of a dimension only holding this hierarchy.
+func GetHierarchy(ctx context.Context, client *odata.Client, root, dimensionName, hierarchyName string) (*Hierarchy, error) {
+	// The hierarchy query has no resource, rendering it only renders its query options
+	res, err := odata.GetEntity[hierarchyRead](ctx, client, root+HierarchyPath(dimensionName, hierarchyName)+hierarchyQuery().String())
+	if err != nil {
+		return nil, err
+	}
+	return res.addTo(CreateDimension(dimensionName)), nil
+}
+
+// GetCube retrieves the cube with the specified name, with its dimensions and rules, from the TM1 server
+// Note: if the cube doesn't exist the returned error satisfies odata.IsNotFound
+func GetCube(ctx context.Context, client *odata.Client, root, name string) (*Cube, error) {
+	query := odata.NewQuery(CubePath(name)).Select("Name", "Rules").Expand("Dimensions", odata.NewQuery("").Select("Name"))
+	res, err := odata.GetEntity[cubeRead](ctx, client, root+query.String())
+	if err != nil {
+		return nil, err
+	}
+	return res.cube(), nil
+}
+
+// GetCubes retrieves all cubes, optionally including the control cubes, with their dimensions and rules
+func GetCubes(ctx context.Context, client *odata.Client, root string, controlObjects bool) ([]*Cube, error) {
+	query := odata.NewQuery("Cubes").Select("Name", "Rules").Expand("Dimensions", odata.NewQuery("").Select("Name")).OrderBy("Name")
+	if filter := controlObjectFilter(controlObjects); filter != nil {
+		query.Filter(filter)
+	}
+	res, err := odata.GetCollection[cubeRead](ctx, client, root+query.String())
+	if err != nil {
+		return nil, err
+	}
+	cubes := make([]*Cube, len(res.Value))
+	for i, read := range res.Value {
+		cubes[i] = read.cube()
+	}
+	return cubes, nil
+}
+
+// cube returns the cube, as read from the server, as a Cube
+func (read *cubeRead) cube() *Cube {
+	cube := &Cube{Name: read.Name, Rules: read.Rules, Dimensions: make([]string, len(read.Dimensions))}
+	for i, dimension := range read.Dimensions {
+		cube.Dimensions[i] = dimension.Name
+	}
+	return cube
+}
+
+// addTo adds the hierarchy, as read from the server, to the dimension
+func (read *hierarchyRead) addTo(dimension *Dimension) *Hierarchy {
+	hierarchy := dimension.AddHierarchy(read.Name)
+
+	// AddHierarchy adds the Caption attribute by default, which the hierarchy might not have on the server
+	hierarchy.Attributes = append([]ElementAttribute{}, read.ElementAttributes...)
+	for _, element := range read.Elements {
+		hierarchy.Elements = append(hierarchy.Elements, Element{Name: element.Name, Type: element.Type})
+		for _, attribute := range hierarchy.Attributes {
+			// Attributes without value are returned as null, empty or 0, or not at all, and simply skipped
+			switch value := element.Attributes[attribute.Name].(type) {
+			case string:
+				if value != "" {
+					hierarchy.SetStringAttribute(element.Name, attribute.Name, value)
+				}
+			case float64:
+				if value != 0 {
+					hierarchy.SetNumericAttribute(element.Name, attribute.Name, value)
+				}
+			}
+		}
+		// Only string and alias attributes can be localized, SetLocalizedAttribute skips any other one
+		for _, localized := range element.LocalizedAttributes {
+			for attribute, value := range localized.Attributes {
+				if value, ok := value.(string); ok && value != "" {
+					hierarchy.SetLocalizedAttribute(element.Name, localized.LocaleID, attribute, value)
+				}
+			}
+		}
+	}
+	hierarchy.Edges = read.Edges
+	return hierarchy
+}
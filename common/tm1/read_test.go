@@ -0,0 +1,108 @@
+package tm1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// readServer returns a test server responding to every request with the response registered for its path, or with a
+// 404 if there is none, and the list the requested URLs get recorded in
+func readServer(t *testing.T, responses map[string]string) (*httptest.Server, *[]string) {
+	verbose := odata.Verbose
+	odata.Verbose = false
+	t.Cleanup(func() { odata.Verbose = verbose })
+
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.RequestURI())
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"code":"","message":"Not found"}}`)
+			return
+		}
+		fmt.Fprint(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requested
+}
+
+func TestGetDimension(t *testing.T) {
+	server, _ := readServer(t, map[string]string{
+		"/Dimensions('Products')": `{"Name":"Products","Hierarchies":[{"Name":"Products",` +
+			`"Elements":[` +
+			`{"Name":"Total","Type":"Consolidated","Attributes":{"Caption":"All Products","Price":0}},` +
+			`{"Name":"Chai","Type":"Numeric","Attributes":{"Caption":"Chai","Price":18},"LocalizedAttributes":[{"LocaleID":"fr","Attributes":{"Caption":"Thé","Price":null}}]},` +
+			`{"Name":"Chang","Type":"Numeric","Attributes":{"Caption":null}}],` +
+			`"Edges":[{"ParentName":"Total","ComponentName":"Chai","Weight":1},{"ParentName":"Total","ComponentName":"Chang","Weight":-1}],` +
+			`"ElementAttributes":[{"Name":"Caption","Type":"String"},{"Name":"Price","Type":"Numeric"}]}]}`,
+	})
+
+	want := CreateDimension("Products")
+	hierarchy := want.AddHierarchy("Products")
+	hierarchy.AddAttribute("Price", AttributeNumeric)
+	hierarchy.AddTypedElement("Total", "All Products", ElementConsolidated)
+	hierarchy.AddTypedElement("Chai", "Chai", ElementNumeric)
+	hierarchy.AddTypedElement("Chang", "", ElementNumeric)
+	hierarchy.AddEdge("Total", "Chai")
+	hierarchy.AddWeightedEdge("Total", "Chang", -1)
+	hierarchy.SetNumericAttribute("Chai", "Price", 18)
+	hierarchy.SetLocalizedCaption("Chai", "fr", "Thé")
+
+	got, err := GetDimension(context.Background(), &odata.Client{}, server.URL+"/", "Products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got.Hierarchies[0], want.Hierarchies[0])
+	}
+
+	if _, err := GetDimension(context.Background(), &odata.Client{}, server.URL+"/", "Suppliers"); !odata.IsNotFound(err) {
+		t.Errorf("missing dimension: got %v, want a not found error", err)
+	}
+}
+
+func TestGetCubes(t *testing.T) {
+	server, requested := readServer(t, map[string]string{
+		"/Cubes('Sales')": `{"Name":"Sales","Rules":"SKIPCHECK;","Dimensions":[{"Name":"Products"},{"Name":"Measures"}]}`,
+		"/Cubes":          `{"value":[{"Name":"Sales","Dimensions":[{"Name":"Products"},{"Name":"Measures"}]}]}`,
+	})
+	client := &odata.Client{}
+
+	cube, err := GetCube(context.Background(), client, server.URL+"/", "Sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&Cube{Name: "Sales", Dimensions: []string{"Products", "Measures"}, Rules: "SKIPCHECK;"}); !reflect.DeepEqual(cube, want) {
+		t.Errorf("got %+v, want %+v", cube, want)
+	}
+
+	tests := []struct {
+		name           string
+		controlObjects bool
+		want           string
+	}{
+		{"without control cubes", false, "/Cubes?$filter=not%20startswith(Name,'%7D')&$select=Name,Rules&$expand=Dimensions($select=Name)&$orderby=Name"},
+		{"with control cubes", true, "/Cubes?$select=Name,Rules&$expand=Dimensions($select=Name)&$orderby=Name"},
+	}
+	for _, test := range tests {
+		*requested = nil
+		cubes, err := GetCubes(context.Background(), client, server.URL+"/", test.controlObjects)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(cubes) != 1 || cubes[0].Name != "Sales" || len(cubes[0].Dimensions) != 2 {
+			t.Errorf("%s: got %+v, want the Sales cube", test.name, cubes)
+		}
+		if len(*requested) != 1 || (*requested)[0] != test.want {
+			t.Errorf("%s: got requests %q, want %q", test.name, *requested, test.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// Subset defines the structure of a single, named, subset of a hierarchy. A subset either is a static subset,
+// defined by its list of elements, or a dynamic subset, defined by an MDX expression which the TM1 server evaluates
+// every time the subset is used.
+type Subset struct {
+	Name       string
+	Dimension  string
+	Hierarchy  string
+	Elements   []string
+	Expression string
+}
+
+// CreateStaticSubset creates a new static subset, with the specified name, of the elements in the hierarchy
+func CreateStaticSubset(name, dimension, hierarchy string, elements ...string) *Subset {
+	return &Subset{Name: name, Dimension: dimension, Hierarchy: hierarchy, Elements: elements}
+}
+
+// CreateDynamicSubset creates a new dynamic subset, with the specified name, of the hierarchy, the elements of
+// which are the result of the MDX set expression
+func CreateDynamicSubset(name, dimension, hierarchy, expression string) *Subset {
+	return &Subset{Name: name, Dimension: dimension, Hierarchy: hierarchy, Expression: expression}
+}
+
+// IsDynamic reports whether the subset is a dynamic subset
+func (subset *Subset) IsDynamic() bool {
+	return subset.Expression != ""
+}
+
+// Equal reports whether both subsets have the same definition, the names of the subsets, hierarchies and elements,
+// like in TM1, being compared ignoring case and spaces
+func (subset *Subset) Equal(other *Subset) bool {
+	return nameKey(subset.Name) == nameKey(other.Name) &&
+		nameKey(subset.Dimension) == nameKey(other.Dimension) &&
+		nameKey(subset.Hierarchy) == nameKey(other.Hierarchy) &&
+		subset.Expression == other.Expression &&
+		sameNames(subset.Elements, other.Elements)
+}
+
+// subsetPost defines the structure of the JSON payload for a subset, either defined by its elements or an MDX
+// expression. Subsets used by a single view only are unnamed.
+type subsetPost struct {
+	Name          string   `json:",omitempty"`
+	HierarchyBind string   `json:"Hierarchy@odata.bind"`
+	Elements      []string `json:"Elements@odata.bind,omitempty"`
+	Expression    string   `json:",omitempty"`
+}
+
+// subsetPatch defines the structure of the JSON payload used to update a subset. Unlike when creating a subset, the
+// expression is always part of it, null for a static subset, as otherwise a dynamic subset that is turned into a
+// static one would keep its expression and, with that, remain dynamic.
+type subsetPatch struct {
+	*subsetPost
+	Expression *string
+}
+
+// post returns the subset in the structure of the JSON payload used to create, or update, it
+func (subset *Subset) post() *subsetPost {
+	post := &subsetPost{Name: subset.Name, HierarchyBind: HierarchyPath(subset.Dimension, subset.Hierarchy), Expression: subset.Expression}
+	if subset.IsDynamic() == false {
+		for _, element := range subset.Elements {
+			post.Elements = append(post.Elements, ElementPath(subset.Dimension, subset.Hierarchy, element))
+		}
+	}
+	return post
+}
+
+// subsetRead defines the structure of a single Subset entity as read from the server, with its hierarchy and
+// elements expanded
+type subsetRead struct {
+	Name       string
+	Expression string
+	Hierarchy  struct {
+		Name      string
+		Dimension struct {
+			Name string
+		}
+	}
+	Elements []struct {
+		Name string
+	}
+}
+
+// subsetQuery returns the query options to expand a subset, or collection of subsets, with everything we read of it
+func subsetQuery(resource string) *odata.Query {
+	return odata.NewQuery(resource).Select("Name", "Expression").
+		Expand("Hierarchy", odata.NewQuery("").Select("Name").Expand("Dimension", odata.NewQuery("").Select("Name"))).
+		Expand("Elements", odata.NewQuery("").Select("Name"))
+}
+
+// subset returns the subset, as read from the server, as a Subset
+func (read *subsetRead) subset() *Subset {
+	subset := &Subset{Name: read.Name, Dimension: read.Hierarchy.Dimension.Name, Hierarchy: read.Hierarchy.Name, Expression: read.Expression}
+	// The elements of a dynamic subset are the result of its expression, not part of its definition
+	if subset.IsDynamic() == false {
+		for _, element := range read.Elements {
+			subset.Elements = append(subset.Elements, element.Name)
+		}
+	}
+	return subset
+}
+
+// GetSubset retrieves the, public or private, subset with the specified name of the hierarchy from the TM1 server
+// Note: if the subset doesn't exist the returned error satisfies odata.IsNotFound
+func GetSubset(ctx context.Context, client *odata.Client, root, dimension, hierarchy, name string, private bool) (*Subset, error) {
+	res, err := odata.GetEntity[subsetRead](ctx, client, root+subsetQuery(SubsetPath(dimension, hierarchy, name, private)).String())
+	if err != nil {
+		return nil, err
+	}
+	return res.subset(), nil
+}
+
+// GetSubsets retrieves all, public or private, subsets of the hierarchy from the TM1 server
+func GetSubsets(ctx context.Context, client *odata.Client, root, dimension, hierarchy string, private bool) ([]*Subset, error) {
+	res, err := odata.GetCollection[subsetRead](ctx, client, root+subsetQuery(SubsetsPath(dimension, hierarchy, private)).OrderBy("Name").String())
+	if err != nil {
+		return nil, err
+	}
+	subsets := make([]*Subset, len(res.Value))
+	for i, read := range res.Value {
+		subsets[i] = read.subset()
+	}
+	return subsets, nil
+}
+
+// GetSubsetElements retrieves the names of the elements, in order, in the, public or private, subset. For a dynamic
+// subset these are the elements its expression currently evaluates to.
+func GetSubsetElements(ctx context.Context, client *odata.Client, root, dimension, hierarchy, name string, private bool) ([]string, error) {
+	res, err := odata.GetCollection[Element](ctx, client, root+odata.NewQuery(SubsetPath(dimension, hierarchy, name, private)+"/Elements").Select("Name").String())
+	if err != nil {
+		return nil, err
+	}
+	elements := make([]string, len(res.Value))
+	for i, element := range res.Value {
+		elements[i] = element.Name
+	}
+	return elements, nil
+}
+
+// PostSubset creates the, public or private, subset on the TM1 server
+func PostSubset(ctx context.Context, client *odata.Client, root string, subset *Subset, private bool) error {
+	jSubset, err := json.Marshal(subset.post())
+	if err != nil {
+		return err
+	}
+	resp, err := client.ExecutePOSTRequestContext(ctx, root+SubsetsPath(subset.Dimension, subset.Hierarchy, private), "application/json", string(jSubset))
+	return expectStatus(resp, err, 201, func() string {
+		return "Failed to create subset '" + subset.Name + "' of hierarchy '" + subset.Hierarchy + "' in dimension '" + subset.Dimension + "'."
+	})
+}
+
+// UpdateSubset replaces the definition of the, existing, public or private, subset on the TM1 server
+func UpdateSubset(ctx context.Context, client *odata.Client, root string, subset *Subset, private bool) error {
+	patch := &subsetPatch{subsetPost: subset.post()}
+	if subset.IsDynamic() == true {
+		patch.Expression = &subset.Expression
+	}
+	jSubset, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	// Binding elements to a static subset adds them to the elements the subset already has, so, in one change set,
+	// first remove the references to the elements the subset has now, one by one, and only then update the subset,
+	// binding the new elements. The elements of a dynamic subset aren't part of its definition, nothing to remove.
+	batch := &odata.Batch{}
+	changeSet := batch.SequentialChangeSet()
+	path := SubsetPath(subset.Dimension, subset.Hierarchy, subset.Name, private)
+	if subset.IsDynamic() == false {
+		current, err := GetSubset(ctx, client, root, subset.Dimension, subset.Hierarchy, subset.Name, private)
+		if err != nil {
+			return err
+		}
+		for _, element := range current.Elements {
+			changeSet.Add("DELETE", path+"/Elements"+odata.StringKey(element)+"/$ref", "")
+		}
+	}
+	changeSet.Add("PATCH", path, string(jSubset))
+	result, err := client.ExecuteBatch(ctx, root, batch)
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// DeleteSubset deletes the, public or private, subset with the specified name of the hierarchy from the TM1 server
+func DeleteSubset(ctx context.Context, client *odata.Client, root, dimension, hierarchy, name string, private bool) error {
+	resp, err := client.ExecuteDELETERequestContext(ctx, root+SubsetPath(dimension, hierarchy, name, private))
+	return expectStatus(resp, err, 204, func() string {
+		return "Failed to delete subset '" + name + "' of hierarchy '" + hierarchy + "' in dimension '" + dimension + "'."
+	})
+}
@@ -0,0 +1,105 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+func TestSubsetPayloads(t *testing.T) {
+	tests := []struct {
+		name      string
+		subset    *Subset
+		wantPost  string
+		wantPatch string
+	}{
+		{
+			name:      "static",
+			subset:    CreateStaticSubset("Beverages", "Products", "Products", "Chai", "Chang"),
+			wantPost:  `{"Name":"Beverages","Hierarchy@odata.bind":"Dimensions('Products')/Hierarchies('Products')","Elements@odata.bind":["Dimensions('Products')/Hierarchies('Products')/Elements('Chai')","Dimensions('Products')/Hierarchies('Products')/Elements('Chang')"]}`,
+			wantPatch: `{"Name":"Beverages","Hierarchy@odata.bind":"Dimensions('Products')/Hierarchies('Products')","Elements@odata.bind":["Dimensions('Products')/Hierarchies('Products')/Elements('Chai')","Dimensions('Products')/Hierarchies('Products')/Elements('Chang')"],"Expression":null}`,
+		},
+		{
+			name:      "dynamic",
+			subset:    CreateDynamicSubset("Years", "Time", "Years", "{[Time].[Years].[Years].Children}"),
+			wantPost:  `{"Name":"Years","Hierarchy@odata.bind":"Dimensions('Time')/Hierarchies('Years')","Expression":"{[Time].[Years].[Years].Children}"}`,
+			wantPatch: `{"Name":"Years","Hierarchy@odata.bind":"Dimensions('Time')/Hierarchies('Years')","Expression":"{[Time].[Years].[Years].Children}"}`,
+		},
+	}
+	for _, test := range tests {
+		jPost, err := json.Marshal(test.subset.post())
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if string(jPost) != test.wantPost {
+			t.Errorf("%s: got post\n%s\nwant\n%s", test.name, jPost, test.wantPost)
+		}
+		patch := &subsetPatch{subsetPost: test.subset.post()}
+		if test.subset.IsDynamic() {
+			patch.Expression = &test.subset.Expression
+		}
+		jPatch, err := json.Marshal(patch)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if string(jPatch) != test.wantPatch {
+			t.Errorf("%s: got patch\n%s\nwant\n%s", test.name, jPatch, test.wantPatch)
+		}
+	}
+}
+
+func TestUpdateSubset(t *testing.T) {
+	verbose := odata.Verbose
+	odata.Verbose = false
+	t.Cleanup(func() { odata.Verbose = verbose })
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, `{"Name":"Beverages","Expression":"","Hierarchy":{"Name":"Products","Dimension":{"Name":"Products"}},"Elements":[{"Name":"Chai"},{"Name":"Chang"}]}`)
+			return
+		}
+		var batch odata.Batch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		result := odata.BatchResult{}
+		for _, req := range batch.Requests {
+			requests = append(requests, req.Method+" "+req.URL)
+			result.Responses = append(result.Responses, &odata.BatchResponse{ID: req.ID, Status: http.StatusNoContent})
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	path := SubsetPath("Products", "Products", "Beverages", false)
+	tests := []struct {
+		name   string
+		subset *Subset
+		want   []string
+	}{
+		{
+			name:   "static",
+			subset: CreateStaticSubset("Beverages", "Products", "Products", "Chai", "Ipoh Coffee"),
+			want:   []string{"DELETE " + path + "/Elements('Chai')/$ref", "DELETE " + path + "/Elements('Chang')/$ref", "PATCH " + path},
+		},
+		{
+			name:   "dynamic",
+			subset: CreateDynamicSubset("Beverages", "Products", "Products", "{[Products].[Products].[Beverages].Children}"),
+			want:   []string{"PATCH " + path},
+		},
+	}
+	for _, test := range tests {
+		requests = nil
+		if err := UpdateSubset(context.Background(), &odata.Client{}, server.URL+"/", test.subset, false); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !reflect.DeepEqual(requests, test.want) {
+			t.Errorf("%s: got requests %q, want %q", test.name, requests, test.want)
+		}
+	}
+}
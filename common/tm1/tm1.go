@@ -1,8 +1,11 @@
 package tm1
 
 import (
-	"bytes"
 	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
 )
 
 // Dimension defines the structure of a single Dimension entity in the TM1 Server schema
@@ -12,20 +15,63 @@ type Dimension struct {
 }
 
 // Hierarchy defines the structure of a single Hierarchy entity in the TM1 Server schema
+// Note: the element attributes, and their values, aren't part of the Hierarchy entity (yet) and are created, and
+// loaded, separately, hence they don't get marshalled.
 type Hierarchy struct {
-	Name     string
-	Elements []Element
-	Edges    []Edge            `json:",omitempty"`
-	Captions map[string]string `json:"-"`
+	Name            string
+	Elements        []Element
+	Edges           []Edge                                  `json:",omitempty"`
+	Attributes      []ElementAttribute                      `json:"-"`
+	AttributeValues map[string]map[string]interface{}       `json:"-"`
+	LocalizedValues map[string]map[string]map[string]string `json:"-"`
+}
+
+// AttributeType defines the type of an element attribute
+type AttributeType string
+
+// The types of element attributes
+const (
+	AttributeString  AttributeType = "String"
+	AttributeNumeric AttributeType = "Numeric"
+	AttributeAlias   AttributeType = "Alias"
+)
+
+// ElementAttribute defines the structure of a single ElementAttribute entity in the TM1 Server schema
+type ElementAttribute struct {
+	Name string
+	Type AttributeType
 }
 
+// LocalizedAttributes defines the structure of a single LocalizedAttributes entity in the TM1 Server schema, holding
+// the values of the attributes of an element in the culture, as defined in the }Cultures dimension, with the LocaleID
+type LocalizedAttributes struct {
+	LocaleID   string
+	Attributes map[string]string
+}
+
+// CaptionAttribute is the name of the string attribute, created in every hierarchy, holding the captions of the elements
+const CaptionAttribute = "Caption"
+
 // Element defines the structure of a single Element entity in the TM1 Server schema
 // Note: We use this struct for both regular element definitions, for which we only specify
-// the elemnets Name, as well as for element entity references, represented by @odata.id
+// the elemnets Name, and optionally its Type, as well as for element entity references,
+// represented by @odata.id. If no Type is specified the TM1 server derives it, making
+// elements with components consolidated and all others numeric.
 type Element struct {
 	Name string
+	Type ElementType `json:",omitempty"`
 }
 
+// ElementType defines the type of an element
+type ElementType string
+
+// The types of elements
+const (
+	ElementNumeric      ElementType = "Numeric"
+	ElementString       ElementType = "String"
+	ElementConsolidated ElementType = "Consolidated"
+)
+
 // Edge defines the structure of a single Edge entity in the TM1 Server schema
 type Edge struct {
 	ParentName    string
@@ -42,57 +88,189 @@ func CreateDimension(name string) *Dimension {
 func (dimension *Dimension) AddHierarchy(name string) *Hierarchy {
 	dimension.Hierarchies = append(dimension.Hierarchies, &Hierarchy{Name: name})
 	hierarchy := dimension.Hierarchies[len(dimension.Hierarchies)-1]
-	hierarchy.Captions = make(map[string]string)
+	hierarchy.AttributeValues = make(map[string]map[string]interface{})
+	hierarchy.LocalizedValues = make(map[string]map[string]map[string]string)
+	hierarchy.AddAttribute(CaptionAttribute, AttributeString)
 	return hierarchy
 }
 
-// GetAttributesJSON returns the JSON specification to be passed to the Update action to update the attributes cube associated to the dimension
-func (dimension *Dimension) GetAttributesJSON() string {
-	return dimension.Hierarchies[0].GetAttributesJSON(dimension.Name)
+// AttributesWriter returns the CellWriter holding the updates setting the attribute values of the elements in the attributes cube associated to the dimension
+// Note: the attributes of a dimension are defined on, and loaded from, its first hierarchy
+func (dimension *Dimension) AttributesWriter() *CellWriter {
+	return dimension.Hierarchies[0].AttributesWriter(dimension.Name)
 }
 
 // AddElement creates a new Element in the specified Hierarchy, with the specified name, and returns the element
 func (hierarchy *Hierarchy) AddElement(name, caption string) *Element {
-	hierarchy.Elements = append(hierarchy.Elements, Element{Name: name})
+	return hierarchy.AddTypedElement(name, caption, "")
+}
+
+// AddTypedElement creates a new Element, of the specified type, in the specified Hierarchy, with the specified name, and returns the element
+func (hierarchy *Hierarchy) AddTypedElement(name, caption string, elementType ElementType) *Element {
+	hierarchy.Elements = append(hierarchy.Elements, Element{Name: name, Type: elementType})
 	if caption != "" {
-		hierarchy.Captions[name] = caption
+		hierarchy.SetStringAttribute(name, CaptionAttribute, caption)
 	}
 	return &hierarchy.Elements[len(hierarchy.Elements)-1]
 }
 
 // AddEdge creates a new Edge in the specified Hierarchy, linking the specified parent and component, and returns the edge
 func (hierarchy *Hierarchy) AddEdge(parent string, component string) *Edge {
-	hierarchy.Edges = append(hierarchy.Edges, Edge{ParentName: parent, ComponentName: component, Weight: 1.0})
+	return hierarchy.AddWeightedEdge(parent, component, 1.0)
+}
+
+// AddWeightedEdge creates a new Edge in the specified Hierarchy, linking the specified parent and component with the specified weight, and returns the edge
+// Note: a negative weight subtracts the component from its parent, e.g. to consolidate Net Revenue as Revenue - Discounts
+func (hierarchy *Hierarchy) AddWeightedEdge(parent string, component string, weight float64) *Edge {
+	hierarchy.Edges = append(hierarchy.Edges, Edge{ParentName: parent, ComponentName: component, Weight: weight})
 	return &hierarchy.Edges[len(hierarchy.Edges)-1]
 }
 
-// GetAttributesJSON returns the JSON specification to be passed to the Update action to update the attributes cube associated to the dimension
-func (hierarchy *Hierarchy) GetAttributesJSON(dimensionName string) string {
-	var jAttributes bytes.Buffer
-	var bFirst = true
-	jAttributes.WriteString("[")
-	for element, caption := range hierarchy.Captions {
-		if bFirst == true {
-			bFirst = false
-		} else {
-			jAttributes.WriteString(",")
+// AddAttribute creates a new ElementAttribute in the specified Hierarchy, with the specified name and type, and returns the attribute
+// Note: if the hierarchy already has an attribute with the specified name, that attribute is returned instead
+func (hierarchy *Hierarchy) AddAttribute(name string, attributeType AttributeType) *ElementAttribute {
+	if attribute := hierarchy.Attribute(name); attribute != nil {
+		return attribute
+	}
+	hierarchy.Attributes = append(hierarchy.Attributes, ElementAttribute{Name: name, Type: attributeType})
+	return &hierarchy.Attributes[len(hierarchy.Attributes)-1]
+}
+
+// Attribute returns the attribute with the specified name, or nil if the hierarchy doesn't have such attribute
+func (hierarchy *Hierarchy) Attribute(name string) *ElementAttribute {
+	for i := range hierarchy.Attributes {
+		if hierarchy.Attributes[i].Name == name {
+			return &hierarchy.Attributes[i]
+		}
+	}
+	return nil
+}
+
+// SetStringAttribute sets the value of the specified String or Alias attribute for the specified element
+func (hierarchy *Hierarchy) SetStringAttribute(element, attribute, value string) error {
+	return hierarchy.setAttribute(element, attribute, value, AttributeString, AttributeAlias)
+}
+
+// SetNumericAttribute sets the value of the specified Numeric attribute for the specified element
+func (hierarchy *Hierarchy) SetNumericAttribute(element, attribute string, value float64) error {
+	return hierarchy.setAttribute(element, attribute, value, AttributeNumeric)
+}
+
+// SetLocalizedAttribute sets the value of the specified String or Alias attribute for the specified element in the specified culture
+func (hierarchy *Hierarchy) SetLocalizedAttribute(element, culture, attribute, value string) error {
+	definition := hierarchy.Attribute(attribute)
+	if definition == nil {
+		return fmt.Errorf("hierarchy '%s' has no attribute '%s'", hierarchy.Name, attribute)
+	}
+	if definition.Type == AttributeNumeric {
+		return fmt.Errorf("attribute '%s' of hierarchy '%s' is of type %s and can't be localized", attribute, hierarchy.Name, definition.Type)
+	}
+	if hierarchy.LocalizedValues[element] == nil {
+		hierarchy.LocalizedValues[element] = make(map[string]map[string]string)
+	}
+	if hierarchy.LocalizedValues[element][culture] == nil {
+		hierarchy.LocalizedValues[element][culture] = make(map[string]string)
+	}
+	hierarchy.LocalizedValues[element][culture][attribute] = value
+	return nil
+}
+
+// SetLocalizedCaption sets the caption of the specified element in the specified culture
+func (hierarchy *Hierarchy) SetLocalizedCaption(element, culture, caption string) error {
+	return hierarchy.SetLocalizedAttribute(element, culture, CaptionAttribute, caption)
+}
+
+// LocalizedElements returns the names, sorted, of the elements that have localized attribute values
+func (hierarchy *Hierarchy) LocalizedElements() []string {
+	elements := make([]string, 0, len(hierarchy.LocalizedValues))
+	for element := range hierarchy.LocalizedValues {
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+	return elements
+}
+
+// LocalizedAttributes returns, ordered by culture, the localized attribute values of the specified element
+func (hierarchy *Hierarchy) LocalizedAttributes(element string) []LocalizedAttributes {
+	cultures := make([]string, 0, len(hierarchy.LocalizedValues[element]))
+	for culture := range hierarchy.LocalizedValues[element] {
+		cultures = append(cultures, culture)
+	}
+	sort.Strings(cultures)
+
+	localized := make([]LocalizedAttributes, len(cultures))
+	for i, culture := range cultures {
+		localized[i] = LocalizedAttributes{LocaleID: culture, Attributes: hierarchy.LocalizedValues[element][culture]}
+	}
+	return localized
+}
+
+func (hierarchy *Hierarchy) setAttribute(element, attribute string, value interface{}, types ...AttributeType) error {
+	definition := hierarchy.Attribute(attribute)
+	if definition == nil {
+		return fmt.Errorf("hierarchy '%s' has no attribute '%s'", hierarchy.Name, attribute)
+	}
+	for _, attributeType := range types {
+		if definition.Type == attributeType {
+			if hierarchy.AttributeValues[element] == nil {
+				hierarchy.AttributeValues[element] = make(map[string]interface{})
+			}
+			hierarchy.AttributeValues[element][attribute] = value
+			return nil
+		}
+	}
+	return fmt.Errorf("attribute '%s' of hierarchy '%s' is of type %s, can't set it to %v", attribute, hierarchy.Name, definition.Type, value)
+}
+
+// AttributesWriter returns the CellWriter holding the updates, ordered by element name, setting the attribute values of the elements in the attributes cube associated to the dimension
+func (hierarchy *Hierarchy) AttributesWriter(dimensionName string) *CellWriter {
+	elements := make([]string, 0, len(hierarchy.AttributeValues))
+	for element := range hierarchy.AttributeValues {
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+
+	attributesCube := AttributesCubeName(dimensionName)
+	writer := &CellWriter{Cube: attributesCube, Hierarchies: []HierarchyRef{{dimensionName, hierarchy.Name}, {attributesCube, attributesCube}}}
+	for _, element := range elements {
+		// Write the values in the order in which the attributes were defined
+		// Note: every tuple has two elements, matching the two dimensions of the attributes cube, hence no errors
+		for _, attribute := range hierarchy.Attributes {
+			switch value := hierarchy.AttributeValues[element][attribute.Name].(type) {
+			case float64:
+				writer.WriteNumber(value, element, attribute.Name)
+			case string:
+				writer.WriteString(value, element, attribute.Name)
+			}
 		}
-		jAttributes.WriteString(`{"Slice@odata.bind":["Dimensions('`)
-		jAttributes.WriteString(dimensionName)
-		jAttributes.WriteString(`')/Hierarchies('`)
-		jAttributes.WriteString(hierarchy.Name)
-		jAttributes.WriteString(`')/Elements('`)
-		jAttributes.WriteString(element)
-		jAttributes.WriteString(`')","Dimensions('}ElementAttributes_`)
-		jAttributes.WriteString(dimensionName)
-		jAttributes.WriteString(`')/Hierarchies('}ElementAttributes_`)
-		jAttributes.WriteString(dimensionName)
-		jAttributes.WriteString(`')/Elements('Caption')"],"Value":"`)
-		jAttributes.WriteString(caption)
-		jAttributes.WriteString(`"}`)
 	}
-	jAttributes.WriteString("]")
-	return jAttributes.String()
+	return writer
+}
+
+// CellUpdate defines the structure of a single cell update as passed to the Update action of a cube, in which Slice
+// holds the paths of the elements, one for every dimension of the cube, identifying the cell to be updated
+// Note: the value is either a number, a string or a spreading expression, e.g. "+5" which adds 5 to the current
+// value of a numeric cell
+type CellUpdate struct {
+	Slice []string `json:"Slice@odata.bind"`
+	Value interface{}
+}
+
+// Cube defines the structure of a single Cube entity in the TM1 Server schema, in which the dimensions, in order,
+// are referred to by name
+type Cube struct {
+	Name       string
+	Dimensions []string
+	Rules      string `json:",omitempty"`
+}
+
+// Post returns the structure, with the JSON annotations, for POSTing (read: creating) the cube
+func (cube *Cube) Post() CubePost {
+	dimensionIds := make([]string, len(cube.Dimensions))
+	for i, dimension := range cube.Dimensions {
+		dimensionIds[i] = DimensionPath(dimension)
+	}
+	return CubePost{Name: cube.Name, DimensionIds: dimensionIds, Rules: cube.Rules}
 }
 
 // CubePost defines the structure of a single Cube entity with the JSON annotations for POSTing (read: creating) one
@@ -105,8 +283,8 @@ type CubePost struct {
 // TransactionLogEntry defines the structure of A single TransactionLogEntry entity
 type TransactionLogEntry struct {
 	ChangeSetID     string
-	TimeStamp       string // should be time.Time but because the seconds are omitted if 0 Go's time parser doesn't like them.
-	ReplicationTime string // should be time.Time but because the seconds are omitted if 0 Go's time parser doesn't like them.
+	TimeStamp       odata.DateTimeOffset
+	ReplicationTime odata.DateTimeOffset
 	User            string
 	Cube            string
 	Tuple           []string
@@ -114,12 +292,3 @@ type TransactionLogEntry struct {
 	NewValue        json.RawMessage
 	StatusMessage   string
 }
-
-// TransactionLogEntriesResponse defines the structure of an odata compliant response wrapping a TransactionLogEntry collection
-type TransactionLogEntriesResponse struct {
-	Context               string                `json:"@odata.context"`
-	Count                 int                   `json:"@odata.count"`
-	TransactionLogEntries []TransactionLogEntry `json:"value"`
-	NextLink              string                `json:"@odata.nextLink"`
-	DeltaLink             string                `json:"@odata.deltaLink"`
-}
@@ -0,0 +1,162 @@
+package tm1
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCellUpdateJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		update CellUpdate
+		want   string
+	}{
+		{
+			name:   "number",
+			update: CellUpdate{Slice: []string{ElementPath("Products", "Products", "Chai"), ElementPath("Measures", "Measures", "Revenue")}, Value: 10.5},
+			want:   `{"Slice@odata.bind":["Dimensions('Products')/Hierarchies('Products')/Elements('Chai')","Dimensions('Measures')/Hierarchies('Measures')/Elements('Revenue')"],"Value":10.5}`,
+		},
+		{
+			name:   "string with quotes and backslash",
+			update: CellUpdate{Slice: []string{ElementPath("Customers", "Customers", `Bon app'`)}, Value: `say "hi" \ bye`},
+			want:   `{"Slice@odata.bind":["Dimensions('Customers')/Hierarchies('Customers')/Elements('Bon%20app''')"],"Value":"say \"hi\" \\ bye"}`,
+		},
+		{
+			name:   "spreading expression",
+			update: CellUpdate{Slice: []string{ElementPath("Measures", "Measures", "Quantity")}, Value: "+5"},
+			want:   `{"Slice@odata.bind":["Dimensions('Measures')/Hierarchies('Measures')/Elements('Quantity')"],"Value":"+5"}`,
+		},
+	}
+	for _, test := range tests {
+		jUpdate, err := json.Marshal(test.update)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if string(jUpdate) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, jUpdate, test.want)
+		}
+	}
+}
+
+func TestHierarchyAttributes(t *testing.T) {
+	hierarchy := CreateDimension("Products").AddHierarchy("Products")
+	hierarchy.AddAttribute("Price", AttributeNumeric)
+	hierarchy.AddAttribute("Code", AttributeAlias)
+	if attribute := hierarchy.AddAttribute("Price", AttributeString); attribute.Type != AttributeNumeric || len(hierarchy.Attributes) != 3 {
+		t.Errorf("re-adding an attribute: got %+v and %d attributes, want the existing numeric attribute and 3", attribute, len(hierarchy.Attributes))
+	}
+
+	tests := []struct {
+		name  string
+		set   func() error
+		valid bool
+	}{
+		{"caption", func() error { return hierarchy.SetStringAttribute("P-1", CaptionAttribute, "Chai") }, true},
+		{"alias", func() error { return hierarchy.SetStringAttribute("P-1", "Code", "CHAI") }, true},
+		{"numeric", func() error { return hierarchy.SetNumericAttribute("P-1", "Price", 18) }, true},
+		{"string value for numeric attribute", func() error { return hierarchy.SetStringAttribute("P-1", "Price", "18") }, false},
+		{"numeric value for string attribute", func() error { return hierarchy.SetNumericAttribute("P-1", CaptionAttribute, 1) }, false},
+		{"unknown attribute", func() error { return hierarchy.SetStringAttribute("P-1", "Color", "Red") }, false},
+	}
+	for _, test := range tests {
+		err := test.set()
+		if test.valid && err != nil {
+			t.Errorf("%s: got %v, want no error", test.name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: got no error, want one", test.name)
+		}
+	}
+	if got := hierarchy.AttributeValues["P-1"]; len(got) != 3 || got["Price"] != 18.0 || got["Code"] != "CHAI" {
+		t.Errorf("got attribute values %v, want Caption, Code and Price", got)
+	}
+}
+
+func TestAttributesWriter(t *testing.T) {
+	dimension := CreateDimension("Products")
+	hierarchy := dimension.AddHierarchy("Products")
+	hierarchy.AddAttribute("Price", AttributeNumeric)
+	hierarchy.AddElement("P-2", "Chang")
+	hierarchy.AddElement("P-1", "Chai")
+	hierarchy.SetNumericAttribute("P-1", "Price", 18)
+
+	writer := dimension.AttributesWriter()
+	if writer.Cube != "}ElementAttributes_Products" || writer.Len() != 3 {
+		t.Fatalf("got %d updates to cube '%s', want 3 to '}ElementAttributes_Products'", writer.Len(), writer.Cube)
+	}
+	jUpdates, err := writer.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	element := func(name string) string {
+		return `"Dimensions('Products')/Hierarchies('Products')/Elements('` + name + `')"`
+	}
+	attribute := func(name string) string {
+		return `"Dimensions('%7DElementAttributes_Products')/Hierarchies('%7DElementAttributes_Products')/Elements('` + name + `')"`
+	}
+	// Ordered by element and, per element, in the order the attributes were defined
+	want := `[{"Slice@odata.bind":[` + element("P-1") + `,` + attribute("Caption") + `],"Value":"Chai"},` +
+		`{"Slice@odata.bind":[` + element("P-1") + `,` + attribute("Price") + `],"Value":18},` +
+		`{"Slice@odata.bind":[` + element("P-2") + `,` + attribute("Caption") + `],"Value":"Chang"}]`
+	if jUpdates != want {
+		t.Errorf("got\n%s\nwant\n%s", jUpdates, want)
+	}
+}
+
+func TestHierarchyJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(hierarchy *Hierarchy)
+		want  string
+	}{
+		{
+			name: "derived types and default weights",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddElement("Total", "")
+				hierarchy.AddElement("Chai", "")
+				hierarchy.AddEdge("Total", "Chai")
+			},
+			want: `{"Name":"Measures","Elements":[{"Name":"Total"},{"Name":"Chai"}],"Edges":[{"ParentName":"Total","ComponentName":"Chai","Weight":1}]}`,
+		},
+		{
+			name: "explicit types and negative weights",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddTypedElement("Net Revenue", "", ElementConsolidated)
+				hierarchy.AddTypedElement("Revenue", "", ElementNumeric)
+				hierarchy.AddTypedElement("Discounts", "", ElementNumeric)
+				hierarchy.AddTypedElement("Comment", "", ElementString)
+				hierarchy.AddEdge("Net Revenue", "Revenue")
+				hierarchy.AddWeightedEdge("Net Revenue", "Discounts", -1)
+			},
+			want: `{"Name":"Measures","Elements":[{"Name":"Net Revenue","Type":"Consolidated"},{"Name":"Revenue","Type":"Numeric"},{"Name":"Discounts","Type":"Numeric"},{"Name":"Comment","Type":"String"}],` +
+				`"Edges":[{"ParentName":"Net Revenue","ComponentName":"Revenue","Weight":1},{"ParentName":"Net Revenue","ComponentName":"Discounts","Weight":-1}]}`,
+		},
+		{
+			name: "no edges",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddTypedElement("Comment", "", ElementString)
+			},
+			want: `{"Name":"Measures","Elements":[{"Name":"Comment","Type":"String"}]}`,
+		},
+	}
+	for _, test := range tests {
+		hierarchy := CreateDimension("Measures").AddHierarchy("Measures")
+		test.build(hierarchy)
+		jHierarchy, err := json.Marshal(hierarchy)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if string(jHierarchy) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, jHierarchy, test.want)
+		}
+	}
+}
+
+func TestCubePost(t *testing.T) {
+	cube := &Cube{Name: "Sales", Dimensions: []string{"Products", "}Cultures"}}
+	jCube, err := json.Marshal(cube.Post())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Name":"Sales","Dimensions@odata.bind":["Dimensions('Products')","Dimensions('%7DCultures')"]}`
+	if string(jCube) != want {
+		t.Errorf("got\n%s\nwant\n%s", jCube, want)
+	}
+}
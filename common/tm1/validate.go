@@ -0,0 +1,259 @@
+package tm1
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxNameLength is the maximum length, in characters, of the name of an object, or element, in TM1
+const MaxNameLength = 255
+
+// illegalObjectNameCharacters are the characters that can't be used in the names of objects, like dimensions,
+// hierarchies and cubes, as these names are used for the files the TM1 server persists the objects in
+const illegalObjectNameCharacters = `\/:*?"<>|`
+
+// nameKey returns the key under which TM1 looks up a name, names in TM1 being case and space insensitive
+func nameKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", ""))
+}
+
+// validateName returns an error, describing what the problem is, if the name isn't a valid element name
+func validateName(kind, name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%s name is empty", kind)
+	case utf8.RuneCountInString(name) > MaxNameLength:
+		return fmt.Errorf("%s name '%s' is longer than %d characters", kind, name, MaxNameLength)
+	case strings.TrimSpace(name) != name:
+		return fmt.Errorf("%s name '%s' has leading or trailing spaces", kind, name)
+	case strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return fmt.Errorf("%s name %q contains a control character", kind, name)
+	}
+	return nil
+}
+
+// validateObjectName returns an error, describing what the problem is, if the name isn't a valid object name
+func validateObjectName(kind, name string) error {
+	if err := validateName(kind, name); err != nil {
+		return err
+	}
+	if i := strings.IndexAny(name, illegalObjectNameCharacters); i >= 0 {
+		return fmt.Errorf("%s name '%s' contains the illegal character '%c'", kind, name, name[i])
+	}
+	if strings.HasPrefix(name, "}") {
+		return fmt.Errorf("%s name '%s' starts with '}', which is reserved for control objects", kind, name)
+	}
+	return nil
+}
+
+// Validate checks the dimension, and all its hierarchies, before it gets sent to the TM1 server, returning an error
+// joining all the problems found, or nil if there are none
+func (dimension *Dimension) Validate() error {
+	var errs []error
+	if err := validateObjectName("dimension", dimension.Name); err != nil {
+		errs = append(errs, err)
+	}
+	if len(dimension.Hierarchies) == 0 {
+		errs = append(errs, fmt.Errorf("dimension '%s' has no hierarchies", dimension.Name))
+	}
+	hierarchies := make(map[string]string)
+	for _, hierarchy := range dimension.Hierarchies {
+		if other, ok := hierarchies[nameKey(hierarchy.Name)]; ok {
+			errs = append(errs, fmt.Errorf("dimension '%s' has hierarchy '%s' more than once (as '%s')", dimension.Name, hierarchy.Name, other))
+			continue
+		}
+		hierarchies[nameKey(hierarchy.Name)] = hierarchy.Name
+		for _, err := range hierarchy.validate() {
+			errs = append(errs, fmt.Errorf("dimension '%s': %w", dimension.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the hierarchy before it gets sent to the TM1 server, returning an error joining all the problems
+// found, or nil if there are none. The checks cover the names of the hierarchy and its elements, duplicate
+// elements and edges, edges referring to undeclared elements, cycles and the attribute values. Elements without a
+// parent are valid in TM1, they simply are top level elements, and therefore only reported by Warnings.
+func (hierarchy *Hierarchy) Validate() error {
+	return errors.Join(hierarchy.validate()...)
+}
+
+// Warnings returns the issues found in the dimension, and all its hierarchies, that, unlike the problems Validate
+// reports, won't stop the TM1 server from accepting it, but likely are mistakes nonetheless
+func (dimension *Dimension) Warnings() []string {
+	var warnings []string
+	for _, hierarchy := range dimension.Hierarchies {
+		for _, warning := range hierarchy.Warnings() {
+			warnings = append(warnings, fmt.Sprintf("dimension '%s': %s", dimension.Name, warning))
+		}
+	}
+	return warnings
+}
+
+// Warnings returns the issues found in the hierarchy that won't stop the TM1 server from accepting it. For now these
+// are the orphans, the leaf elements without a parent in a hierarchy with consolidations, which, while valid top level
+// elements, typically are elements the generating code forgot to add to a consolidation.
+func (hierarchy *Hierarchy) Warnings() []string {
+	var warnings []string
+	related := make(map[string]bool)
+	for _, edge := range hierarchy.Edges {
+		related[nameKey(edge.ParentName)] = true
+		related[nameKey(edge.ComponentName)] = true
+	}
+	if len(related) == 0 {
+		return nil
+	}
+	reported := make(map[string]bool)
+	for _, element := range hierarchy.Elements {
+		key := nameKey(element.Name)
+		if related[key] == true || reported[key] == true || element.Type == ElementConsolidated {
+			continue
+		}
+		reported[key] = true
+		warnings = append(warnings, fmt.Sprintf("hierarchy '%s': element '%s' is an orphan, it has neither a parent nor any components", hierarchy.Name, element.Name))
+	}
+	return warnings
+}
+
+// validate returns all the problems found in the hierarchy
+func (hierarchy *Hierarchy) validate() []error {
+	var errs []error
+	report := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("hierarchy '%s': "+format, append([]interface{}{hierarchy.Name}, args...)...))
+	}
+	if err := validateObjectName("hierarchy", hierarchy.Name); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Elements, which must have valid and unique names
+	elements := make(map[string]*Element)
+	for i := range hierarchy.Elements {
+		element := &hierarchy.Elements[i]
+		if err := validateName("element", element.Name); err != nil {
+			report("%v", err)
+			continue
+		}
+		if other, ok := elements[nameKey(element.Name)]; ok {
+			if other.Name == element.Name {
+				report("element '%s' is added more than once", element.Name)
+			} else {
+				report("element '%s' conflicts with element '%s', names being case and space insensitive", element.Name, other.Name)
+			}
+			continue
+		}
+		elements[nameKey(element.Name)] = element
+	}
+
+	// Edges, which must link declared elements, only once
+	children := make(map[string][]string)
+	edges := make(map[[2]string]bool)
+	for _, edge := range hierarchy.Edges {
+		parent, component := elements[nameKey(edge.ParentName)], elements[nameKey(edge.ComponentName)]
+		if parent == nil {
+			report("edge '%s' -> '%s' refers to undeclared parent element '%s'", edge.ParentName, edge.ComponentName, edge.ParentName)
+		}
+		if component == nil {
+			report("edge '%s' -> '%s' refers to undeclared component element '%s'", edge.ParentName, edge.ComponentName, edge.ComponentName)
+		}
+		if parent == nil || component == nil {
+			continue
+		}
+		key := [2]string{nameKey(parent.Name), nameKey(component.Name)}
+		if edges[key] == true {
+			report("edge '%s' -> '%s' is added more than once", parent.Name, component.Name)
+			continue
+		}
+		edges[key] = true
+		if parent.Type == ElementNumeric || parent.Type == ElementString {
+			report("element '%s' has components but is of type %s", parent.Name, parent.Type)
+		}
+		children[key[0]] = append(children[key[0]], key[1])
+	}
+
+	// Cycles, every element can't, directly or indirectly, be a component of itself
+	for _, cycle := range findCycles(hierarchy.Elements, children, elements) {
+		report("edges form a cycle %s", strings.Join(cycle, " -> "))
+	}
+
+	// Attribute values, which must be for declared elements and, for aliases, be unique
+	aliases := make(map[string]string)
+	valueElements := make([]string, 0, len(hierarchy.AttributeValues))
+	for element := range hierarchy.AttributeValues {
+		valueElements = append(valueElements, element)
+	}
+	sort.Strings(valueElements)
+	for _, element := range valueElements {
+		values := hierarchy.AttributeValues[element]
+		if elements[nameKey(element)] == nil {
+			report("attribute values set for undeclared element '%s'", element)
+			continue
+		}
+		for _, definition := range hierarchy.Attributes {
+			if definition.Type == AttributeAlias {
+				alias, _ := values[definition.Name].(string)
+				if alias == "" {
+					continue
+				}
+				if other, ok := elements[nameKey(alias)]; ok && other.Name != element {
+					report("alias '%s' of element '%s' conflicts with element '%s'", alias, element, other.Name)
+				} else if other, ok := aliases[nameKey(alias)]; ok {
+					report("alias '%s' of element '%s' is already used by element '%s'", alias, element, other)
+				}
+				aliases[nameKey(alias)] = element
+			}
+		}
+	}
+	for _, element := range hierarchy.LocalizedElements() {
+		if elements[nameKey(element)] == nil {
+			report("localized attribute values set for undeclared element '%s'", element)
+		}
+	}
+	return errs
+}
+
+// findCycles returns the cycles, as the names of the elements in the cycle, found in the graph of edges
+func findCycles(declared []Element, children map[string][]string, elements map[string]*Element) [][]string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	var cycles [][]string
+	state := make(map[string]int)
+	var path []string
+	var visit func(key string)
+	visit = func(key string) {
+		state[key] = visiting
+		path = append(path, key)
+		for _, child := range children[key] {
+			switch state[child] {
+			case unvisited:
+				visit(child)
+			case visiting:
+				// Found a back edge, the cycle runs from the child, up the current path, back to the child
+				var cycle []string
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i] == child {
+						for _, k := range path[i:] {
+							cycle = append(cycle, elements[k].Name)
+						}
+						break
+					}
+				}
+				cycles = append(cycles, append(cycle, elements[child].Name))
+			}
+		}
+		path = path[:len(path)-1]
+		state[key] = visited
+	}
+	for _, element := range declared {
+		if key := nameKey(element.Name); elements[key] != nil && state[key] == unvisited {
+			visit(key)
+		}
+	}
+	return cycles
+}
@@ -0,0 +1,185 @@
+package tm1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHierarchyValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(hierarchy *Hierarchy)
+		want  []string
+	}{
+		{
+			name: "valid hierarchy",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddElement("Total", "All Products")
+				hierarchy.AddElement("Chai", "")
+				hierarchy.AddElement("Chang", "")
+				hierarchy.AddEdge("Total", "Chai")
+				hierarchy.AddEdge("Total", "Chang")
+			},
+		},
+		{
+			name: "elements without a parent are top level elements",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddElement("Chai", "")
+				hierarchy.AddElement("Chang", "")
+			},
+		},
+		{
+			name: "invalid element names",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddElement("", "")
+				hierarchy.AddElement(" Chai", "")
+				hierarchy.AddElement(strings.Repeat("x", MaxNameLength+1), "")
+			},
+			want: []string{"element name is empty", "leading or trailing spaces", "longer than 255 characters"},
+		},
+		{
+			name: "duplicate elements",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddElement("Chai", "")
+				hierarchy.AddElement("Chai", "")
+				hierarchy.AddElement("CH AI", "")
+			},
+			want: []string{"element 'Chai' is added more than once", "element 'CH AI' conflicts with element 'Chai'"},
+		},
+		{
+			name: "edges to undeclared and leaf elements",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddTypedElement("Chai", "", ElementNumeric)
+				hierarchy.AddElement("Chang", "")
+				hierarchy.AddEdge("Total", "Chai")
+				hierarchy.AddEdge("Chai", "Chang")
+				hierarchy.AddEdge("Chai", "Chang")
+			},
+			want: []string{"undeclared parent element 'Total'", "element 'Chai' has components but is of type Numeric", "edge 'Chai' -> 'Chang' is added more than once"},
+		},
+		{
+			name: "cycle",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddElement("A", "")
+				hierarchy.AddElement("B", "")
+				hierarchy.AddElement("C", "")
+				hierarchy.AddEdge("A", "B")
+				hierarchy.AddEdge("B", "C")
+				hierarchy.AddEdge("C", "A")
+			},
+			want: []string{"edges form a cycle"},
+		},
+		{
+			name: "attribute values",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddAttribute("Code", AttributeAlias)
+				hierarchy.AddElement("Chai", "")
+				hierarchy.AddElement("Chang", "")
+				hierarchy.SetStringAttribute("Chai", "Code", "Chang")
+				hierarchy.SetStringAttribute("Chang", "Code", "X")
+				hierarchy.SetStringAttribute("Chartreuse", CaptionAttribute, "Chartreuse verte")
+				hierarchy.SetLocalizedCaption("Ikura", "fr", "Ikura")
+			},
+			want: []string{"alias 'Chang' of element 'Chai' conflicts with element 'Chang'", "attribute values set for undeclared element 'Chartreuse'", "localized attribute values set for undeclared element 'Ikura'"},
+		},
+	}
+	for _, test := range tests {
+		hierarchy := CreateDimension("Products").AddHierarchy("Products")
+		test.build(hierarchy)
+		err := hierarchy.Validate()
+		if len(test.want) == 0 {
+			if err != nil {
+				t.Errorf("%s: got %v, want no error", test.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: got no error, want %q", test.name, test.want)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: got %q, want it to contain %q", test.name, err, want)
+			}
+		}
+	}
+}
+
+func TestDimensionValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		dimension string
+		want      string
+	}{
+		{"valid name", "Products", ""},
+		{"illegal character", "Products/Services", "contains the illegal character '/'"},
+		{"control object", "}Products", "reserved for control objects"},
+	}
+	for _, test := range tests {
+		dimension := CreateDimension(test.dimension)
+		dimension.AddHierarchy("Products").AddElement("Chai", "")
+		err := dimension.Validate()
+		if test.want == "" && err != nil {
+			t.Errorf("%s: got %v, want no error", test.name, err)
+		} else if test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)) {
+			t.Errorf("%s: got %v, want it to contain %q", test.name, err, test.want)
+		}
+	}
+
+	// A dimension needs at least one hierarchy, and can't have the same one twice
+	dimension := CreateDimension("Products")
+	if err := dimension.Validate(); err == nil || !strings.Contains(err.Error(), "has no hierarchies") {
+		t.Errorf("dimension without hierarchies: got %v", err)
+	}
+	dimension.AddHierarchy("Products")
+	dimension.AddHierarchy("products")
+	if err := dimension.Validate(); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("dimension with duplicate hierarchies: got %v", err)
+	}
+}
+
+func TestHierarchyWarnings(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(hierarchy *Hierarchy)
+		want  []string
+	}{
+		{
+			name: "no consolidations",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddElement("Chai", "")
+				hierarchy.AddElement("Chang", "")
+			},
+		},
+		{
+			name: "orphan leaf",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddElement("Total", "")
+				hierarchy.AddElement("Chai", "")
+				hierarchy.AddElement("Chang", "")
+				hierarchy.AddEdge("Total", "Chai")
+			},
+			want: []string{"hierarchy 'Products': element 'Chang' is an orphan, it has neither a parent nor any components"},
+		},
+		{
+			name: "consolidated elements without components aren't orphans",
+			build: func(hierarchy *Hierarchy) {
+				hierarchy.AddElement("Total", "")
+				hierarchy.AddElement("Chai", "")
+				hierarchy.AddTypedElement("Discontinued", "", ElementConsolidated)
+				hierarchy.AddEdge("total", "CHAI")
+			},
+		},
+	}
+	for _, test := range tests {
+		hierarchy := CreateDimension("Products").AddHierarchy("Products")
+		test.build(hierarchy)
+		if err := hierarchy.Validate(); err != nil {
+			t.Errorf("%s: got %v, want no error", test.name, err)
+		}
+		if got := hierarchy.Warnings(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package tm1
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// The OData types of the two kinds of views in the TM1 Server schema
+const (
+	nativeViewType = "ibm.tm1.api.v1.NativeView"
+	mdxViewType    = "ibm.tm1.api.v1.MDXView"
+)
+
+// View defines the structure of a single view on a cube. A view is either an MDX view, defined by its MDX query, or
+// a native view, defined by the subsets on its titles, columns and rows. Every dimension of the cube, that isn't on
+// the columns or rows, has to be on the titles of a native view.
+type View struct {
+	Name                 string
+	MDX                  string
+	Titles               []ViewAxisSubset
+	Columns              []ViewAxisSubset
+	Rows                 []ViewAxisSubset
+	SuppressEmptyColumns bool
+	SuppressEmptyRows    bool
+	FormatString         string
+}
+
+// ViewAxisSubset defines the subset used for a hierarchy on the titles, columns or rows of a native view. The subset
+// either is a named, public, subset of the hierarchy, or a subset only used by the view, which is either defined by
+// its elements or, in case of a dynamic subset, by an MDX expression. Selected, only used on the titles, holds the
+// name of the element selected in the subset.
+type ViewAxisSubset struct {
+	Dimension  string
+	Hierarchy  string
+	Subset     string
+	Elements   []string
+	Expression string
+	Selected   string
+}
+
+// CreateMDXView creates a new view, with the specified name, defined by the MDX query
+func CreateMDXView(name, mdx string) *View {
+	return &View{Name: name, MDX: mdx}
+}
+
+// CreateNativeView creates a new, empty, native view with the specified name
+func CreateNativeView(name string) *View {
+	return &View{Name: name}
+}
+
+// IsMDX reports whether the view is an MDX view
+func (view *View) IsMDX() bool {
+	return view.MDX != ""
+}
+
+// AddTitle adds the hierarchy, with the selected element, using a subset of the specified elements, to the titles
+func (view *View) AddTitle(dimension, hierarchy, selected string, elements ...string) *ViewAxisSubset {
+	view.Titles = append(view.Titles, ViewAxisSubset{Dimension: dimension, Hierarchy: hierarchy, Elements: elements, Selected: selected})
+	return &view.Titles[len(view.Titles)-1]
+}
+
+// AddColumn adds the hierarchy, using a subset of the specified elements, to the columns
+func (view *View) AddColumn(dimension, hierarchy string, elements ...string) *ViewAxisSubset {
+	view.Columns = append(view.Columns, ViewAxisSubset{Dimension: dimension, Hierarchy: hierarchy, Elements: elements})
+	return &view.Columns[len(view.Columns)-1]
+}
+
+// AddRow adds the hierarchy, using a subset of the specified elements, to the rows
+func (view *View) AddRow(dimension, hierarchy string, elements ...string) *ViewAxisSubset {
+	view.Rows = append(view.Rows, ViewAxisSubset{Dimension: dimension, Hierarchy: hierarchy, Elements: elements})
+	return &view.Rows[len(view.Rows)-1]
+}
+
+// viewPost defines the structure of the JSON payload used to create, or update, a view on the TM1 server
+type viewPost struct {
+	Type                 string `json:"@odata.type"`
+	Name                 string
+	MDX                  string         `json:",omitempty"`
+	Titles               []viewAxisPost `json:",omitempty"`
+	Columns              []viewAxisPost `json:",omitempty"`
+	Rows                 []viewAxisPost `json:",omitempty"`
+	SuppressEmptyColumns bool           `json:",omitempty"`
+	SuppressEmptyRows    bool           `json:",omitempty"`
+	FormatString         string         `json:",omitempty"`
+}
+
+// viewAxisPost defines the structure of the JSON payload for a single subset on an axis of a native view, either
+// binding to a named subset or defining the subset inline
+type viewAxisPost struct {
+	SubsetBind   string      `json:"Subset@odata.bind,omitempty"`
+	Subset       *subsetPost `json:",omitempty"`
+	SelectedBind string      `json:"Selected@odata.bind,omitempty"`
+}
+
+// post returns the view in the structure of the JSON payload used to create, or update, it
+func (view *View) post() *viewPost {
+	if view.IsMDX() {
+		return &viewPost{Type: mdxViewType, Name: view.Name, MDX: view.MDX}
+	}
+	post := &viewPost{Type: nativeViewType, Name: view.Name, SuppressEmptyColumns: view.SuppressEmptyColumns, SuppressEmptyRows: view.SuppressEmptyRows, FormatString: view.FormatString}
+	post.Titles = axisPost(view.Titles)
+	post.Columns = axisPost(view.Columns)
+	post.Rows = axisPost(view.Rows)
+	return post
+}
+
+// axisPost returns the subsets on an axis of a native view in the structure of the JSON payload
+func axisPost(subsets []ViewAxisSubset) []viewAxisPost {
+	var posts []viewAxisPost
+	for _, subset := range subsets {
+		post := viewAxisPost{}
+		if subset.Subset != "" {
+			post.SubsetBind = SubsetPath(subset.Dimension, subset.Hierarchy, subset.Subset, false)
+		} else {
+			post.Subset = &subsetPost{HierarchyBind: HierarchyPath(subset.Dimension, subset.Hierarchy), Expression: subset.Expression}
+			for _, element := range subset.Elements {
+				post.Subset.Elements = append(post.Subset.Elements, ElementPath(subset.Dimension, subset.Hierarchy, element))
+			}
+		}
+		if subset.Selected != "" {
+			post.SelectedBind = ElementPath(subset.Dimension, subset.Hierarchy, subset.Selected)
+		}
+		posts = append(posts, post)
+	}
+	return posts
+}
+
+// viewRead defines the structure of a single View entity as read from the server, with, in case of a native view,
+// the subsets on its axes expanded
+type viewRead struct {
+	Type                 string `json:"@odata.type"`
+	Name                 string
+	MDX                  string
+	Titles               []viewAxisRead
+	Columns              []viewAxisRead
+	Rows                 []viewAxisRead
+	SuppressEmptyColumns bool
+	SuppressEmptyRows    bool
+	FormatString         string
+}
+
+// viewAxisRead defines the structure of a single subset on an axis of a native view as read from the server
+type viewAxisRead struct {
+	Subset struct {
+		Name       string
+		Expression string
+		Hierarchy  struct {
+			Name      string
+			Dimension struct {
+				Name string
+			}
+		}
+		Elements []struct {
+			Name string
+		}
+	}
+	Selected *struct {
+		Name string
+	}
+}
+
+// viewQuery returns the query options to expand a view, or collection of views, with everything we read of it. The
+// axes only exist on native views, hence the type cast in the paths of the expanded navigation properties.
+func viewQuery(resource string) *odata.Query {
+	subsetQuery := func() *odata.Query {
+		return odata.NewQuery("").Select("Name", "Expression").
+			Expand("Hierarchy", odata.NewQuery("").Select("Name").Expand("Dimension", odata.NewQuery("").Select("Name"))).
+			Expand("Elements", odata.NewQuery("").Select("Name"))
+	}
+	return odata.NewQuery(resource).
+		Expand("tm1.NativeView/Titles/Subset", subsetQuery()).
+		Expand("tm1.NativeView/Titles/Selected", odata.NewQuery("").Select("Name")).
+		Expand("tm1.NativeView/Columns/Subset", subsetQuery()).
+		Expand("tm1.NativeView/Rows/Subset", subsetQuery())
+}
+
+// view returns the view, as read from the server, as a View
+func (read *viewRead) view() *View {
+	view := &View{Name: read.Name}
+	if strings.HasSuffix(read.Type, mdxViewType) {
+		view.MDX = read.MDX
+		return view
+	}
+	view.Titles = viewAxis(read.Titles)
+	view.Columns = viewAxis(read.Columns)
+	view.Rows = viewAxis(read.Rows)
+	view.SuppressEmptyColumns = read.SuppressEmptyColumns
+	view.SuppressEmptyRows = read.SuppressEmptyRows
+	view.FormatString = read.FormatString
+	return view
+}
+
+// viewAxis returns the subsets on an axis of a native view, as read from the server, as ViewAxisSubsets
+func viewAxis(reads []viewAxisRead) []ViewAxisSubset {
+	var subsets []ViewAxisSubset
+	for _, axis := range reads {
+		subset := ViewAxisSubset{Dimension: axis.Subset.Hierarchy.Dimension.Name, Hierarchy: axis.Subset.Hierarchy.Name}
+		if axis.Subset.Name != "" {
+			// The elements of a named subset are part of the subset, not of the view
+			subset.Subset = axis.Subset.Name
+		} else if axis.Subset.Expression != "" {
+			subset.Expression = axis.Subset.Expression
+		} else {
+			for _, element := range axis.Subset.Elements {
+				subset.Elements = append(subset.Elements, element.Name)
+			}
+		}
+		if axis.Selected != nil {
+			subset.Selected = axis.Selected.Name
+		}
+		subsets = append(subsets, subset)
+	}
+	return subsets
+}
+
+// GetView retrieves the, public or private, view with the specified name on the cube from the TM1 server
+// Note: if the view doesn't exist the returned error satisfies odata.IsNotFound
+func GetView(ctx context.Context, client *odata.Client, root, cube, name string, private bool) (*View, error) {
+	res, err := odata.GetEntity[viewRead](ctx, client, root+viewQuery(ViewPath(cube, name, private)).String())
+	if err != nil {
+		return nil, err
+	}
+	return res.view(), nil
+}
+
+// GetViews retrieves all, public or private, views on the cube from the TM1 server
+func GetViews(ctx context.Context, client *odata.Client, root, cube string, private bool) ([]*View, error) {
+	res, err := odata.GetCollection[viewRead](ctx, client, root+viewQuery(ViewsPath(cube, private)).OrderBy("Name").String())
+	if err != nil {
+		return nil, err
+	}
+	views := make([]*View, len(res.Value))
+	for i, read := range res.Value {
+		views[i] = read.view()
+	}
+	return views, nil
+}
+
+// PostView creates the, public or private, view on the cube on the TM1 server
+func PostView(ctx context.Context, client *odata.Client, root, cube string, view *View, private bool) error {
+	jView, err := json.Marshal(view.post())
+	if err != nil {
+		return err
+	}
+	resp, err := client.ExecutePOSTRequestContext(ctx, root+ViewsPath(cube, private), "application/json", string(jView))
+	return expectStatus(resp, err, 201, func() string {
+		return "Failed to create view '" + view.Name + "' on cube '" + cube + "'."
+	})
+}
+
+// UpdateView replaces the definition of the, existing, view on the cube on the TM1 server
+func UpdateView(ctx context.Context, client *odata.Client, root, cube string, view *View, private bool) error {
+	jView, err := json.Marshal(view.post())
+	if err != nil {
+		return err
+	}
+	resp, err := client.ExecutePATCHRequestContext(ctx, root+ViewPath(cube, view.Name, private), "application/json", string(jView))
+	return expectStatus(resp, err, 204, func() string {
+		return "Failed to update view '" + view.Name + "' on cube '" + cube + "'."
+	})
+}
+
+// DeleteView deletes the, public or private, view with the specified name from the cube on the TM1 server
+func DeleteView(ctx context.Context, client *odata.Client, root, cube, name string, private bool) error {
+	resp, err := client.ExecuteDELETERequestContext(ctx, root+ViewPath(cube, name, private))
+	return expectStatus(resp, err, 204, func() string {
+		return "Failed to delete view '" + name + "' on cube '" + cube + "'."
+	})
+}
+
+// ExecuteView executes the, public or private, view with the specified name on the cube and returns the resulting
+// cellset, with all its axes and cells. The titles of a native view, like the WHERE clause of an MDX view, end up
+// as the slicer of the cellset. The cellset is deleted from the server once it has been read.
+func ExecuteView(ctx context.Context, client *odata.Client, root, cube, name string, private bool) (*CellSet, error) {
+	// The number of axes of the cellset depends on the type, and in case of an MDX view on the query, of the view
+	view, err := GetView(ctx, client, root, cube, name, private)
+	if err != nil {
+		return nil, err
+	}
+	return executeCellSet(ctx, client, root, ViewPath(cube, name, private)+"/tm1.Execute", "{}", view.axisCount())
+}
+
+// axisCount returns the number of axes of the cellset resulting from executing the view. A native view has its
+// columns as the first axis and its rows as the second, but only if they have any hierarchies on them, as a view
+// without rows results in a cellset with a single axis, and one without columns and rows in one without any axes.
+func (view *View) axisCount() int {
+	if view.IsMDX() {
+		return queryAxisCount(view.MDX)
+	}
+	if len(view.Rows) > 0 {
+		return 2
+	}
+	if len(view.Columns) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package tm1
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestViewPost(t *testing.T) {
+	tests := []struct {
+		name string
+		view func() *View
+		want string
+	}{
+		{
+			name: "mdx view",
+			view: func() *View {
+				return CreateMDXView("Top Products", "SELECT {[Measures].[Revenue]} ON 0 FROM [Sales]")
+			},
+			want: `{"@odata.type":"ibm.tm1.api.v1.MDXView","Name":"Top Products","MDX":"SELECT {[Measures].[Revenue]} ON 0 FROM [Sales]"}`,
+		},
+		{
+			name: "native view",
+			view: func() *View {
+				view := CreateNativeView("Revenue by Year")
+				view.AddTitle("Measures", "Measures", "Revenue", "Revenue")
+				view.AddColumn("Time", "Years").Subset = "All Years"
+				view.AddRow("Products", "Products").Expression = "{[Products].[Products].[All].Children}"
+				view.SuppressEmptyRows = true
+				return view
+			},
+			want: `{"@odata.type":"ibm.tm1.api.v1.NativeView","Name":"Revenue by Year",` +
+				`"Titles":[{"Subset":{"Hierarchy@odata.bind":"Dimensions('Measures')/Hierarchies('Measures')","Elements@odata.bind":["Dimensions('Measures')/Hierarchies('Measures')/Elements('Revenue')"]},"Selected@odata.bind":"Dimensions('Measures')/Hierarchies('Measures')/Elements('Revenue')"}],` +
+				`"Columns":[{"Subset@odata.bind":"Dimensions('Time')/Hierarchies('Years')/Subsets('All%20Years')"}],` +
+				`"Rows":[{"Subset":{"Hierarchy@odata.bind":"Dimensions('Products')/Hierarchies('Products')","Expression":"{[Products].[Products].[All].Children}"}}],` +
+				`"SuppressEmptyRows":true}`,
+		},
+	}
+	for _, test := range tests {
+		jView, err := json.Marshal(test.view().post())
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if string(jView) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, jView, test.want)
+		}
+	}
+}
+
+func TestViewRead(t *testing.T) {
+	tests := []struct {
+		name string
+		read string
+		want *View
+	}{
+		{
+			name: "mdx view",
+			read: `{"@odata.type":"#ibm.tm1.api.v1.MDXView","Name":"Top Products","MDX":"SELECT FROM [Sales]"}`,
+			want: &View{Name: "Top Products", MDX: "SELECT FROM [Sales]"},
+		},
+		{
+			name: "native view",
+			read: `{"@odata.type":"#ibm.tm1.api.v1.NativeView","Name":"Revenue by Year","SuppressEmptyRows":true,` +
+				`"Titles":[{"Subset":{"Name":"","Expression":"","Hierarchy":{"Name":"Measures","Dimension":{"Name":"Measures"}},"Elements":[{"Name":"Revenue"}]},"Selected":{"Name":"Revenue"}}],` +
+				`"Columns":[{"Subset":{"Name":"All Years","Expression":"","Hierarchy":{"Name":"Years","Dimension":{"Name":"Time"}},"Elements":[{"Name":"1997"}]}}],` +
+				`"Rows":[{"Subset":{"Name":"","Expression":"{[Products].[Products].[All].Children}","Hierarchy":{"Name":"Products","Dimension":{"Name":"Products"}},"Elements":[{"Name":"Beverages"}]}}]}`,
+			want: &View{
+				Name:              "Revenue by Year",
+				Titles:            []ViewAxisSubset{{Dimension: "Measures", Hierarchy: "Measures", Elements: []string{"Revenue"}, Selected: "Revenue"}},
+				Columns:           []ViewAxisSubset{{Dimension: "Time", Hierarchy: "Years", Subset: "All Years"}},
+				Rows:              []ViewAxisSubset{{Dimension: "Products", Hierarchy: "Products", Expression: "{[Products].[Products].[All].Children}"}},
+				SuppressEmptyRows: true,
+			},
+		},
+	}
+	for _, test := range tests {
+		read := &viewRead{}
+		if err := json.Unmarshal([]byte(test.read), read); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := read.view(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"os/signal"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+	"github.com/hubert-heijkers/GoTHINK2020/common/tm1"
+	"github.com/joho/godotenv"
+)
+
+// Environment variables
+var tm1ServiceRootURL string
+
+// The http client, extended with some odata functions, we'll use throughout.
+var client *odata.Client
+
+// leavesHierarchyName is the name of the hierarchy, holding all leaf elements of a dimension, TM1 maintains itself
+const leavesHierarchyName = "Leaves"
+
+func main() {
+	out := flag.String("out", "model", "the folder to export the model to")
+	controlObjects := flag.Bool("control", false, "include the control objects, the names of which start with a '}'")
+	flag.Parse()
+
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+	tm1ServiceRootURL = os.Getenv("TM1_SERVICE_ROOT_URL")
+
+	// Create the one and only http client we'll be using, with a cookie jar enabled to keep reusing our session
+	client = &odata.Client{}
+	cookieJar, _ := cookiejar.New(nil)
+	client.Jar = cookieJar
+	client.RetryPolicy = odata.DefaultRetryPolicy()
+
+	// Validate that the TM1 server is accessable by requesting the version of the server, through the client so
+	// this initial request gets rate limited and retried like any other
+	authenticate := func(req *http.Request) {
+		// Since this is our initial request we'll have to provide a user name and
+		// password, also conveniently stored in the environment variables, to authenticate.
+		// Note: using authentication mode 1, TM1 authentication, which maps to basic
+		// authentication in HTTP[S]
+		req.SetBasicAuth(os.Getenv("TM1_USER"), os.Getenv("TM1_PASSWORD"))
+		req.Header.Set("Accept", "*/*")
+	}
+
+	// Let's execute the request
+	resp, err := client.ExecuteGETRequestEx(tm1ServiceRootURL+"Configuration/ProductVersion/$value", authenticate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Validate that the request executed successfully
+	err = odata.ValidateStatusCode(resp, 200, func() string {
+		return "Server responded with an unexpected result while asking for its version number."
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The body simply contains the version number of the server
+	version, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	fmt.Println("Using TM1 Server version", string(version))
+
+	// Interrupting the export cancels the context which aborts any in-flight request
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Read the model, all dimensions, cubes and processes, from the server
+	model := &tm1.Model{}
+	fmt.Println(">> Reading dimensions...")
+	model.Dimensions, err = tm1.GetDimensions(ctx, client, tm1ServiceRootURL, *controlObjects)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(">> Reading cubes...")
+	model.Cubes, err = tm1.GetCubes(ctx, client, tm1ServiceRootURL, *controlObjects)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(">> Reading processes...")
+	model.Processes, err = tm1.GetProcesses(ctx, client, tm1ServiceRootURL, *controlObjects)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The Leaves hierarchy is maintained by TM1 itself, there is no point in exporting it
+	for _, dimension := range model.Dimensions {
+		hierarchies := dimension.Hierarchies[:0]
+		for _, hierarchy := range dimension.Hierarchies {
+			if hierarchy.Name != leavesHierarchyName {
+				hierarchies = append(hierarchies, hierarchy)
+			}
+		}
+		dimension.Hierarchies = hierarchies
+	}
+
+	// And write it, one file per object, to the output folder
+	fmt.Println(">> Writing", len(model.Dimensions), "dimensions,", len(model.Cubes), "cubes and", len(model.Processes), "processes to", *out)
+	err = tm1.WriteModel(*out, model)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// And we are done!
+	fmt.Println(">> Done!")
+}
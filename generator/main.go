@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// typeSelection defines an entity or complex type to generate and, optionally, the properties to include
+type typeSelection struct {
+	qualifiedName string
+	properties    []string
+}
+
+// generator holds the state while generating the Go source for the selected types
+type generator struct {
+	metadata     *odata.Metadata
+	source       string
+	pkg          string
+	selected     []*typeSelection
+	generated    map[string]string
+	imports      map[string]bool
+	out          bytes.Buffer
+	complexQueue []string
+}
+
+// primitiveTypes maps the OData primitive types to their Go counterparts
+var primitiveTypes = map[string]string{
+	"Edm.String":         "string",
+	"Edm.Guid":           "string",
+	"Edm.Date":           "string",
+	"Edm.TimeOfDay":      "string",
+	"Edm.Duration":       "string",
+	"Edm.Binary":         "string",
+	"Edm.Boolean":        "bool",
+	"Edm.Byte":           "uint8",
+	"Edm.SByte":          "int8",
+	"Edm.Int16":          "int16",
+	"Edm.Int32":          "int",
+	"Edm.Int64":          "int64",
+	"Edm.Single":         "float32",
+	"Edm.Double":         "float64",
+	"Edm.Decimal":        "float64",
+	"Edm.DateTimeOffset": "odata.DateTimeOffset",
+}
+
+// loadMetadata loads the metadata document either from the service, if source is a URL, or from a file
+func loadMetadata(source, user, password string) (*odata.Metadata, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		data, err := ioutil.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		return odata.ParseMetadata(data)
+	}
+
+	// The source is the service root URL, or the URL of the metadata document itself
+	serviceRootURL := strings.TrimSuffix(source, "$metadata")
+	if !strings.HasSuffix(serviceRootURL, "/") {
+		serviceRootURL += "/"
+	}
+	client := &odata.Client{}
+	cookieJar, _ := cookiejar.New(nil)
+	client.Jar = cookieJar
+	odata.Verbose = false
+	ctx := context.Background()
+	if user != "" {
+		// Authenticate the session first, the cookie jar will take care of reusing it
+		resp, err := client.ExecuteGETRequestExContext(ctx, serviceRootURL, func(req *http.Request) {
+			req.SetBasicAuth(user, password)
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+	}
+	return client.GetMetadata(ctx, serviceRootURL)
+}
+
+// parseSelection parses the type selection, a comma separated list of, optionally qualified, type names each
+// optionally followed by the properties to include between parentheses, e.g. Customer(CustomerID,CompanyName),Order
+func parseSelection(metadata *odata.Metadata, selection string) ([]*typeSelection, error) {
+	var selected []*typeSelection
+	for _, item := range splitTopLevel(selection) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		sel := &typeSelection{qualifiedName: item}
+		if i := strings.Index(item, "("); i >= 0 && strings.HasSuffix(item, ")") {
+			sel.qualifiedName = item[:i]
+			for _, property := range strings.Split(item[i+1:len(item)-1], ",") {
+				if property = strings.TrimSpace(property); property != "" {
+					sel.properties = append(sel.properties, property)
+				}
+			}
+		}
+		qualifiedName, err := qualify(metadata, sel.qualifiedName)
+		if err != nil {
+			return nil, err
+		}
+		sel.qualifiedName = qualifiedName
+		selected = append(selected, sel)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no types selected")
+	}
+	return selected, nil
+}
+
+// splitTopLevel splits the string on commas not enclosed by parentheses
+func splitTopLevel(s string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(items, s[start:])
+}
+
+// qualify returns the namespace qualified name of the passed, possibly unqualified, entity or complex type name
+func qualify(metadata *odata.Metadata, name string) (string, error) {
+	if strings.Contains(name, ".") {
+		if metadata.EntityType(name) == nil && metadata.ComplexType(name) == nil {
+			return "", fmt.Errorf("unknown type '%s'", name)
+		}
+		return name, nil
+	}
+	var found []string
+	for _, schema := range metadata.Schemas {
+		for _, entityType := range schema.EntityTypes {
+			if entityType.Name == name {
+				found = append(found, schema.Namespace+"."+name)
+			}
+		}
+		for _, complexType := range schema.ComplexTypes {
+			if complexType.Name == name {
+				found = append(found, schema.Namespace+"."+name)
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("unknown type '%s'", name)
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("ambiguous type '%s', qualify it with one of the namespaces: %s", name, strings.Join(found, ", "))
+}
+
+// goName turns an OData identifier into an exported Go identifier
+func goName(name string) string {
+	var out strings.Builder
+	for i, c := range name {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_':
+			if i == 0 {
+				if unicode.IsDigit(c) {
+					out.WriteRune('X')
+				}
+				c = unicode.ToUpper(c)
+			}
+			out.WriteRune(c)
+		default:
+			out.WriteRune('_')
+		}
+	}
+	return out.String()
+}
+
+// typeName returns the name of the Go type generated for the type with the passed qualified name. The namespace
+// isn't part of the name, so types with the same name in different schemas result in the same Go type name, which
+// generateType detects and reports as an error.
+func (g *generator) typeName(qualifiedName string) string {
+	return goName(qualifiedName[strings.LastIndex(qualifiedName, ".")+1:])
+}
+
+// isSelected returns if the entity type with the passed qualified name was selected to be generated
+func (g *generator) isSelected(qualifiedName string) bool {
+	entityType := g.metadata.EntityType(qualifiedName)
+	for _, sel := range g.selected {
+		if entityType != nil && g.metadata.EntityType(sel.qualifiedName) == entityType {
+			return true
+		}
+	}
+	return false
+}
+
+// goType returns the Go type for the passed OData type, or "" if no field should be generated for it
+func (g *generator) goType(typeName string, isNavigation bool) string {
+	if strings.HasPrefix(typeName, "Collection(") && strings.HasSuffix(typeName, ")") {
+		itemType := g.goType(typeName[len("Collection("):len(typeName)-1], isNavigation)
+		if itemType == "" {
+			return ""
+		}
+		return "[]" + strings.TrimPrefix(itemType, "*")
+	}
+	if goType, ok := primitiveTypes[typeName]; ok {
+		if strings.HasPrefix(goType, "odata.") {
+			g.imports["github.com/hubert-heijkers/GoTHINK2020/common/odata"] = true
+		}
+		return goType
+	}
+	if isNavigation {
+		// Navigation properties are only generated if their target type gets generated as well
+		if !g.isSelected(typeName) {
+			return ""
+		}
+		return "*" + g.typeName(typeName)
+	}
+	if g.metadata.ComplexType(typeName) != nil {
+		g.complexQueue = append(g.complexQueue, typeName)
+		return g.typeName(typeName)
+	}
+	// Enumeration values are represented by the name of their member
+	if i := strings.LastIndex(typeName, "."); i >= 0 {
+		if schema := g.metadata.Schema(typeName[:i]); schema != nil {
+			for _, enumType := range schema.EnumTypes {
+				if enumType.Name == typeName[i+1:] {
+					return "string"
+				}
+			}
+		}
+	}
+	g.imports["encoding/json"] = true
+	return "json.RawMessage"
+}
+
+// properties returns the structural and navigation properties of the type, including inherited ones
+func (g *generator) properties(structuredType *odata.StructuredType) ([]*odata.Property, []*odata.NavigationProperty) {
+	var properties []*odata.Property
+	var navigationProperties []*odata.NavigationProperty
+	if structuredType.BaseType != "" {
+		if entityType := g.metadata.EntityType(structuredType.BaseType); entityType != nil {
+			properties, navigationProperties = g.properties(&entityType.StructuredType)
+		} else if complexType := g.metadata.ComplexType(structuredType.BaseType); complexType != nil {
+			properties, navigationProperties = g.properties(&complexType.StructuredType)
+		}
+	}
+	return append(properties, structuredType.Properties...), append(navigationProperties, structuredType.NavigationProperties...)
+}
+
+// generateType generates the Go struct for an entity or complex type
+func (g *generator) generateType(qualifiedName string, include []string) error {
+	var structuredType *odata.StructuredType
+	kind := "entity"
+	if entityType := g.metadata.EntityType(qualifiedName); entityType != nil {
+		structuredType = &entityType.StructuredType
+	} else if complexType := g.metadata.ComplexType(qualifiedName); complexType != nil {
+		structuredType = &complexType.StructuredType
+		kind = "complex type"
+	} else {
+		return fmt.Errorf("unknown type '%s'", qualifiedName)
+	}
+	name := g.typeName(qualifiedName)
+	if generated, ok := g.generated[name]; ok {
+		if generated != qualifiedName {
+			return fmt.Errorf("types '%s' and '%s' would both be generated as '%s'", generated, qualifiedName, name)
+		}
+		return nil
+	}
+	g.generated[name] = qualifiedName
+
+	included := func(propertyName string) bool {
+		if len(include) == 0 {
+			return true
+		}
+		for _, p := range include {
+			if p == propertyName {
+				return true
+			}
+		}
+		return false
+	}
+
+	var fields bytes.Buffer
+	properties, navigationProperties := g.properties(structuredType)
+	for _, property := range properties {
+		if !included(property.Name) {
+			continue
+		}
+		if goType := g.goType(property.Type, false); goType != "" {
+			writeField(&fields, property.Name, goType, false)
+		}
+	}
+	for _, navigationProperty := range navigationProperties {
+		if !included(navigationProperty.Name) {
+			continue
+		}
+		if goType := g.goType(navigationProperty.Type, true); goType != "" {
+			writeField(&fields, navigationProperty.Name, goType, true)
+		}
+	}
+	for _, p := range include {
+		found := false
+		for _, property := range properties {
+			found = found || property.Name == p
+		}
+		for _, navigationProperty := range navigationProperties {
+			found = found || navigationProperty.Name == p
+		}
+		if !found {
+			return fmt.Errorf("type '%s' has no property '%s'", qualifiedName, p)
+		}
+	}
+
+	fmt.Fprintf(&g.out, "\n// %s defines the structure of a single %s %s\n", name, structuredType.Name, kind)
+	fmt.Fprintf(&g.out, "type %s struct {\n%s}\n", name, fields.String())
+	if kind == "entity" {
+		g.imports["github.com/hubert-heijkers/GoTHINK2020/common/odata"] = true
+		fmt.Fprintf(&g.out, "\n// %sCollectionResponse defines the structure of an odata compliant response wrapping a %s collection\n", name, structuredType.Name)
+		fmt.Fprintf(&g.out, "type %sCollectionResponse = odata.CollectionResponse[%s]\n", name, name)
+	}
+	return nil
+}
+
+// writeField writes a single struct field, with a json tag if the Go name differs from the property name
+func writeField(fields *bytes.Buffer, propertyName, goType string, omitEmpty bool) {
+	name := goName(propertyName)
+	var tag string
+	switch {
+	case name != propertyName && omitEmpty:
+		tag = fmt.Sprintf(" `json:\"%s,omitempty\"`", propertyName)
+	case name != propertyName:
+		tag = fmt.Sprintf(" `json:\"%s\"`", propertyName)
+	case omitEmpty:
+		tag = " `json:\",omitempty\"`"
+	}
+	fmt.Fprintf(fields, "\t%s %s%s\n", name, goType, tag)
+}
+
+// generate generates the Go source for all selected types, and the complex types they depend on
+func (g *generator) generate() ([]byte, error) {
+	for _, sel := range g.selected {
+		if err := g.generateType(sel.qualifiedName, sel.properties); err != nil {
+			return nil, err
+		}
+	}
+	for len(g.complexQueue) > 0 {
+		qualifiedName := g.complexQueue[0]
+		g.complexQueue = g.complexQueue[1:]
+		if err := g.generateType(qualifiedName, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by generator from %s; DO NOT EDIT.\n\npackage %s\n", g.source, g.pkg)
+	if len(g.imports) > 0 {
+		imports := make([]string, 0, len(g.imports))
+		for imp := range g.imports {
+			imports = append(imports, imp)
+		}
+		sort.Strings(imports)
+		src.WriteString("\nimport (\n")
+		for _, imp := range imports {
+			fmt.Fprintf(&src, "\t%q\n", imp)
+		}
+		src.WriteString(")\n")
+	}
+	src.Write(g.out.Bytes())
+	return format.Source(src.Bytes())
+}
+
+func main() {
+	source := flag.String("metadata", "", "service root URL, or metadata document URL or file, to generate the types from")
+	user := flag.String("user", os.Getenv("TM1_USER"), "user name to authenticate with, if any")
+	password := flag.String("password", os.Getenv("TM1_PASSWORD"), "password to authenticate with")
+	pkg := flag.String("package", "main", "name of the package the generated code belongs to")
+	types := flag.String("types", "", "comma separated list of types to generate, e.g. Customer(CustomerID,CompanyName),Order")
+	out := flag.String("out", "", "file to write the generated code to, defaults to stdout")
+	flag.Parse()
+	if *source == "" || *types == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Load and parse the metadata
+	metadata, err := loadMetadata(*source, *user, *password)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Generate the types
+	selected, err := parseSelection(metadata, *types)
+	if err != nil {
+		log.Fatal(err)
+	}
+	g := &generator{
+		metadata:  metadata,
+		source:    *source,
+		pkg:       *pkg,
+		selected:  selected,
+		generated: make(map[string]string),
+		imports:   make(map[string]bool),
+	}
+	src, err := g.generate()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// And write them out
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err = ioutil.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
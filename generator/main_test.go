@@ -0,0 +1,213 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+)
+
+// testMetadata is a trimmed down version of the NorthWind metadata document, with an additional schema declaring a
+// type with the same name as one in the NorthWind schema
+const testMetadata = `<?xml version="1.0" encoding="utf-8"?>
+<edmx:Edmx Version="4.0" xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx">
+  <edmx:DataServices>
+    <Schema Namespace="NorthwindModel" xmlns="http://docs.oasis-open.org/odata/ns/edm">
+      <EntityType Name="Product">
+        <Key><PropertyRef Name="ProductID"/></Key>
+        <Property Name="ProductID" Type="Edm.Int32" Nullable="false"/>
+        <Property Name="ProductName" Type="Edm.String"/>
+        <Property Name="UnitPrice" Type="Edm.Decimal"/>
+        <Property Name="Discontinued" Type="Edm.Boolean"/>
+        <NavigationProperty Name="Category" Type="NorthwindModel.Category"/>
+        <NavigationProperty Name="Order_Details" Type="Collection(NorthwindModel.Order_Detail)"/>
+      </EntityType>
+      <EntityType Name="Category">
+        <Key><PropertyRef Name="CategoryID"/></Key>
+        <Property Name="CategoryID" Type="Edm.Int32" Nullable="false"/>
+        <Property Name="CategoryName" Type="Edm.String"/>
+        <NavigationProperty Name="Products" Type="Collection(NorthwindModel.Product)"/>
+      </EntityType>
+      <EntityType Name="Order_Detail">
+        <Key><PropertyRef Name="OrderID"/><PropertyRef Name="ProductID"/></Key>
+        <Property Name="OrderID" Type="Edm.Int32" Nullable="false"/>
+        <Property Name="ProductID" Type="Edm.Int32" Nullable="false"/>
+      </EntityType>
+      <EntityType Name="Order">
+        <Key><PropertyRef Name="OrderID"/></Key>
+        <Property Name="OrderID" Type="Edm.Int32" Nullable="false"/>
+        <Property Name="OrderDate" Type="Edm.DateTimeOffset"/>
+        <Property Name="ShipAddress" Type="NorthwindModel.Address"/>
+      </EntityType>
+      <ComplexType Name="Address">
+        <Property Name="Street" Type="Edm.String"/>
+        <Property Name="City" Type="Edm.String"/>
+      </ComplexType>
+    </Schema>
+    <Schema Namespace="Legacy" xmlns="http://docs.oasis-open.org/odata/ns/edm">
+      <EntityType Name="Product">
+        <Key><PropertyRef Name="Code"/></Key>
+        <Property Name="Code" Type="Edm.String" Nullable="false"/>
+      </EntityType>
+    </Schema>
+  </edmx:DataServices>
+</edmx:Edmx>`
+
+func newTestGenerator(t *testing.T, selection string) (*generator, error) {
+	metadata, err := odata.ParseMetadata([]byte(testMetadata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected, err := parseSelection(metadata, selection)
+	if err != nil {
+		return nil, err
+	}
+	return &generator{
+		metadata:  metadata,
+		source:    "test",
+		pkg:       "northwind",
+		selected:  selected,
+		generated: make(map[string]string),
+		imports:   make(map[string]bool),
+	}, nil
+}
+
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"ProductID", "ProductID"},
+		{"customerID", "CustomerID"},
+		{"Order_Details", "Order_Details"},
+		{"1stQuarter", "X1stQuarter"},
+		{"Unit-Price", "Unit_Price"},
+	}
+	for _, test := range tests {
+		if got := goName(test.name); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSplitTopLevel(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"Product", []string{"Product"}},
+		{"Product,Category", []string{"Product", "Category"}},
+		{"Product(ProductID,ProductName),Category", []string{"Product(ProductID,ProductName)", "Category"}},
+	}
+	for _, test := range tests {
+		if got := splitTopLevel(test.s); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		selection string
+		want      []*typeSelection
+		err       string
+	}{
+		{"Category", []*typeSelection{{qualifiedName: "NorthwindModel.Category"}}, ""},
+		{"Category(CategoryID, CategoryName),Address", []*typeSelection{
+			{qualifiedName: "NorthwindModel.Category", properties: []string{"CategoryID", "CategoryName"}},
+			{qualifiedName: "NorthwindModel.Address"},
+		}, ""},
+		{"Legacy.Product", []*typeSelection{{qualifiedName: "Legacy.Product"}}, ""},
+		{"Product", nil, "ambiguous type 'Product'"},
+		{"Supplier", nil, "unknown type 'Supplier'"},
+		{"NorthwindModel.Supplier", nil, "unknown type 'NorthwindModel.Supplier'"},
+		{" , ", nil, "no types selected"},
+	}
+	for _, test := range tests {
+		g, err := newTestGenerator(t, test.selection)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.selection, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.selection, err)
+			continue
+		}
+		if !reflect.DeepEqual(g.selected, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.selection, g.selected, test.want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name      string
+		selection string
+		contains  []string
+		excludes  []string
+		err       string
+	}{
+		{
+			name:      "navigation only to selected types",
+			selection: "NorthwindModel.Product(ProductID,ProductName,UnitPrice,Category,Order_Details),Category(CategoryID,CategoryName)",
+			contains: []string{
+				"type Product struct {",
+				"ProductID   int\n",
+				"UnitPrice   float64\n",
+				"Category    *Category `json:\",omitempty\"`",
+				"type ProductCollectionResponse = odata.CollectionResponse[Product]",
+				"\"github.com/hubert-heijkers/GoTHINK2020/common/odata\"",
+			},
+			excludes: []string{"Order_Details", "Discontinued"},
+		},
+		{
+			name:      "complex types and date time offsets",
+			selection: "Order",
+			contains: []string{
+				"OrderDate   odata.DateTimeOffset",
+				"ShipAddress Address",
+				"type Address struct {",
+			},
+		},
+		{
+			name:      "unknown property",
+			selection: "Category(CategoryID,Description)",
+			err:       "type 'NorthwindModel.Category' has no property 'Description'",
+		},
+		{
+			name:      "colliding type names",
+			selection: "NorthwindModel.Product,Legacy.Product",
+			err:       "types 'NorthwindModel.Product' and 'Legacy.Product' would both be generated as 'Product'",
+		},
+	}
+	for _, test := range tests {
+		g, err := newTestGenerator(t, test.selection)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		src, err := g.generate()
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		for _, s := range test.contains {
+			if !strings.Contains(string(src), s) {
+				t.Errorf("%s: generated code doesn't contain %q:\n%s", test.name, s, src)
+			}
+		}
+		for _, s := range test.excludes {
+			if strings.Contains(string(src), s) {
+				t.Errorf("%s: generated code contains %q:\n%s", test.name, s, src)
+			}
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 
 	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+	"github.com/hubert-heijkers/GoTHINK2020/common/tm1"
 	"github.com/joho/godotenv"
 )
 
@@ -28,6 +29,20 @@ var tm1ServiceRootURL string
 // The http client, extended with some odata functions, we'll use throughout.
 var client *odata.Client
 
+// expectStatus validates that the request executed, and the server responded with the expected status code, closing
+// the body of the response if it did
+func expectStatus(resp *http.Response, err error, statusCode int, logFmt func() string) error {
+	if err != nil {
+		return err
+	}
+	err = odata.ValidateStatusCode(resp, statusCode, logFmt)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
 func main() {
 	// Load environment variables from .env file
 	err := godotenv.Load()
@@ -62,9 +77,12 @@ func main() {
 	}
 
 	// Validate that the request executed successfully
-	odata.ValidateStatusCode(resp, 200, func() string {
+	err = odata.ValidateStatusCode(resp, 200, func() string {
 		return "Server responded with an unexpected result while asking for its version number."
 	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// The body simply contains the version number of the server
 	version, _ := ioutil.ReadAll(resp.Body)
@@ -80,26 +98,43 @@ func main() {
 	// Initialize GIT
 	// bind the model to the github.com/hubert-heijkers/tm1-model-northwind repository
 	fmt.Println(">> Initialize GIT...")
-	resp = client.ExecutePOSTRequest(tm1ServiceRootURL+"GitInit", "application/json", `
+	resp, err = client.ExecutePOSTRequest(tm1ServiceRootURL+"GitInit", "application/json", `
 	{
 		"URL": "https://github.com/Hubert-Heijkers/tm1-model-northwind.git",
 		"Deployment": "Development"
 	}
 	`)
-	defer resp.Body.Close()
+	err = expectStatus(resp, err, 200, func() string {
+		return "Failed to initialize GIT."
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Prepare to pull the head of the master branch
 	fmt.Println(">> Pull head from master...")
-	resp = client.ExecutePOSTRequest(tm1ServiceRootURL+"GitPull", "application/json", `
+	resp, err = client.ExecutePOSTRequest(tm1ServiceRootURL+"GitPull", "application/json", `
 	{
 		"Branch": "master"
 	}
 	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = odata.ValidateStatusCode(resp, 200, func() string {
+		return "Failed to pull head from master."
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// The response contains the details about the git pull plan
 	// Read the response body
 	defer resp.Body.Close()
-	body, _ := ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println(string(body))
 
 	// Unmarshal the git plan details
@@ -113,7 +148,13 @@ func main() {
 	// or she whats to actually apply the changes as described in the git pull plan but
 	// here we know we do and simply execute the git pull plan to apply all changes.
 	fmt.Println(">> Execute the git pull plan...")
-	resp = client.ExecutePOSTRequest(tm1ServiceRootURL+"GitPlans('"+gitPullPlan.ID+"')/tm1.Execute", "application/json", `{}`)
+	resp, err = client.ExecutePOSTRequest(tm1ServiceRootURL+tm1.GitPlanPath(gitPullPlan.ID)+"/tm1.Execute", "application/json", `{}`)
+	err = expectStatus(resp, err, 204, func() string {
+		return "Failed to execute git pull plan '" + gitPullPlan.ID + "'."
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// And we are done!
 	fmt.Println(">> Done!")
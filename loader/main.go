@@ -1,18 +1,20 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"os"
+	"os/signal"
 	"strconv"
 
 	"github.com/hubert-heijkers/GoTHINK2020/builder/northwind"
 	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+	"github.com/hubert-heijkers/GoTHINK2020/common/tm1"
 	"github.com/joho/godotenv"
 )
 
@@ -20,77 +22,67 @@ import (
 var datasourceServiceRootURL string
 var tm1ServiceRootURL string
 
+// Names of the cube we are loading, and its dimensions
+const productDimensionName = "Products"
+const customerDimensionName = "Customers"
+const employeeDimensionName = "Employees"
+const timeDimensionName = "Time"
+const measuresDimensionName = "Measures"
+const ordersCubeName = "Sales"
+
 // The http client, extended with some odata functions, we'll use throughout.
 var client *odata.Client
 
-func processOrderData(responseBody []byte) (int, string) {
-	// Unmarshal the JSON response
-	res := northwind.OrderCollectionResponse{}
-	err := json.Unmarshal(responseBody, &res)
-	if err != nil {
-		log.Fatal(err)
-	}
+func processOrderData(ctx context.Context, sales *tm1.CellWriter, orders []northwind.Order) error {
 
 	// Process the collection of orders and convert to a set of cell updates
 	// Note that we are using making it easy on ourselves here and not perform
 	// pre-aggregation at the cell level and use a 'spreading' command to update
 	// the cells to not have to retrieve the data before adding the new value to
 	// it before updating the cell again.
-	// Also note that we know the dimension names so we directly pass them here,
-	// in a real world example it might take a bit more setting up and validation
-	// then assuming the dimension names and presuming they all have a same named
-	// hierarchy (a-typically in cases with multiple, alternate, hierarchies)
-	var jCellUpdates bytes.Buffer
-	var bFirst = true
-	jCellUpdates.WriteString("[")
-	for _, order := range res.Orders {
-		var jOrderTuple bytes.Buffer
-		jOrderTuple.WriteString(`"Dimensions('Customers')/Hierarchies('Customers')/Elements('`)
-		jOrderTuple.WriteString(order.CustomerID)
-		jOrderTuple.WriteString(`')","Dimensions('Employees')/Hierarchies('Employees')/Elements('`)
-		jOrderTuple.WriteString(strconv.Itoa(order.EmployeeID))
-		jOrderTuple.WriteString(`')","Dimensions('Time')/Hierarchies('Time')/Elements('`)
-		jOrderTuple.WriteString(fmt.Sprintf("%02d-%02d-%04d", order.Date.Day(), int(order.Date.Month()), order.Date.Year()))
+	// Also note that we don't depend on the order of the dimensions in the cube but
+	// identify the cells by the element for every dimension, which the cell writer
+	// puts in the order of, and validates against, the dimensions of the cube,
+	// presuming they all have a same named hierarchy (a-typically in cases with
+	// multiple, alternate, hierarchies)
+	for _, order := range orders {
+		date := fmt.Sprintf("%02d-%02d-%04d", order.Date.Day(), int(order.Date.Month()), order.Date.Year())
 		for _, detail := range order.Details {
-			if bFirst == true {
-				bFirst = false
-			} else {
-				jCellUpdates.WriteString(",")
+			cell := map[string]string{
+				productDimensionName:  "P-" + strconv.Itoa(detail.ProductID),
+				customerDimensionName: order.CustomerID,
+				employeeDimensionName: strconv.Itoa(order.EmployeeID),
+				timeDimensionName:     date,
 			}
 			// Quantity
-			jCellUpdates.WriteString(`{"Slice@odata.bind":[`)
-			jCellUpdates.Write(jOrderTuple.Bytes())
-			jCellUpdates.WriteString(`')","Dimensions('Products')/Hierarchies('Products')/Elements('P-`)
-			jCellUpdates.WriteString(strconv.Itoa(detail.ProductID))
-			jCellUpdates.WriteString(`')","Dimensions('Measures')/Hierarchies('Measures')/Elements('Quantity')"],"Value":"+`)
-			jCellUpdates.WriteString(strconv.Itoa(detail.Quantity))
-			jCellUpdates.WriteString(`"},`)
+			cell[measuresDimensionName] = "Quantity"
+			tuple, err := sales.Tuple(cell)
+			if err != nil {
+				return err
+			}
+			if err = sales.Add(float64(detail.Quantity), tuple...); err != nil {
+				return err
+			}
 			// Revenue
-			jCellUpdates.WriteString(`{"Slice@odata.bind":[`)
-			jCellUpdates.Write(jOrderTuple.Bytes())
-			jCellUpdates.WriteString(`')","Dimensions('Products')/Hierarchies('Products')/Elements('P-`)
-			jCellUpdates.WriteString(strconv.Itoa(detail.ProductID))
-			jCellUpdates.WriteString(`')","Dimensions('Measures')/Hierarchies('Measures')/Elements('Revenue')"],"Value":"+`)
-			jCellUpdates.WriteString(fmt.Sprintf("%f", float64(detail.Quantity)*detail.UnitPrice))
-			jCellUpdates.WriteString(`"}`)
+			cell[measuresDimensionName] = "Revenue"
+			tuple, err = sales.Tuple(cell)
+			if err != nil {
+				return err
+			}
+			if err = sales.Add(float64(detail.Quantity)*detail.UnitPrice, tuple...); err != nil {
+				return err
+			}
 		}
 	}
-	jCellUpdates.WriteString("]")
 
 	fmt.Println(">> Loading order data...")
-	resp := client.ExecutePOSTRequest(tm1ServiceRootURL+"Cubes('Sales')/tm1.Update", "application/json", jCellUpdates.String())
-
-	// Validate that the update executed successfully (by default an empty response is expected, hence the 204).
-	odata.ValidateStatusCode(resp, 204, func() string {
-		return "Loading data into cube 'Sales'."
-	})
-	resp.Body.Close()
-
-	// Return the nextLink, if there is one
-	return res.Count, res.NextLink
+	return sales.Write(ctx, client, tm1ServiceRootURL)
 }
 
 func main() {
+	validate := flag.Bool("validate", false, "validate the update request against the server's metadata before loading")
+	flag.Parse()
+
 	// Load environment variables from .env file
 	err := godotenv.Load()
 	if err != nil {
@@ -104,30 +96,40 @@ func main() {
 	cookieJar, _ := cookiejar.New(nil)
 	client.Jar = cookieJar
 
-	// Validate that the TM1 server is accessable by requesting the version of the server
-	req, _ := http.NewRequest("GET", tm1ServiceRootURL+"Configuration/ProductVersion/$value", nil)
-
-	// Since this is our initial request we'll have to provide a user name and
-	// password, also conveniently stored in the environment variables, to authenticate.
-	// Note: using authentication mode 1, TM1 authentication, which maps to basic
-	// authentication in HTTP[S]
-	req.SetBasicAuth(os.Getenv("TM1_USER"), os.Getenv("TM1_PASSWORD"))
-
-	// We'll expect text back in this case but we'll simply dump the content out and
-	// won't do any content type verification here
-	req.Header.Add("Accept", "*/*")
+	// Retry requests failing with a transient error, like a 503 while the TM1 server is busy saving or the
+	// NorthWind service throttling us, and go easy on the public NorthWind service while we're at it.
+	// Note: only idempotent requests get retried, our "+" spreading updates therefore won't be.
+	client.RetryPolicy = odata.DefaultRetryPolicy()
+	client.RateLimiter = odata.NewRateLimiter(10, 5)
+
+	// Validate that the TM1 server is accessable by requesting the version of the server, through the client so
+	// this initial request gets rate limited and retried like any other
+	authenticate := func(req *http.Request) {
+		// Since this is our initial request we'll have to provide a user name and
+		// password, also conveniently stored in the environment variables, to authenticate.
+		// Note: using authentication mode 1, TM1 authentication, which maps to basic
+		// authentication in HTTP[S]
+		req.SetBasicAuth(os.Getenv("TM1_USER"), os.Getenv("TM1_PASSWORD"))
+
+		// We'll expect text back in this case but we'll simply dump the content out and
+		// won't do any content type verification here
+		req.Header.Set("Accept", "*/*")
+	}
 
 	// Let's execute the request
-	resp, err := client.Do(req)
+	resp, err := client.ExecuteGETRequestEx(tm1ServiceRootURL+"Configuration/ProductVersion/$value", authenticate)
 	if err != nil {
 		// Execution of the request failed, log the error and terminate
 		log.Fatal(err)
 	}
 
 	// Validate that the request executed successfully
-	odata.ValidateStatusCode(resp, 200, func() string {
+	err = odata.ValidateStatusCode(resp, 200, func() string {
 		return "Server responded with an unexpected result while asking for its version number."
 	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// The body simply contains the version number of the server
 	version, _ := ioutil.ReadAll(resp.Body)
@@ -140,12 +142,48 @@ func main() {
 	// jar which will automatically be reused on subsequent requests to our TM1 server,
 	// and therefore don't need to send the credentials over and over again.
 
+	// Interrupting the loader cancels the context which aborts any in-flight request and stops the load.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// If asked to, check whether the server supports the request we'll be sending it, by validating the request
+	// path against the server's metadata document. The metadata document isn't always complete, nor always
+	// available, so any problem is only reported as a warning and the load itself decides.
+	if *validate {
+		metadata, err := client.GetMetadata(ctx, tm1ServiceRootURL)
+		if err == nil {
+			err = metadata.ValidatePath(tm1.CubePath(ordersCubeName) + "/tm1.Update")
+		}
+		if err != nil {
+			fmt.Println(">> Warning: unable to validate the update request against the metadata:", err)
+		}
+	}
+
+	// Retrieve the dimensions of our Sales cube, against which the cell writer validates the cells we write
+	sales, err := tm1.LoadCellWriter(ctx, client, tm1ServiceRootURL, ordersCubeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Load the data in the cube
-	// The load once again uses one of our utility functions, IterateCollection, that
-	// iterates the collection and calls back to our processOrderData function.
+	// The load once again uses one of our utility functions, IteratePages, that
+	// iterates the collection and calls back to our processOrderData function with
+	// every page of orders.
 	// The load itself is based on the data from the northwind database, from which we
 	// read the order data once again and this time put the data into our Sales cube.
-	client.IterateCollection(datasourceServiceRootURL, "Orders?$select=CustomerID,EmployeeID,OrderDate&$expand=Order_Details($select=ProductID,UnitPrice,Quantity)", processOrderData)
+	err = odata.IteratePages(ctx, client, datasourceServiceRootURL, odata.NewQuery("Orders").Select("CustomerID", "EmployeeID", "OrderDate").Expand("Order_Details", odata.NewQuery("").Select("ProductID", "UnitPrice", "Quantity")).String(), nil, func(orders []northwind.Order) error {
+		return processOrderData(ctx, sales, orders)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Save the data we've loaded to disk, so it survives a restart of the TM1 server
+	fmt.Println(">> Saving data...")
+	err = tm1.SaveDataAll(ctx, client, tm1ServiceRootURL)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// And we are done!
 	fmt.Println(">> Done!")
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hubert-heijkers/GoTHINK2020/builder/northwind"
+	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
+	"github.com/hubert-heijkers/GoTHINK2020/common/tm1"
+)
+
+func TestProcessOrderData(t *testing.T) {
+	verbose := odata.Verbose
+	odata.Verbose = false
+	t.Cleanup(func() { odata.Verbose = verbose })
+
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client = &odata.Client{}
+	tm1ServiceRootURL = server.URL + "/"
+
+	// The dimensions of the Sales cube in the order in which the builder creates them
+	sales := tm1.NewCellWriter(ordersCubeName, productDimensionName, customerDimensionName, employeeDimensionName, timeDimensionName, measuresDimensionName)
+	orders := []northwind.Order{{
+		CustomerID: "ALFKI",
+		EmployeeID: 6,
+		Date:       time.Date(1997, 8, 25, 0, 0, 0, 0, time.UTC),
+		Details:    []northwind.OrderDetail{{ProductID: 28, UnitPrice: 45.6, Quantity: 15}},
+	}}
+	if err := processOrderData(context.Background(), sales, orders); err != nil {
+		t.Fatal(err)
+	}
+
+	slice := `["Dimensions('Products')/Hierarchies('Products')/Elements('P-28')",` +
+		`"Dimensions('Customers')/Hierarchies('Customers')/Elements('ALFKI')",` +
+		`"Dimensions('Employees')/Hierarchies('Employees')/Elements('6')",` +
+		`"Dimensions('Time')/Hierarchies('Time')/Elements('25-08-1997')",`
+	for _, want := range []string{
+		slice + `"Dimensions('Measures')/Hierarchies('Measures')/Elements('Quantity')"],"Value":"+15"`,
+		slice + `"Dimensions('Measures')/Hierarchies('Measures')/Elements('Revenue')"],"Value":"+684"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("got update\n%s\nwant it to contain\n%s", body, want)
+		}
+	}
+}
@@ -2,14 +2,15 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/cookiejar"
-	"net/url"
 	"os"
+	"os/signal"
 	"time"
 
 	"github.com/hubert-heijkers/GoTHINK2020/common/odata"
@@ -26,18 +27,11 @@ const ordersCubeName = "Sales"
 // The http client, extended with some odata functions, we'll use throughout.
 var client *odata.Client
 
-func processTransactionLogEntries(responseBody []byte) (string, string) {
-	// Unmarshal the JSON response
-	res := tm1.TransactionLogEntriesResponse{}
-	err := json.Unmarshal(responseBody, &res)
-	if err != nil {
-		log.Fatal(err)
-	}
-
+func processTransactionLogEntries(entries []tm1.TransactionLogEntry) error {
 	// Process the entries by simply dumping them in a nicely consumable from to the console
-	for _, entry := range res.TransactionLogEntries {
+	for _, entry := range entries {
 		var out bytes.Buffer
-		out.WriteString(entry.TimeStamp)
+		out.WriteString(entry.TimeStamp.Format(time.RFC3339))
 		out.WriteString(" ")
 		out.WriteString(entry.Cube)
 		out.WriteString("['")
@@ -53,9 +47,7 @@ func processTransactionLogEntries(responseBody []byte) (string, string) {
 		out.WriteString(string(entry.NewValue))
 		fmt.Println(out.String())
 	}
-
-	// Return the nextLink and deltaLink, if there any
-	return res.NextLink, res.DeltaLink
+	return nil
 }
 
 func main() {
@@ -95,9 +87,12 @@ func main() {
 	}
 
 	// Validate that the request executed successfully
-	odata.ValidateStatusCode(resp, 200, func() string {
+	err = odata.ValidateStatusCode(resp, 200, func() string {
 		return "Server responded with an unexpected result while asking for its version number."
 	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// The body simply contains the version number of the server
 	version, _ := ioutil.ReadAll(resp.Body)
@@ -108,5 +103,15 @@ func main() {
 
 	// Track the collection of transaction log entries. This will query the existing entries and then cause
 	// the server to query the delta of the collection (read: just the changes) after a defined duration.
-	client.TrackCollection(tm1ServiceRootURL, "TransactionLogEntries?$filter=Cube%20eq%20'"+url.QueryEscape(ordersCubeName)+"'", 1*time.Second, processTransactionLogEntries)
+	// Tracking continues until the watcher gets interrupted, at which point the context gets cancelled which
+	// aborts any in-flight request and stops the tracking loop cleanly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err = odata.TrackPages(ctx, client, tm1ServiceRootURL, odata.NewQuery("TransactionLogEntries").Filter(odata.Eq(odata.Prop("Cube"), odata.String(ordersCubeName))).String(), 1*time.Second, processTransactionLogEntries)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+
+	// And we are done!
+	fmt.Println(">> Done!")
 }